@@ -1,23 +1,224 @@
 package bbox
 
 import (
+	"errors"
 	"fmt"
+	"github.com/brechtvm/osm/distance"
 	"github.com/brechtvm/osm/point"
+	"math"
+	"strconv"
+	"strings"
 )
 
+// A bounding box. If the longitude of LowerLeft is greater than the one of
+// UpperRight the box crosses the antimeridian, i.e. it reaches from
+// LowerLeft.Lon east to 180 and on from -180 to UpperRight.Lon.
+//
+// NOTE: before the antimeridian support such a box contained nothing and
+// intersected nothing, Contains(), ContainsInclusive(), ContainsBBox(),
+// Intersects(), Intersection() and Union() now treat it as crossing the
+// antimeridian. Boxes with swapped corners must be fixed by the caller.
 type BBox struct {
 	LowerLeft  *point.Point
 	UpperRight *point.Point
 }
 
+// returns a new bounding box
+func New(minLat, minLon, maxLat, maxLon float64) *BBox {
+	return &BBox{LowerLeft: point.New(minLat, minLon), UpperRight: point.New(maxLat, maxLon)}
+}
+
+// parses "minlon,minlat,maxlon,maxlat" as used by the OSM API, minlon may
+// be greater than maxlon for a box crossing the antimeridian
+func Parse(s string) (*BBox, error) {
+	f := strings.Split(s, ",")
+	if len(f) != 4 {
+		return nil, errors.New(fmt.Sprintf("Invalid bounding box %q, expected minlon,minlat,maxlon,maxlat", s))
+	}
+	var v [4]float64
+	for i := range f {
+		n, err := strconv.ParseFloat(strings.TrimSpace(f[i]), 64)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid bounding box %q: %s", s, err))
+		}
+		v[i] = n
+	}
+	minLon, minLat, maxLon, maxLat := v[0], v[1], v[2], v[3]
+	if minLat > maxLat {
+		return nil, errors.New(fmt.Sprintf("Invalid bounding box %q, minlat > maxlat", s))
+	}
+	if minLat < -90 || maxLat > 90 || minLon < -180 || minLon > 180 || maxLon < -180 || maxLon > 180 {
+		return nil, errors.New(fmt.Sprintf("Invalid bounding box %q, out of range", s))
+	}
+	return New(minLat, minLon, maxLat, maxLon), nil
+}
+
 func (b *BBox) String() string {
 	return fmt.Sprintf("  <bounds minlat='%f' minlon='%f' maxlat='%f' maxlon='%f'  />\n",
 		b.LowerLeft.Lat, b.LowerLeft.Lon, b.UpperRight.Lat, b.UpperRight.Lon)
 }
 
+// "minlon,minlat,maxlon,maxlat", the format read by Parse()
+func (b *BBox) Format() string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return strings.Join([]string{f(b.LowerLeft.Lon), f(b.LowerLeft.Lat), f(b.UpperRight.Lon), f(b.UpperRight.Lat)}, ",")
+}
+
+// checks if the box crosses the antimeridian
+func (b *BBox) CrossesAntimeridian() bool {
+	return b.LowerLeft.Lon > b.UpperRight.Lon
+}
+
+// the width of the box in degrees of longitude
+func (b *BBox) lonSpan() float64 {
+	s := b.UpperRight.Lon - b.LowerLeft.Lon
+	if s < 0 {
+		s += 360
+	}
+	return s
+}
+
+// the distance from the western edge eastwards to lon in degrees
+func (b *BBox) lonOffset(lon float64) float64 {
+	return math.Mod(math.Mod(lon-b.LowerLeft.Lon, 360)+360, 360)
+}
+
+// checks if p is inside the box, points on the edges are outside (see
+// ContainsInclusive()). A box crossing the antimeridian contains the
+// points east of LowerLeft.Lon and west of UpperRight.Lon.
 func (b *BBox) Contains(p *point.Point) bool {
-	return p.Lat > b.LowerLeft.Lat && p.Lat < b.UpperRight.Lat &&
-		p.Lon > b.LowerLeft.Lon && p.Lon < b.UpperRight.Lon
+	if !(p.Lat > b.LowerLeft.Lat && p.Lat < b.UpperRight.Lat) {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return p.Lon > b.LowerLeft.Lon || p.Lon < b.UpperRight.Lon
+	}
+	return p.Lon > b.LowerLeft.Lon && p.Lon < b.UpperRight.Lon
+}
+
+// checks if p is inside the box or on its edges
+func (b *BBox) ContainsInclusive(p *point.Point) bool {
+	if !(p.Lat >= b.LowerLeft.Lat && p.Lat <= b.UpperRight.Lat) {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return p.Lon >= b.LowerLeft.Lon || p.Lon <= b.UpperRight.Lon
+	}
+	return p.Lon >= b.LowerLeft.Lon && p.Lon <= b.UpperRight.Lon
+}
+
+// checks if o is inside b (edges may touch)
+func (b *BBox) ContainsBBox(o *BBox) bool {
+	if o.LowerLeft.Lat < b.LowerLeft.Lat || o.UpperRight.Lat > b.UpperRight.Lat {
+		return false
+	}
+	if b.lonSpan() >= 360 {
+		return true
+	}
+	return b.lonOffset(o.LowerLeft.Lon)+o.lonSpan() <= b.lonSpan()
+}
+
+// checks if the boxes overlap (or touch)
+func (b *BBox) Intersects(o *BBox) bool {
+	if o.LowerLeft.Lat > b.UpperRight.Lat || o.UpperRight.Lat < b.LowerLeft.Lat {
+		return false
+	}
+	return b.lonOffset(o.LowerLeft.Lon) <= b.lonSpan() || o.lonOffset(b.LowerLeft.Lon) <= o.lonSpan()
+}
+
+// returns the overlapping part of the boxes, false if they do not
+// intersect. If the boxes overlap in two parts (only possible if both
+// span most of the world) the larger one is returned.
+func (b *BBox) Intersection(o *BBox) (*BBox, bool) {
+	if !b.Intersects(o) {
+		return nil, false
+	}
+	minLat := math.Max(b.LowerLeft.Lat, o.LowerLeft.Lat)
+	maxLat := math.Min(b.UpperRight.Lat, o.UpperRight.Lat)
+	// with b starting at 0, o starts at start and its copy at start-360
+	bs, os := b.lonSpan(), o.lonSpan()
+	start := b.lonOffset(o.LowerLeft.Lon)
+	from, span := -1.0, -1.0
+	for _, s := range []float64{start, start - 360} {
+		f, t := math.Max(0, s), math.Min(bs, s+os)
+		if t-f > span {
+			from, span = f, t-f
+		}
+	}
+	minLon := normLon(b.LowerLeft.Lon + from)
+	maxLon := normLon(b.LowerLeft.Lon + from + span)
+	if span >= 360 {
+		minLon, maxLon = -180, 180
+	}
+	return New(minLat, minLon, maxLat, maxLon), true
+}
+
+// returns the smallest box containing both boxes
+func (b *BBox) Union(o *BBox) *BBox {
+	minLat := math.Min(b.LowerLeft.Lat, o.LowerLeft.Lat)
+	maxLat := math.Max(b.UpperRight.Lat, o.UpperRight.Lat)
+	if b.ContainsBBox(o) || o.ContainsBBox(b) {
+		w := b
+		if o.lonSpan() > b.lonSpan() {
+			w = o
+		}
+		return New(minLat, w.LowerLeft.Lon, maxLat, w.UpperRight.Lon)
+	}
+	// either from the west edge of b to the east edge of o or the other
+	// way round, whichever is narrower
+	e1 := b.lonOffset(o.LowerLeft.Lon) + o.lonSpan()
+	e2 := o.lonOffset(b.LowerLeft.Lon) + b.lonSpan()
+	if math.Min(e1, e2) >= 360 {
+		return New(minLat, -180, maxLat, 180)
+	}
+	if e1 <= e2 {
+		return New(minLat, b.LowerLeft.Lon, maxLat, o.UpperRight.Lon)
+	}
+	return New(minLat, o.LowerLeft.Lon, maxLat, b.UpperRight.Lon)
+}
+
+// extends the box to contain p
+func (b *BBox) Extend(p *point.Point) *BBox {
+	return b.Union(New(p.Lat, p.Lon, p.Lat, p.Lon))
+}
+
+func normLon(l float64) float64 {
+	if l >= -180 && l <= 180 {
+		return l
+	}
+	return math.Mod(math.Mod(l+180, 360)+360, 360) - 180
+}
+
+// Returns a box which is larger by d on every side. The latitudes are
+// clipped at the poles, a box which would wrap around the world gets all
+// longitudes.
+func (b *BBox) Expand(d distance.Distance) *BBox {
+	dlat := float64(d/distance.EarthRadius) * 180 / math.Pi
+	minLat := math.Max(-90, b.LowerLeft.Lat-dlat)
+	maxLat := math.Min(90, b.UpperRight.Lat+dlat)
+	// the longitude difference is largest at the latitude nearest a pole
+	c := math.Cos(math.Max(math.Abs(minLat), math.Abs(maxLat)) * math.Pi / 180)
+	if c < 1e-9 || b.lonSpan()+2*dlat/c >= 360 {
+		return New(minLat, -180, maxLat, 180)
+	}
+	dlon := dlat / c
+	return New(minLat, normLon(b.LowerLeft.Lon-dlon), maxLat, normLon(b.UpperRight.Lon+dlon))
+}
+
+// the center of the box
+func (b *BBox) Center() *point.Point {
+	return point.New((b.LowerLeft.Lat+b.UpperRight.Lat)/2, normLon(b.LowerLeft.Lon+b.lonSpan()/2))
+}
+
+// the east-west extent of the box measured along its middle latitude
+func (b *BBox) Width() distance.Distance {
+	lat := (b.LowerLeft.Lat + b.UpperRight.Lat) / 2 * math.Pi / 180
+	return distance.Distance(b.lonSpan()*math.Pi/180*math.Cos(lat)) * distance.EarthRadius
+}
+
+// the north-south extent of the box
+func (b *BBox) Height() distance.Distance {
+	return distance.Distance((b.UpperRight.Lat-b.LowerLeft.Lat)*math.Pi/180) * distance.EarthRadius
 }
 
 // vim: ts=4 sw=4 noexpandtab nolist syn=go
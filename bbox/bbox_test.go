@@ -0,0 +1,108 @@
+package bbox
+
+import (
+	"github.com/brechtvm/osm/point"
+	"testing"
+)
+
+func sameBBox(a, b *BBox) bool {
+	return a.LowerLeft.Lat == b.LowerLeft.Lat && a.LowerLeft.Lon == b.LowerLeft.Lon &&
+		a.UpperRight.Lat == b.UpperRight.Lat && a.UpperRight.Lon == b.UpperRight.Lon
+}
+
+func TestContains(t *testing.T) {
+	normal := New(0, 10, 10, 20)
+	// from 170 east over the antimeridian to -170
+	crossing := New(0, 170, 10, -170)
+	for _, c := range []struct {
+		b         *BBox
+		p         *point.Point
+		contains  bool
+		inclusive bool
+	}{
+		{normal, point.New(5, 15), true, true},
+		{normal, point.New(5, 10), false, true},
+		{normal, point.New(5, 25), false, false},
+		{normal, point.New(5, 175), false, false},
+		{crossing, point.New(5, 175), true, true},
+		{crossing, point.New(5, -175), true, true},
+		{crossing, point.New(5, 180), true, true},
+		{crossing, point.New(5, -180), true, true},
+		{crossing, point.New(5, 170), false, true},
+		{crossing, point.New(5, -170), false, true},
+		{crossing, point.New(5, 0), false, false},
+		{crossing, point.New(5, 15), false, false},
+		{crossing, point.New(11, 175), false, false},
+	} {
+		if got := c.b.Contains(c.p); got != c.contains {
+			t.Errorf("%s Contains(%v) = %v, want %v", c.b.Format(), c.p, got, c.contains)
+		}
+		if got := c.b.ContainsInclusive(c.p); got != c.inclusive {
+			t.Errorf("%s ContainsInclusive(%v) = %v, want %v", c.b.Format(), c.p, got, c.inclusive)
+		}
+	}
+	if !crossing.ContainsBBox(New(2, 175, 8, -175)) || !crossing.ContainsBBox(New(2, 172, 8, 178)) {
+		t.Error("crossing box does not contain boxes on the antimeridian")
+	}
+	if crossing.ContainsBBox(normal) || crossing.ContainsBBox(New(2, 160, 8, -175)) {
+		t.Error("crossing box contains boxes outside")
+	}
+}
+
+func TestIntersection(t *testing.T) {
+	crossing := New(0, 170, 10, -170)
+	for _, c := range []struct {
+		a, b *BBox
+		want *BBox // nil if they do not intersect
+	}{
+		{New(0, 0, 10, 10), New(5, 5, 15, 15), New(5, 5, 10, 10)},
+		{New(0, 0, 10, 10), New(5, 20, 15, 30), nil},
+		{crossing, New(5, -175, 15, 0), New(5, -175, 10, -170)},
+		{crossing, New(5, 160, 15, 175), New(5, 170, 10, 175)},
+		{crossing, New(5, 175, 15, -160), New(5, 175, 10, -170)},
+		{crossing, New(0, 0, 10, 10), nil},
+	} {
+		for _, ab := range [][2]*BBox{{c.a, c.b}, {c.b, c.a}} {
+			got, ok := ab[0].Intersection(ab[1])
+			if ok != (c.want != nil) || ok && !sameBBox(got, c.want) {
+				t.Errorf("%s intersection %s = %v %v, want %v", ab[0].Format(), ab[1].Format(), got, ok, c.want)
+			}
+			if ab[0].Intersects(ab[1]) != ok {
+				t.Errorf("%s Intersects(%s) != %v", ab[0].Format(), ab[1].Format(), ok)
+			}
+		}
+	}
+}
+
+// boxes touching at the antimeridian intersect
+func TestIntersectsAtAntimeridian(t *testing.T) {
+	if !New(0, 175, 10, 180).Intersects(New(0, -180, 10, -175)) {
+		t.Error("boxes touching at the antimeridian do not intersect")
+	}
+}
+
+func TestUnion(t *testing.T) {
+	for _, c := range []struct {
+		a, b, want *BBox
+	}{
+		{New(0, 0, 10, 10), New(5, 20, 15, 30), New(0, 0, 15, 30)},
+		// narrower over the antimeridian than around the world
+		{New(0, 170, 10, 180), New(0, -180, 10, -170), New(0, 170, 10, -170)},
+		{New(0, 160, 10, 170), New(5, -175, 15, -165), New(0, 160, 15, -165)},
+		{New(0, 170, 10, -170), New(0, 175, 5, 178), New(0, 170, 10, -170)},
+		{New(0, 170, 10, -170), New(0, -175, 5, 10), New(0, 170, 10, 10)},
+		// both boxes together wrap around the world
+		{New(0, -100, 10, 100), New(0, 90, 10, -90), New(0, -180, 10, 180)},
+	} {
+		for _, ab := range [][2]*BBox{{c.a, c.b}, {c.b, c.a}} {
+			if got := ab[0].Union(ab[1]); !sameBBox(got, c.want) {
+				t.Errorf("%s union %s = %s, want %s", ab[0].Format(), ab[1].Format(), got.Format(), c.want.Format())
+			}
+		}
+	}
+	if got := New(0, 170, 10, 175).Extend(point.New(5, -178)); !sameBBox(got, New(0, 170, 10, -178)) {
+		t.Errorf("extended over the antimeridian to %s", got.Format())
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
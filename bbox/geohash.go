@@ -0,0 +1,31 @@
+package bbox
+
+import (
+	"github.com/brechtvm/osm/point"
+)
+
+// returns the cell of a geohash
+func FromGeohash(hash string) (*BBox, error) {
+	minLat, minLon, maxLat, maxLon, err := point.GeohashBounds(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &BBox{LowerLeft: point.New(minLat, minLon), UpperRight: point.New(maxLat, maxLon)}, nil
+}
+
+// returns the longest geohash whose cell contains the whole box, an empty
+// string if there is none (e.g. for a box around the equator)
+func (b *BBox) Geohash() string {
+	// cells include their lower and left edges only, so the upper right
+	// corner is compared with the bounds of the cells
+	for prec := point.MaxGeohashPrecision; prec > 0; prec-- {
+		h := b.LowerLeft.Geohash(prec)
+		_, _, maxLat, maxLon, _ := point.GeohashBounds(h)
+		if b.UpperRight.Lat <= maxLat && b.UpperRight.Lon <= maxLon {
+			return h
+		}
+	}
+	return ""
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,42 @@
+package bbox
+
+import (
+	"testing"
+)
+
+func TestFromGeohash(t *testing.T) {
+	b, err := FromGeohash("ezs42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := New(42.5830078125, -5.625, 42.626953125, -5.5810546875); !sameBBox(b, want) {
+		t.Errorf("box of ezs42 is %s, want %s", b.Format(), want.Format())
+	}
+	if _, err = FromGeohash("ezs4a"); err == nil {
+		t.Error("no error for an invalid geohash")
+	}
+}
+
+func TestGeohash(t *testing.T) {
+	for _, c := range []struct {
+		b    *BBox
+		hash string
+	}{
+		// a cell is its own geohash
+		{New(42.5830078125, -5.625, 42.626953125, -5.5810546875), "ezs42"},
+		{New(42.59, -5.62, 42.62, -5.59), "ezs42"},
+		// over the edge of ezs42 into ezs43
+		{New(42.59, -5.62, 42.62, -5.58), "ezs4"},
+		{New(57.649, 10.407, 57.65, 10.408), "u4pruy"},
+		{New(57.64911, 10.40744, 57.64911, 10.40744), "u4pruydqqvj8"},
+		// nothing contains a box around the equator or the prime meridian
+		{New(-1, 10, 1, 11), ""},
+		{New(50, -1, 51, 1), ""},
+	} {
+		if got := c.b.Geohash(); got != c.hash {
+			t.Errorf("geohash of %s is %q, want %q", c.b.Format(), got, c.hash)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
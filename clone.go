@@ -0,0 +1,118 @@
+package osm
+
+import (
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/user"
+	"github.com/brechtvm/osm/way"
+	"time"
+)
+
+// returns a deep copy of the OSM: all nodes, ways, relations, tags and
+// users are copied, ways and relations of the copy refer to the copied
+// nodes, ways and relations. Changes to the copy do not affect o.
+//
+// The Handler and the Locations index are shared, a TagIndex is rebuilt
+// for the copy.
+func (o *OSM) Clone() *OSM {
+	c := &OSM{
+		Version:   o.Version,
+		BBox:      cloneBBox(o.BBox),
+		Origin:    o.Origin,
+		Nodes:     make(map[int64]*node.Node, len(o.Nodes)),
+		Ways:      make(map[int64]*way.Way, len(o.Ways)),
+		Relations: make(map[int64]*relation.Relation, len(o.Relations)),
+		Handler:   o.Handler,
+		Locations: o.Locations,
+	}
+	if o.Users != nil {
+		c.Users = make(map[uint32]*user.User, len(o.Users))
+		for id, u := range o.Users {
+			c.Users[id] = user.New(u.Id, u.Name)
+		}
+	}
+	if o.Timestamps != nil {
+		c.Timestamps = make(map[string]time.Time, len(o.Timestamps))
+		for k, t := range o.Timestamps {
+			c.Timestamps[k] = t
+		}
+	}
+	if o.NodeStore != nil {
+		c.NodeStore = o.NodeStore.Clone()
+	}
+
+	for id, n := range o.Nodes {
+		c.Nodes[id] = n.DeepCopy()
+	}
+	for id, w := range o.Ways {
+		c.Ways[id] = w.DeepCopyWith(c.Nodes)
+	}
+	for _, r := range o.Relations {
+		r.DeepCopyWith(c.Nodes, c.Ways, c.Relations)
+	}
+	c.dropUnknown(o)
+	if o.TagIndex != nil {
+		c.BuildTagIndex()
+	}
+	return c
+}
+
+// returns a new OSM with deep copies of the given items and everything
+// they depend on: the nodes of ways and all members of relations
+// (recursively).
+func (o *OSM) CloneItems(items ...item.Item) *OSM {
+	c := NewOSM(o.Handler)
+	c.Origin = o.Origin
+	for _, i := range items {
+		switch i.(type) {
+		case *node.Node:
+			if _, ok := c.Nodes[i.Id()]; !ok {
+				c.Nodes[i.Id()] = i.(*node.Node).DeepCopy()
+			}
+		case *way.Way:
+			if _, ok := c.Ways[i.Id()]; !ok {
+				c.Ways[i.Id()] = i.(*way.Way).DeepCopyWith(c.Nodes)
+			}
+		case *relation.Relation:
+			i.(*relation.Relation).DeepCopyWith(c.Nodes, c.Ways, c.Relations)
+		}
+	}
+	return c
+}
+
+// DeepCopyWith() adds copies of relation members which are not part of
+// the original OSM (e.g. nodes materialised from a NodeStore), these are
+// removed again to get the same set of items as in o.
+func (c *OSM) dropUnknown(o *OSM) {
+	for id := range c.Nodes {
+		if _, ok := o.Nodes[id]; !ok {
+			delete(c.Nodes, id)
+		}
+	}
+	for id := range c.Ways {
+		if _, ok := o.Ways[id]; !ok {
+			delete(c.Ways, id)
+		}
+	}
+	for id := range c.Relations {
+		if _, ok := o.Relations[id]; !ok {
+			delete(c.Relations, id)
+		}
+	}
+}
+
+func cloneBBox(b bbox.BBox) bbox.BBox {
+	var c bbox.BBox
+	if b.LowerLeft != nil {
+		c.LowerLeft = point.New(b.LowerLeft.Lat, b.LowerLeft.Lon)
+	}
+	if b.UpperRight != nil {
+		c.UpperRight = point.New(b.UpperRight.Lat, b.UpperRight.Lon)
+	}
+	return c
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
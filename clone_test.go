@@ -0,0 +1,184 @@
+package osm
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/user"
+	"github.com/brechtvm/osm/way"
+	"testing"
+	"time"
+)
+
+// the ways 10 (1-2-3) and 11 (3-4) share node 3, relation 30 has node 1,
+// way 10, relation 31 and a missing way as members, relation 31 refers
+// back to relation 30
+func cloneOSM() *OSM {
+	o := NewOSM(nil)
+	for id := int64(1); id <= 5; id++ {
+		o.AddNode(testNode(id, 50, 4+float64(id)/10))
+	}
+	o.Nodes[1].Tags_.Add("amenity", "bench")
+	o.Nodes[1].User_ = user.New(7, "alice")
+	for _, w := range []*way.Way{testWay(10, []int64{1, 2, 3}, "highway", "residential"), testWay(11, []int64{3, 4})} {
+		for _, id := range w.NodeIDs {
+			w.Nodes_ = append(w.Nodes_, o.Nodes[id])
+		}
+		o.AddWay(w)
+	}
+	r30 := &relation.Relation{Id_: 30, Tags_: &tags.Tags{"type": "route"}}
+	r31 := &relation.Relation{Id_: 31, Tags_: tags.New(), Members_: []*relation.Member{{Type_: item.TypeRelation, Id_: 30, Ref: r30}}}
+	r30.Members_ = []*relation.Member{
+		{Type_: item.TypeNode, Id_: 1, Role: "stop", Ref: o.Nodes[1]},
+		{Type_: item.TypeWay, Id_: 10, Ref: o.Ways[10]},
+		{Type_: item.TypeRelation, Id_: 31, Ref: r31},
+		{Type_: item.TypeWay, Id_: 99},
+	}
+	o.AddRelation(r30)
+	o.AddRelation(r31)
+	o.Users = map[uint32]*user.User{7: user.New(7, "alice")}
+	o.Timestamps = map[string]time.Time{"node/1": time.Unix(1e9, 0)}
+	return o
+}
+
+// checks that the refs of the ways and relations of c are the items of c
+func checkRefs(t *testing.T, name string, c *OSM) {
+	t.Helper()
+	for _, w := range c.Ways {
+		for i, n := range w.Nodes_ {
+			if n != c.Nodes[n.Id_] {
+				t.Errorf("%s: node %d of way %d is not the node of the OSM", name, i, w.Id_)
+			}
+		}
+	}
+	for _, r := range c.Relations {
+		for _, m := range r.Members_ {
+			var ok bool
+			switch ref := m.Ref.(type) {
+			case *node.Node:
+				ok = ref == c.Nodes[m.Id_]
+			case *way.Way:
+				ok = ref == c.Ways[m.Id_]
+			case *relation.Relation:
+				ok = ref == c.Relations[m.Id_]
+			default:
+				ok = m.Ref == nil && c.Ways[m.Id_] == nil
+			}
+			if !ok {
+				t.Errorf("%s: member %s #%d of relation %d is not the item of the OSM", name, m.Type_, m.Id_, r.Id_)
+			}
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	o := cloneOSM()
+	o.BuildTagIndex()
+	c := o.Clone()
+	if len(c.Nodes) != 5 || len(c.Ways) != 2 || len(c.Relations) != 2 {
+		t.Fatalf("clone has %d nodes, %d ways and %d relations", len(c.Nodes), len(c.Ways), len(c.Relations))
+	}
+	checkRefs(t, "clone", c)
+	if c.Nodes[1] == o.Nodes[1] || c.Ways[10] == o.Ways[10] || c.Relations[30] == o.Relations[30] {
+		t.Error("clone shares items with the original")
+	}
+	if c.TagIndex == nil || c.TagIndex == o.TagIndex {
+		t.Error("tag index of the clone not rebuilt")
+	}
+
+	// changes to the clone
+	c.Nodes[1].Position_.Lat = 0
+	c.Nodes[1].Tags_.Add("amenity", "shelter")
+	c.Nodes[1].User_.Name = "bob"
+	c.Ways[10].Tags_.Delete("highway")
+	c.Ways[10].Nodes_[0] = c.Nodes[5]
+	c.Relations[30].Members_[0].Role = "platform"
+	c.Relations[30].Tags_.Add("name", "x")
+	c.Users[7].Name = "bob"
+	c.Timestamps["node/1"] = time.Unix(0, 0)
+	c.BBox.LowerLeft = point.New(1, 1)
+
+	n := o.Nodes[1]
+	if n.Position_.Lat != 50 || n.Tags_.Get("amenity") != "bench" || n.User_.Name != "alice" {
+		t.Errorf("original node 1 changed through the clone: %v %v %v", n.Position_, n.Tags_, n.User_)
+	}
+	if o.Ways[10].Tags_.Get("highway") != "residential" || o.Ways[10].Nodes_[0] != o.Nodes[1] {
+		t.Error("original way 10 changed through the clone")
+	}
+	if r := o.Relations[30]; r.Members_[0].Role != "stop" || r.Tags_.Get("name") != "" {
+		t.Error("original relation 30 changed through the clone")
+	}
+	if o.Users[7].Name != "alice" || o.Timestamps["node/1"].Unix() != 1e9 || o.BBox.LowerLeft != nil {
+		t.Error("users, timestamps or bounds of the original changed through the clone")
+	}
+	if o.NodesWithTag("amenity", "bench").Len() != 1 {
+		t.Error("tag index of the original changed through the clone")
+	}
+}
+
+// the nodes in the NodeStore are copied with the store, only the nodes
+// of the Nodes map are deep copies
+func TestCloneCompact(t *testing.T) {
+	o := compactOSM()
+	if err := o.ResolveWay(o.Ways[10]); err != nil {
+		t.Fatal(err)
+	}
+	// node 4, a member of relation 20, is flushed and detached
+	o.FlushNodes()
+	o.GetNode(1)
+	o.Ways[10].Nodes_[0] = o.GetNode(1)
+	c := o.Clone()
+	if c.NodeStore == nil || c.NodeStore == o.NodeStore || c.NodeCount() != 4 {
+		t.Fatalf("clone has %d nodes and the NodeStore %p", c.NodeCount(), c.NodeStore)
+	}
+	if len(c.Nodes) != 1 || c.Nodes[1] == nil || c.Ways[10].Nodes_[0] != c.Nodes[1] {
+		t.Errorf("nodes map of the clone has %d nodes, want node 1 of way 10", len(c.Nodes))
+	}
+	if c.GetNode(4) == nil || c.GetNode(4).Tags_.Get("amenity") != "bench" {
+		t.Errorf("node 4 of the clone is %v", c.GetNode(4))
+	}
+
+	c.GetNode(2).Position_.Lat = 0
+	c.AddNode(testNode(3, 0, 0))
+	c.AddNode(testNode(5, 0, 0))
+	c.FlushNodes()
+	if p, _ := o.NodeStore.Position(2); p.Lat != 50.1 {
+		t.Errorf("stored node 2 of the original at %v", p)
+	}
+	if p, _ := o.NodeStore.Position(3); p.Lat != 50.2 || o.NodeCount() != 4 {
+		t.Errorf("stored node 3 of the original at %v, %d nodes", p, o.NodeCount())
+	}
+}
+
+func TestCloneItems(t *testing.T) {
+	o := cloneOSM()
+	c := o.CloneItems(o.Ways[11], o.Nodes[5], o.Ways[11])
+	if len(c.Nodes) != 3 || c.Nodes[3] == nil || c.Nodes[4] == nil || c.Nodes[5] == nil || len(c.Ways) != 1 || len(c.Relations) != 0 {
+		t.Errorf("clone of way 11 and node 5 has %d nodes, %d ways and %d relations", len(c.Nodes), len(c.Ways), len(c.Relations))
+	}
+	checkRefs(t, "way 11", c)
+
+	// relation 30 pulls in relation 31, way 10 and its nodes, the missing
+	// way 99 stays missing
+	c = o.CloneItems(o.Relations[30], o.Ways[11])
+	if len(c.Nodes) != 4 || len(c.Ways) != 2 || len(c.Relations) != 2 || c.Ways[99] != nil {
+		t.Errorf("clone of relation 30 has %d nodes, %d ways and %d relations", len(c.Nodes), len(c.Ways), len(c.Relations))
+	}
+	checkRefs(t, "relation 30", c)
+	if c.Ways[11].Nodes_[0] != c.Ways[10].Nodes_[2] {
+		t.Error("node 3 is not shared by the ways 10 and 11 of the clone")
+	}
+	if m := c.Relations[30].Members_[3]; m.Id_ != 99 || m.Ref != nil {
+		t.Errorf("missing member of the clone is %v", m)
+	}
+	if c.Relations[31].Members_[0].Ref != c.Relations[30] {
+		t.Error("relation 31 of the clone does not refer to relation 30 of the clone")
+	}
+	if c.Nodes[1] == o.Nodes[1] {
+		t.Error("clone shares node 1 with the original")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,31 @@
+package distance
+
+import (
+	"fmt"
+)
+
+// an area in square meters
+type Area float64
+
+const (
+	SquareMeter      Area = 1.0
+	SquareKilometer       = 1000 * 1000 * SquareMeter // km²
+	Hectare               = 10000 * SquareMeter       // ha
+	Are                   = 100 * SquareMeter         // a
+	SquareCentimeter      = SquareMeter / 10000       // cm²
+)
+
+func (a Area) String() string {
+	if a < 0 {
+		return "-" + (-a).String()
+	}
+	if a >= SquareKilometer {
+		return fmt.Sprintf("%f", float64(a/SquareKilometer)) + "km²"
+	}
+	if a >= SquareMeter || a == 0 {
+		return fmt.Sprintf("%f", float64(a/SquareMeter)) + "m²"
+	}
+	return fmt.Sprintf("%f", float64(a/SquareCentimeter)) + "cm²"
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,27 @@
+package distance
+
+import (
+	"testing"
+)
+
+func TestAreaString(t *testing.T) {
+	for _, c := range []struct {
+		a    Area
+		want string
+	}{
+		{1500000, "1.500000km²"},
+		{12.5, "12.500000m²"},
+		{0, "0.000000m²"},
+		{0.5, "5000.000000cm²"},
+		{-2 * Hectare, "-20000.000000m²"},
+	} {
+		if got := c.a.String(); got != c.want {
+			t.Errorf("Area(%g) = %s, want %s", float64(c.a), got, c.want)
+		}
+	}
+	if SquareKilometer != 100*Hectare || Hectare != 100*Are {
+		t.Error("units do not match")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,445 @@
+package osm
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/user"
+	"github.com/brechtvm/osm/way"
+	"time"
+)
+
+// the strategies of ExtractBBox(), named after the osmium extract
+// strategies
+type ExtractStrategy int
+
+const (
+	// nodes inside the box, ways with at least one node inside the box
+	// and relations with at least one included member. Ways and relations
+	// are reference incomplete, i.e. nodes of a way outside the box are
+	// not part of the extract.
+	ExtractSimple ExtractStrategy = iota
+	// like ExtractSimple, but all nodes of the included ways are added
+	ExtractCompleteWays
+	// like ExtractCompleteWays, but multipolygon relations with at least
+	// one included member are completed: all their member ways (with all
+	// nodes) are added. The completed ways and nodes select no further
+	// relations.
+	ExtractSmart
+)
+
+func (s ExtractStrategy) String() string {
+	switch s {
+	case ExtractSimple:
+		return "simple"
+	case ExtractCompleteWays:
+		return "complete_ways"
+	case ExtractSmart:
+		return "smart"
+	}
+	return "unknown"
+}
+
+// the ids of the items selected for an extract
+type extractSet struct {
+	bb        bbox.BBox
+	contains  func(*point.Point) bool
+	strategy  ExtractStrategy
+	nodes     map[int64]bool
+	ways      map[int64]bool
+	relations map[int64]bool
+}
+
+// the set for an extract of the area for which contains returns true,
+// contains is only called for points inside bb
+func newExtractSet(bb bbox.BBox, contains func(*point.Point) bool, s ExtractStrategy) *extractSet {
+	return &extractSet{
+		bb:        bb,
+		contains:  contains,
+		strategy:  s,
+		nodes:     make(map[int64]bool),
+		ways:      make(map[int64]bool),
+		relations: make(map[int64]bool),
+	}
+}
+
+func (e *extractSet) inside(p *point.Point) bool {
+	return p != nil && e.bb.ContainsInclusive(p) && (e.contains == nil || e.contains(p))
+}
+
+func polygonSet(mp polygon.MultiPolygon, s ExtractStrategy) (*extractSet, error) {
+	bb, err := mp.BoundingBox()
+	if err != nil {
+		return nil, err
+	}
+	return newExtractSet(*bb, mp.Contains, s), nil
+}
+
+func wayNodeIds(w *way.Way) []int64 {
+	if len(w.NodeIDs) > 0 {
+		return w.NodeIDs
+	}
+	ids := make([]int64, len(w.Nodes_))
+	for i, n := range w.Nodes_ {
+		ids[i] = n.Id_
+	}
+	return ids
+}
+
+// checks if the way has a node in the set, the positions of Nodes_ are
+// used for nodes which are not (yet) known, as in handler mode with a
+// location index
+func (e *extractSet) touches(w *way.Way) bool {
+	for _, id := range wayNodeIds(w) {
+		if e.nodes[id] {
+			return true
+		}
+	}
+	for _, n := range w.Nodes_ {
+		if n != nil && e.inside(n.Position_) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *extractSet) addWayNodes(w *way.Way) {
+	for _, id := range wayNodeIds(w) {
+		e.nodes[id] = true
+	}
+}
+
+// checks if a member of the relation is in the set
+func (e *extractSet) referenced(r *relation.Relation) bool {
+	for _, m := range r.Members_ {
+		switch m.Type_ {
+		case item.TypeNode:
+			if e.nodes[m.Id_] {
+				return true
+			}
+		case item.TypeWay:
+			if e.ways[m.Id_] {
+				return true
+			}
+		case item.TypeRelation:
+			if e.relations[m.Id_] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// the ids of the member ways of a selected relation which the strategy
+// adds to complete it, i.e. the ways of a multipolygon which are not in
+// the set yet
+func (e *extractSet) completion(r *relation.Relation) []int64 {
+	if e.strategy != ExtractSmart || !r.IsMultipolygon() {
+		return nil
+	}
+	var ids []int64
+	for _, m := range r.Members_ {
+		if m.Type_ == item.TypeWay && !e.ways[m.Id_] {
+			ids = append(ids, m.Id_)
+		}
+	}
+	return ids
+}
+
+// Returns a new OSM with the items selected by the strategy from the
+// bounding box (including its edges). The items are shared with o, use
+// Clone() on the result for an independent copy. See ExtractFilter for
+// the same on a stream of items.
+func (o *OSM) ExtractBBox(bb bbox.BBox, s ExtractStrategy) *OSM {
+	return o.extract(newExtractSet(bb, nil, s))
+}
+
+// Returns a new OSM with the items selected by the strategy from the area
+// of the multipolygon, e.g. read from a .poly file (see the poly package).
+// The bounding box of the result is the one of the multipolygon. Points
+// on the boundary may be inside or outside.
+func (o *OSM) ExtractPolygon(mp polygon.MultiPolygon, s ExtractStrategy) (*OSM, error) {
+	e, err := polygonSet(mp, s)
+	if err != nil {
+		return nil, err
+	}
+	return o.extract(e), nil
+}
+
+// Returns a new OSM with the items selected by the strategy from the area
+// of the area relation (e.g. an administrative boundary) with the given
+// id, which must be part of o. Member ways with only NodeIDs are resolved
+// into copies, the ways of o are not changed.
+func (o *OSM) ExtractRelation(id int64, s ExtractStrategy) (*OSM, error) {
+	r := o.Relations[id]
+	if r == nil {
+		return nil, errors.New(fmt.Sprintf("Missing relation #%d", id))
+	}
+	c := *r
+	c.Members_ = make([]*relation.Member, len(r.Members_))
+	for i, m := range r.Members_ {
+		mc := *m
+		if w, ok := m.Ref.(*way.Way); ok && w != nil {
+			wc := *w
+			if err := o.ResolveWay(&wc); err != nil {
+				return nil, err
+			}
+			mc.Ref = &wc
+		}
+		c.Members_[i] = &mc
+	}
+	mp, err := c.MultiPolygon()
+	if err != nil {
+		return nil, err
+	}
+	return o.ExtractPolygon(mp, s)
+}
+
+func (o *OSM) extract(e *extractSet) *OSM {
+	s := e.strategy
+	for n := range o.AllNodes() {
+		if e.inside(n.Position_) {
+			e.nodes[n.Id_] = true
+		}
+	}
+	for _, w := range o.Ways {
+		if e.touches(w) {
+			e.ways[w.Id_] = true
+		}
+	}
+	if s != ExtractSimple {
+		for id := range e.ways {
+			e.addWayNodes(o.Ways[id])
+		}
+	}
+	// relations may refer to relations, repeat until nothing is added
+	for changed := true; changed; {
+		changed = false
+		for _, r := range o.Relations {
+			if e.relations[r.Id_] || !e.referenced(r) {
+				continue
+			}
+			e.relations[r.Id_] = true
+			changed = true
+		}
+	}
+	// the set is complete, the ways added now select nothing
+	var complete []int64
+	for id := range e.relations {
+		complete = append(complete, e.completion(o.Relations[id])...)
+	}
+	for _, id := range complete {
+		if w, ok := o.Ways[id]; ok {
+			e.ways[id] = true
+			e.addWayNodes(w)
+		}
+	}
+
+	x := NewOSM(nil)
+	x.Origin = o.Origin
+	x.BBox = cloneBBox(e.bb)
+	for id := range e.nodes {
+		if n := o.GetNode(id); n != nil {
+			x.AddNode(n)
+			x.addUser(o, n.User_, n.Timestamp_)
+		}
+	}
+	for id := range e.ways {
+		w := o.Ways[id]
+		x.AddWay(w)
+		x.addUser(o, w.User_, w.Timestamp_)
+	}
+	for id := range e.relations {
+		r := o.Relations[id]
+		x.AddRelation(r)
+		x.addUser(o, r.User_, r.Timestamp_)
+	}
+	return x
+}
+
+// copies the user and the timestamp of an extracted item from the Users
+// and Timestamps of o, if o has them
+func (x *OSM) addUser(o *OSM, u *user.User, t time.Time) {
+	if u != nil && o.Users != nil {
+		if ou, ok := o.Users[u.Id]; ok {
+			if x.Users == nil {
+				x.Users = make(map[uint32]*user.User)
+			}
+			x.Users[u.Id] = ou
+		}
+	}
+	if o.Timestamps != nil {
+		k := fmt.Sprintf("%s", t)
+		if ot, ok := o.Timestamps[k]; ok {
+			if x.Timestamps == nil {
+				x.Timestamps = make(map[string]time.Time)
+			}
+			x.Timestamps[k] = ot
+		}
+	}
+}
+
+// ExtractFilter is an OSMReader which passes the items of an extract (see
+// ExtractBBox()) on to the next OSMReader, e.g.
+//
+//	f := osm.NewExtractFilter(bb, osm.ExtractCompleteWays, handler)
+//	for f.NextPass() {
+//		fh, _ := os.Open(file)
+//		osm.New(pbf.Parser(fh), f)
+//		fh.Close()
+//	}
+//
+// As nodes come before ways and ways before relations, the strategies
+// other than ExtractSimple need more than one pass over the input: the
+// first passes only collect the ids of the selected items, the last pass
+// hands them to the next reader. The filter keeps only these ids in
+// memory.
+//
+// The items are selected by the same rules as ExtractBBox(): relations
+// are selected by the nodes of the selected ways and not by the ways and
+// nodes added to complete multipolygons. In contrast to ExtractBBox()
+// relations are only selected by members of relations seen earlier in the
+// same pass or in a previous one, and the smart strategy only completes
+// the multipolygons selected in the first pass.
+type ExtractFilter struct {
+	set  *extractSet
+	next OSMReader
+	pass int
+	// ids of multipolygon member ways to complete in the next pass, and
+	// of their nodes. They are kept out of the set so that they select no
+	// relations, like in ExtractBBox()
+	complete      map[int64]bool
+	completeNodes map[int64]bool
+	// ids of the nodes of the selected ways, added to the set after the
+	// ways so that they select no further ways
+	wayNodes map[int64]bool
+}
+
+// returns a new filter for the bounding box which passes the extract to
+// next, call NextPass() before every pass
+func NewExtractFilter(bb bbox.BBox, s ExtractStrategy, next OSMReader) *ExtractFilter {
+	return newExtractFilter(newExtractSet(bb, nil, s), next)
+}
+
+func newExtractFilter(e *extractSet, next OSMReader) *ExtractFilter {
+	return &ExtractFilter{
+		set:           e,
+		next:          next,
+		complete:      make(map[int64]bool),
+		completeNodes: make(map[int64]bool),
+		wayNodes:      make(map[int64]bool),
+	}
+}
+
+// returns a new filter for the area of the multipolygon, see
+// NewExtractFilter()
+func NewPolygonExtractFilter(mp polygon.MultiPolygon, s ExtractStrategy, next OSMReader) (*ExtractFilter, error) {
+	e, err := polygonSet(mp, s)
+	if err != nil {
+		return nil, err
+	}
+	return newExtractFilter(e, next), nil
+}
+
+// the number of passes over the input needed by the strategy
+func (f *ExtractFilter) Passes() int {
+	switch f.set.strategy {
+	case ExtractCompleteWays:
+		return 2
+	case ExtractSmart:
+		return 3
+	}
+	return 1
+}
+
+// starts the next pass, returns false after the last one
+func (f *ExtractFilter) NextPass() bool {
+	if f.pass >= f.Passes() {
+		return false
+	}
+	if f.pass == 1 {
+		f.addWayNodes()
+	}
+	f.pass++
+	return true
+}
+
+// adds the nodes of the selected ways to the set, once all ways of the
+// first pass are read
+func (f *ExtractFilter) addWayNodes() {
+	for id := range f.wayNodes {
+		f.set.nodes[id] = true
+	}
+	f.wayNodes = nil
+}
+
+func (f *ExtractFilter) last() bool {
+	return f.pass >= f.Passes()
+}
+
+// implements the OSMReader interface
+func (f *ExtractFilter) ReadBounds(b *bbox.BBox) bool {
+	if !f.last() {
+		return true
+	}
+	bb := cloneBBox(f.set.bb)
+	return f.next.ReadBounds(&bb)
+}
+
+// implements the OSMReader interface
+func (f *ExtractFilter) ReadNode(n *node.Node) bool {
+	if f.pass == 1 && f.set.inside(n.Position_) {
+		f.set.nodes[n.Id_] = true
+	}
+	if f.last() && (f.set.nodes[n.Id_] || f.completeNodes[n.Id_]) {
+		return f.next.ReadNode(n)
+	}
+	return true
+}
+
+// implements the OSMReader interface
+func (f *ExtractFilter) ReadWay(w *way.Way) bool {
+	switch {
+	case f.pass == 1 && f.set.touches(w):
+		f.set.ways[w.Id_] = true
+		if !f.last() {
+			for _, id := range wayNodeIds(w) {
+				f.wayNodes[id] = true
+			}
+		}
+	case f.pass == 2 && f.complete[w.Id_]:
+		for _, id := range wayNodeIds(w) {
+			f.completeNodes[id] = true
+		}
+	}
+	if f.last() && (f.set.ways[w.Id_] || f.complete[w.Id_]) {
+		return f.next.ReadWay(w)
+	}
+	return true
+}
+
+// implements the OSMReader interface
+func (f *ExtractFilter) ReadRelation(r *relation.Relation) bool {
+	if f.pass == 1 && f.wayNodes != nil {
+		f.addWayNodes()
+	}
+	if !f.set.relations[r.Id_] && f.set.referenced(r) {
+		f.set.relations[r.Id_] = true
+		if f.pass == 1 {
+			for _, id := range f.set.completion(r) {
+				f.complete[id] = true
+			}
+		}
+	}
+	if f.last() && f.set.relations[r.Id_] {
+		return f.next.ReadRelation(r)
+	}
+	return true
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,286 @@
+package osm
+
+import (
+	"fmt"
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/user"
+	"github.com/brechtvm/osm/way"
+	"sort"
+	"testing"
+	"time"
+)
+
+var extractBox = bbox.BBox{LowerLeft: point.New(50, 4), UpperRight: point.New(51, 5)}
+
+func testRelation(id int64, kv []string, ml ...*relation.Member) *relation.Relation {
+	t := tags.New()
+	for i := 0; i+1 < len(kv); i += 2 {
+		t.Add(kv[i], kv[i+1])
+	}
+	return &relation.Relation{Id_: id, Tags_: t, Members_: ml, Version_: 1, Visible_: true}
+}
+
+// the nodes 1 and 2 are inside extractBox, way 10 leaves the box to node 3
+// and multipolygon 20 has the outer ways 10 and 12. The relations 21, 22
+// and 24 refer to a node of way 10, to the completed way 12 and to one of
+// its nodes, 23 refers to 20.
+func extractOSM() *OSM {
+	o := NewOSM(nil)
+	o.AddNode(testNode(1, 50.5, 4.5))
+	o.AddNode(testNode(2, 50.6, 4.6))
+	o.AddNode(testNode(3, 52.0, 6.0))
+	o.AddNode(testNode(4, 52.1, 6.1))
+	o.AddNode(testNode(5, 52.2, 6.2))
+	for _, w := range []*way.Way{testWay(10, []int64{1, 3}), testWay(11, []int64{3, 4}), testWay(12, []int64{3, 4, 5, 3})} {
+		for _, id := range w.NodeIDs {
+			w.Nodes_ = append(w.Nodes_, o.Nodes[id])
+		}
+		o.AddWay(w)
+	}
+	member := func(t item.ItemType, id int64, role string) *relation.Member {
+		return &relation.Member{Type_: t, Id_: id, Role: role}
+	}
+	o.AddRelation(testRelation(20, []string{"type", "multipolygon"}, member(item.TypeWay, 10, "outer"), member(item.TypeWay, 12, "outer")))
+	o.AddRelation(testRelation(21, nil, member(item.TypeNode, 3, "")))
+	o.AddRelation(testRelation(22, nil, member(item.TypeWay, 12, "")))
+	o.AddRelation(testRelation(23, nil, member(item.TypeRelation, 20, "")))
+	o.AddRelation(testRelation(24, nil, member(item.TypeNode, 5, "")))
+	return o
+}
+
+// the sorted ids of the items of an OSM
+type extractIds struct {
+	nodes, ways, relations []int64
+}
+
+func (e *extractIds) ReadBounds(b *bbox.BBox) bool { return true }
+func (e *extractIds) ReadNode(n *node.Node) bool {
+	e.nodes = append(e.nodes, n.Id_)
+	return true
+}
+func (e *extractIds) ReadWay(w *way.Way) bool {
+	e.ways = append(e.ways, w.Id_)
+	return true
+}
+func (e *extractIds) ReadRelation(r *relation.Relation) bool {
+	e.relations = append(e.relations, r.Id_)
+	return true
+}
+
+func (e *extractIds) String() string {
+	return fmt.Sprintf("nodes %v, ways %v, relations %v", e.nodes, e.ways, e.relations)
+}
+
+func idsOf(o *OSM) *extractIds {
+	e := &extractIds{}
+	for n := range o.SortedNodes() {
+		e.ReadNode(n)
+	}
+	for w := range o.SortedWays() {
+		e.ReadWay(w)
+	}
+	for r := range o.SortedRelations() {
+		e.ReadRelation(r)
+	}
+	return e
+}
+
+// passes the items of o sorted by type and id to f, like a sorted file
+func stream(o *OSM, f *ExtractFilter) *extractIds {
+	for f.NextPass() {
+		for n := range o.SortedNodes() {
+			f.ReadNode(n)
+		}
+		for w := range o.SortedWays() {
+			f.ReadWay(w)
+		}
+		for r := range o.SortedRelations() {
+			f.ReadRelation(r)
+		}
+	}
+	e := f.next.(*extractIds)
+	for _, l := range [][]int64{e.nodes, e.ways, e.relations} {
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	}
+	return e
+}
+
+// the in memory and the streaming extract select the same items
+func TestExtractStrategies(t *testing.T) {
+	for _, c := range []struct {
+		s    ExtractStrategy
+		want string
+	}{
+		{ExtractSimple, "nodes [1 2], ways [10], relations [20 23]"},
+		{ExtractCompleteWays, "nodes [1 2 3], ways [10], relations [20 21 23]"},
+		{ExtractSmart, "nodes [1 2 3 4 5], ways [10 12], relations [20 21 23]"},
+	} {
+		o := extractOSM()
+		if got := idsOf(o.ExtractBBox(extractBox, c.s)).String(); got != c.want {
+			t.Errorf("%s ExtractBBox: %s, want %s", c.s, got, c.want)
+		}
+		if got := stream(o, NewExtractFilter(extractBox, c.s, &extractIds{})).String(); got != c.want {
+			t.Errorf("%s ExtractFilter: %s, want %s", c.s, got, c.want)
+		}
+	}
+}
+
+func TestExtractUsers(t *testing.T) {
+	o := extractOSM()
+	t1 := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	o.Users = map[uint32]*user.User{7: user.New(7, "a"), 8: user.New(8, "b")}
+	o.Timestamps = map[string]time.Time{fmt.Sprintf("%s", t1): t1, fmt.Sprintf("%s", t2): t2}
+	o.Nodes[1].User_, o.Nodes[1].Timestamp_ = user.New(7, "a"), t1
+	o.Nodes[4].User_, o.Nodes[4].Timestamp_ = user.New(8, "b"), t2
+
+	x := o.ExtractBBox(extractBox, ExtractSimple)
+	if len(x.Users) != 1 || x.Users[7] == nil {
+		t.Errorf("users %v, want only user 7", x.Users)
+	}
+	if len(x.Timestamps) != 1 || !x.Timestamps[fmt.Sprintf("%s", t1)].Equal(t1) {
+		t.Errorf("timestamps %v, want only %s", x.Timestamps, t1)
+	}
+	x = o.ExtractBBox(extractBox, ExtractSmart)
+	if len(x.Users) != 2 || len(x.Timestamps) != 2 {
+		t.Errorf("%d users and %d timestamps in the smart extract, want 2", len(x.Users), len(x.Timestamps))
+	}
+}
+
+// an L shaped outline over extractBox without its upper right quarter,
+// with a hole at 50.1..50.3, 4.1..4.3
+var extractL = polygon.MultiPolygon{polygon.New(
+	polygon.Ring{point.New(50, 4), point.New(50, 5), point.New(50.5, 5), point.New(50.5, 4.5), point.New(51, 4.5), point.New(51, 4), point.New(50, 4)},
+	polygon.Ring{point.New(50.1, 4.1), point.New(50.1, 4.3), point.New(50.3, 4.3), point.New(50.3, 4.1), point.New(50.1, 4.1)},
+)}
+
+// the nodes 1, 4 and 5 are inside extractL, 2 is in the hole, 3 is in the
+// cut off quarter and 6 is outside of extractBox. Way 10 leaves the area
+// to the hole, way 11 goes from the hole to the quarter and way 12 from
+// the quarter out of the box. Relation 20 refers to node 3, 21 to way 13,
+// 22 to way 11. The area relation 30 has the outline (way 40, nodes
+// 101..106) and the hole (way 41, nodes 111..114), its ways have only
+// NodeIDs.
+func extractPolygonOSM() *OSM {
+	o := NewOSM(nil)
+	for _, n := range []*node.Node{
+		testNode(1, 50.05, 4.05), testNode(2, 50.2, 4.2), testNode(3, 50.8, 4.8),
+		testNode(4, 50.8, 4.2), testNode(5, 50.2, 4.8), testNode(6, 52, 6),
+	} {
+		o.AddNode(n)
+	}
+	for _, w := range []*way.Way{testWay(10, []int64{1, 2}), testWay(11, []int64{2, 3}), testWay(12, []int64{3, 6}), testWay(13, []int64{4, 5})} {
+		for _, id := range w.NodeIDs {
+			w.Nodes_ = append(w.Nodes_, o.Nodes[id])
+		}
+		o.AddWay(w)
+	}
+	member := func(t item.ItemType, id int64, role string) *relation.Member {
+		return &relation.Member{Type_: t, Id_: id, Role: role}
+	}
+	o.AddRelation(testRelation(20, nil, member(item.TypeNode, 3, "")))
+	o.AddRelation(testRelation(21, nil, member(item.TypeWay, 13, "")))
+	o.AddRelation(testRelation(22, nil, member(item.TypeWay, 11, "")))
+
+	for i, r := range extractL[0].Outer[:6] {
+		o.AddNode(testNode(int64(101+i), r.Lat, r.Lon))
+	}
+	for i, r := range extractL[0].Inners[0][:4] {
+		o.AddNode(testNode(int64(111+i), r.Lat, r.Lon))
+	}
+	o.AddWay(testWay(40, []int64{101, 102, 103, 104, 105, 106, 101}))
+	o.AddWay(testWay(41, []int64{111, 112, 113, 114, 111}))
+	outer, inner := member(item.TypeWay, 40, "outer"), member(item.TypeWay, 41, "inner")
+	outer.Ref, inner.Ref = o.Ways[40], o.Ways[41]
+	o.AddRelation(testRelation(30, []string{"type", "boundary"}, outer, inner))
+	return o
+}
+
+// the ids below 30 only, the nodes on the boundary of the relation may be
+// inside or outside
+func (e *extractIds) below30() string {
+	keep := func(ids []int64) []int64 {
+		var k []int64
+		for _, id := range ids {
+			if id < 30 {
+				k = append(k, id)
+			}
+		}
+		return k
+	}
+	return (&extractIds{keep(e.nodes), keep(e.ways), keep(e.relations)}).String()
+}
+
+// the polygon extracts drop the items in the hole and in the cut off
+// quarter, which the extract of the bounding box has
+func TestExtractPolygon(t *testing.T) {
+	for _, c := range []struct {
+		s       ExtractStrategy
+		box     string
+		polygon string
+	}{
+		{ExtractSimple, "nodes [1 2 3 4 5], ways [10 11 12 13], relations [20 21 22]", "nodes [1 4 5], ways [10 13], relations [21]"},
+		{ExtractCompleteWays, "nodes [1 2 3 4 5 6], ways [10 11 12 13], relations [20 21 22]", "nodes [1 2 4 5], ways [10 13], relations [21]"},
+	} {
+		o := extractPolygonOSM()
+		if got := idsOf(o.ExtractBBox(extractBox, c.s)).below30(); got != c.box {
+			t.Errorf("%s ExtractBBox: %s, want %s", c.s, got, c.box)
+		}
+		x, err := o.ExtractPolygon(extractL, c.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := idsOf(x).below30(); got != c.polygon {
+			t.Errorf("%s ExtractPolygon: %s, want %s", c.s, got, c.polygon)
+		}
+		if !x.BBox.LowerLeft.Equal(extractBox.LowerLeft) || !x.BBox.UpperRight.Equal(extractBox.UpperRight) {
+			t.Errorf("%s ExtractPolygon: bounds %v, want %v", c.s, x.BBox, extractBox)
+		}
+		f, err := NewPolygonExtractFilter(extractL, c.s, &extractIds{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := stream(o, f).below30(); got != c.polygon {
+			t.Errorf("%s polygon ExtractFilter: %s, want %s", c.s, got, c.polygon)
+		}
+
+		x, err = o.ExtractRelation(30, c.s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := idsOf(x).below30(); got != c.polygon {
+			t.Errorf("%s ExtractRelation: %s, want %s", c.s, got, c.polygon)
+		}
+		if len(o.Ways[40].Nodes_) != 0 || len(o.Ways[41].Nodes_) != 0 {
+			t.Errorf("%s ExtractRelation: member ways of the relation resolved", c.s)
+		}
+	}
+}
+
+func TestExtractPolygonErrors(t *testing.T) {
+	o := extractPolygonOSM()
+	if _, err := o.ExtractRelation(31, ExtractSimple); err == nil {
+		t.Error("no error for a missing relation")
+	}
+	if _, err := o.ExtractRelation(20, ExtractSimple); err == nil {
+		t.Error("no error for a relation which is not an area")
+	}
+	delete(o.Nodes, 113)
+	if _, err := o.ExtractRelation(30, ExtractSimple); err == nil {
+		t.Error("no error for a relation with a missing node")
+	}
+	if _, err := o.ExtractPolygon(polygon.MultiPolygon{}, ExtractSimple); err == nil {
+		t.Error("no error for an empty multipolygon")
+	}
+	if _, err := NewPolygonExtractFilter(polygon.MultiPolygon{}, ExtractSimple, &extractIds{}); err == nil {
+		t.Error("no error for an empty multipolygon")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -9,21 +9,18 @@ import (
 
 func (o *OSM) FilterTags(tl ...*tags.Tags) *OSM {
 	no := NewOSM(nil)
-	for id := range o.Nodes {
-		n := o.GetNode(id)
-		if n == nil {
-			continue
-		}
+	for n := range o.AllNodes() {
 		if n.Tags() == nil {
 			continue
 		}
+		id := n.Id_
 
 		nt := map[string]string(*n.Tags())
 	findNodeTags:
 		for _, t := range tl {
 			for k := range nt {
 				if t.Has(k) && t.Get(k) == nt[k] {
-					no.Nodes[id] = n
+					no.Nodes[id] = o.GetNode(id)
 					break findNodeTags
 				}
 			}
@@ -45,8 +42,9 @@ func (o *OSM) FilterTags(tl ...*tags.Tags) *OSM {
 			for k := range nt {
 				if t.Has(k) && t.Get(k) == nt[k] {
 					no.Ways[id] = n
+					o.ResolveWay(n)
 					for _, nd := range n.GetNodes() {
-						no.Nodes[n.Id_] = nd
+						no.Nodes[nd.Id_] = nd
 					}
 					break findWayTags
 				}
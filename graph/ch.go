@@ -0,0 +1,427 @@
+package graph
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/point"
+	"math"
+	"sort"
+)
+
+// the number of vertices a witness search may settle before it gives up,
+// a lower limit makes the contraction faster but adds more shortcuts
+var WitnessLimit = 500
+
+// an edge of a contraction hierarchy
+type CHEdge struct {
+	From, To int // vertex indexes
+	Weight   float64
+	// the two edges a shortcut replaces, -1 for the edges of the graph
+	Skip1, Skip2 int
+}
+
+// checks if the edge is a shortcut
+func (e *CHEdge) Shortcut() bool {
+	return e.Skip1 != -1
+}
+
+// A contraction hierarchy of a graph for one metric. The vertices are
+// contracted one by one in the order of Rank, the shortcuts keep the
+// distances between the remaining vertices. A query searches upwards in
+// the hierarchy from both ends. A hierarchy can only be built for a graph
+// without turn restrictions, see Contract.
+type CH struct {
+	Graph  *Graph
+	Metric Metric
+	// the contraction order of the vertices
+	Rank []int
+	// the edges of the graph, with the same ids, followed by the shortcuts
+	Edges []CHEdge
+	// per vertex the edges to and from vertices of a higher rank
+	up, down [][]int
+}
+
+// the state of the contraction
+type contractor struct {
+	ch         *CH
+	out, in    [][]int // edge ids per vertex, to and from remaining vertices
+	contracted []bool
+	// the number of contracted neighbours per vertex
+	deleted []int
+	// the state of the witness searches, an entry is valid if its stamp
+	// is the stamp of the current search
+	dist    []float64
+	stamp   []int
+	settled []int
+	search  int
+	q       queue
+}
+
+// a neighbour of a vertex and the cheapest edge to or from it
+type neighbour struct {
+	vertex, edge int
+}
+
+// Builds the contraction hierarchy of the graph. The vertices are ordered
+// by the number of shortcuts their contraction adds minus the edges it
+// removes, and by the number of contracted neighbours.
+//
+// An error is returned if the graph has turn restrictions, as the queries
+// would not enforce them. Use a profile without Vehicles to build a graph
+// which ignores turn restrictions.
+func Contract(g *Graph, m Metric) (*CH, error) {
+	if err := g.checkNoTurns(); err != nil {
+		return nil, err
+	}
+	n := len(g.Vertices)
+	c := &CH{Graph: g, Metric: m, Rank: make([]int, n)}
+	ct := &contractor{ch: c, out: make([][]int, n), in: make([][]int, n), contracted: make([]bool, n), deleted: make([]int, n), dist: make([]float64, n), stamp: make([]int, n), settled: make([]int, n)}
+	for i := range g.Edges {
+		e := &g.Edges[i]
+		c.Edges = append(c.Edges, CHEdge{From: e.From, To: e.To, Weight: g.weight(e, m), Skip1: -1, Skip2: -1})
+		if e.From != e.To {
+			ct.out[e.From] = append(ct.out[e.From], i)
+			ct.in[e.To] = append(ct.in[e.To], i)
+		}
+	}
+	q := &queue{}
+	for v := 0; v < n; v++ {
+		heap.Push(q, queued{vertex: v, key: ct.priority(v)})
+	}
+	for rank := 0; q.Len() > 0; {
+		v := heap.Pop(q).(queued).vertex
+		// the priorities change as neighbours are contracted, they are
+		// updated when a vertex comes out of the queue
+		if p := ct.priority(v); q.Len() > 0 && p > (*q)[0].key {
+			heap.Push(q, queued{vertex: v, key: p})
+			continue
+		}
+		ct.shortcuts(v, true)
+		ct.contract(v)
+		c.Rank[v] = rank
+		rank++
+	}
+	c.index()
+	return c, nil
+}
+
+// a contraction hierarchy cannot enforce turn restrictions
+func (g *Graph) checkNoTurns() error {
+	if g.turns != nil {
+		return errors.New(fmt.Sprintf("Cannot contract a graph with %d turn restrictions", len(g.Restrictions)))
+	}
+	return nil
+}
+
+// marks v as contracted and drops the edges to and from it from the
+// lists of its neighbours
+func (ct *contractor) contract(v int) {
+	ct.contracted[v] = true
+	remaining := func(edges []int, out bool) []int {
+		l := edges[:0]
+		for _, id := range edges {
+			e := &ct.ch.Edges[id]
+			if (out && !ct.contracted[e.To]) || (!out && !ct.contracted[e.From]) {
+				l = append(l, id)
+			}
+		}
+		return l
+	}
+	for _, nb := range ct.neighbours(v, false) {
+		ct.deleted[nb.vertex]++
+		ct.out[nb.vertex] = remaining(ct.out[nb.vertex], true)
+	}
+	for _, nb := range ct.neighbours(v, true) {
+		ct.deleted[nb.vertex]++
+		ct.in[nb.vertex] = remaining(ct.in[nb.vertex], false)
+	}
+	ct.out[v], ct.in[v] = nil, nil
+}
+
+// fills up and down from the edges and the ranks
+func (c *CH) index() {
+	n := len(c.Rank)
+	c.up, c.down = make([][]int, n), make([][]int, n)
+	for i := range c.Edges {
+		e := &c.Edges[i]
+		switch {
+		case e.From == e.To:
+		case c.Rank[e.From] < c.Rank[e.To]:
+			c.up[e.From] = append(c.up[e.From], i)
+		default:
+			c.down[e.To] = append(c.down[e.To], i)
+		}
+	}
+}
+
+// the remaining neighbours of v with the cheapest edge, sorted by vertex
+func (ct *contractor) neighbours(v int, out bool) []neighbour {
+	edges := ct.in[v]
+	if out {
+		edges = ct.out[v]
+	}
+	best := make(map[int]int)
+	for _, id := range edges {
+		e := &ct.ch.Edges[id]
+		u := e.From
+		if out {
+			u = e.To
+		}
+		if ct.contracted[u] {
+			continue
+		}
+		if b, ok := best[u]; !ok || e.Weight < ct.ch.Edges[b].Weight {
+			best[u] = id
+		}
+	}
+	nl := make([]neighbour, 0, len(best))
+	for u, id := range best {
+		nl = append(nl, neighbour{vertex: u, edge: id})
+	}
+	sort.Slice(nl, func(i, j int) bool { return nl[i].vertex < nl[j].vertex })
+	return nl
+}
+
+func (ct *contractor) priority(v int) float64 {
+	removed := len(ct.neighbours(v, false)) + len(ct.neighbours(v, true))
+	return float64(ct.shortcuts(v, false) - removed + ct.deleted[v])
+}
+
+// Returns the number of shortcuts needed to contract v, they are added if
+// add is set. A shortcut u-v-x is needed if the witness search finds no
+// path from u to x around v which is as cheap.
+func (ct *contractor) shortcuts(v int, add bool) int {
+	edges := ct.ch.Edges
+	ins, outs := ct.neighbours(v, false), ct.neighbours(v, true)
+	count := 0
+	for _, in := range ins {
+		w1 := edges[in.edge].Weight
+		max := -1.0
+		for _, out := range outs {
+			if out.vertex != in.vertex {
+				max = math.Max(max, w1+edges[out.edge].Weight)
+			}
+		}
+		if max < 0 {
+			continue
+		}
+		ct.witness(in.vertex, v, max, outs)
+		for _, out := range outs {
+			if out.vertex == in.vertex {
+				continue
+			}
+			w := w1 + ct.ch.Edges[out.edge].Weight
+			if ct.stamp[out.vertex] == ct.search && ct.dist[out.vertex] <= w {
+				continue
+			}
+			count++
+			if add {
+				id := len(ct.ch.Edges)
+				ct.ch.Edges = append(ct.ch.Edges, CHEdge{From: in.vertex, To: out.vertex, Weight: w, Skip1: in.edge, Skip2: out.edge})
+				ct.out[in.vertex] = append(ct.out[in.vertex], id)
+				ct.in[out.vertex] = append(ct.in[out.vertex], id)
+			}
+		}
+	}
+	return count
+}
+
+// Searches the distances from u to the remaining vertices up to max,
+// avoiding v, until the targets are settled. The distances are in dist
+// for the vertices with the current stamp.
+func (ct *contractor) witness(u, v int, max float64, targets []neighbour) {
+	ct.search++
+	ct.dist[u], ct.stamp[u] = 0, ct.search
+	left := len(targets)
+	q := &ct.q
+	*q = append((*q)[:0], queued{vertex: u})
+	for n := 0; q.Len() > 0 && n < WitnessLimit && left > 0; n++ {
+		x := heap.Pop(q).(queued)
+		if ct.settled[x.vertex] == ct.search {
+			continue
+		}
+		if x.key > max {
+			break
+		}
+		ct.settled[x.vertex] = ct.search
+		for _, t := range targets {
+			if t.vertex == x.vertex {
+				left--
+			}
+		}
+		for _, id := range ct.out[x.vertex] {
+			e := &ct.ch.Edges[id]
+			if e.To == v || ct.contracted[e.To] {
+				continue
+			}
+			if d := x.key + e.Weight; ct.stamp[e.To] != ct.search || d < ct.dist[e.To] {
+				ct.dist[e.To], ct.stamp[e.To] = d, ct.search
+				heap.Push(q, queued{vertex: e.To, key: d})
+			}
+		}
+	}
+}
+
+// returns the ids of the graph edges a hierarchy edge stands for
+func (c *CH) Unpack(id int) []int {
+	var el []int
+	stack := []int{id}
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if e := &c.Edges[id]; e.Shortcut() {
+			stack = append(stack, e.Skip2, e.Skip1)
+		} else {
+			el = append(el, id)
+		}
+	}
+	return el
+}
+
+// Searches the best route between two positions which are snapped to the
+// nearest routable ways (see DefaultSnapRadius).
+func (c *CH) Route(from, to *point.Point) (*Route, error) {
+	s, err := c.Graph.Snap(from, DefaultSnapRadius)
+	if err != nil {
+		return nil, err
+	}
+	t, err := c.Graph.Snap(to, DefaultSnapRadius)
+	if err != nil {
+		return nil, err
+	}
+	return c.RouteLocations(s, t)
+}
+
+// searches the best route between two snapped locations
+func (c *CH) RouteLocations(from, to *Location) (*Route, error) {
+	g := c.Graph
+	sources, targets, best, result := g.seeds(from, to, c.Metric)
+	if p, cost := c.search(sources, targets); p != nil && cost < best {
+		result = p
+	}
+	if result == nil {
+		return nil, errors.New("No route found")
+	}
+	return g.route(from, to, result), nil
+}
+
+// one direction of a query, it only visits the vertices it reaches
+type chFrontier struct {
+	ch       *CH
+	backward bool
+	dist     map[int]float64
+	pred     map[int]int // edge to the vertex, -1 for seeds
+	seedPos  map[int]edgePos
+	settled  map[int]bool
+	q        *queue
+}
+
+func (c *CH) newFrontier(backward bool, seeds []seed) *chFrontier {
+	f := &chFrontier{ch: c, backward: backward, dist: make(map[int]float64), pred: make(map[int]int), seedPos: make(map[int]edgePos), settled: make(map[int]bool), q: &queue{}}
+	for _, s := range seeds {
+		if d, ok := f.dist[s.vertex]; !ok || s.cost < d {
+			f.dist[s.vertex], f.pred[s.vertex] = s.cost, -1
+			f.seedPos[s.vertex] = s.pos
+			heap.Push(f.q, queued{vertex: s.vertex, key: s.cost})
+		}
+	}
+	return f
+}
+
+// the smallest distance in the queue, +Inf if it is empty
+func (f *chFrontier) top() float64 {
+	for f.q.Len() > 0 {
+		if x := (*f.q)[0]; !f.settled[x.vertex] {
+			return x.key
+		}
+		heap.Pop(f.q)
+	}
+	return math.Inf(1)
+}
+
+// settles the next vertex and relaxes its edges upwards, relaxed is called
+// for every improved vertex
+func (f *chFrontier) step(relaxed func(v int)) {
+	v := heap.Pop(f.q).(queued).vertex
+	f.settled[v] = true
+	edges := f.ch.up[v]
+	if f.backward {
+		edges = f.ch.down[v]
+	}
+	for _, id := range edges {
+		e := &f.ch.Edges[id]
+		w := e.To
+		if f.backward {
+			w = e.From
+		}
+		if d, ok := f.dist[w]; !ok || f.dist[v]+e.Weight < d {
+			f.dist[w], f.pred[w] = f.dist[v]+e.Weight, id
+			delete(f.seedPos, w)
+			heap.Push(f.q, queued{vertex: w, key: f.dist[w]})
+			relaxed(w)
+		}
+	}
+}
+
+// the unpacked graph edges from the seed to v, in the direction of travel
+func (f *chFrontier) edges(v int) ([]int, edgePos) {
+	var hl []int
+	for f.pred[v] != -1 {
+		e := &f.ch.Edges[f.pred[v]]
+		hl = append(hl, f.pred[v])
+		if f.backward {
+			v = e.To
+		} else {
+			v = e.From
+		}
+	}
+	if !f.backward {
+		for i, j := 0, len(hl)-1; i < j; i, j = i+1, j-1 {
+			hl[i], hl[j] = hl[j], hl[i]
+		}
+	}
+	var el []int
+	for _, id := range hl {
+		el = append(el, f.ch.Unpack(id)...)
+	}
+	return el, f.seedPos[v]
+}
+
+// Searches upwards from both ends. A direction stops once its smallest
+// distance exceeds the best route found, the best route meets at the
+// vertex of the highest rank on it.
+func (c *CH) search(sources, targets []seed) (*path, float64) {
+	ff := c.newFrontier(false, sources)
+	fb := c.newFrontier(true, targets)
+	best, meet := math.Inf(1), -1
+	check := func(v int) {
+		df, okf := ff.dist[v]
+		db, okb := fb.dist[v]
+		if okf && okb && df+db < best {
+			best, meet = df+db, v
+		}
+	}
+	for _, s := range sources {
+		check(s.vertex)
+	}
+	for {
+		tf, tb := ff.top(), fb.top()
+		if tf >= best && tb >= best {
+			break
+		}
+		if tf <= tb {
+			ff.step(check)
+		} else {
+			fb.step(check)
+		}
+	}
+	if meet == -1 {
+		return nil, best
+	}
+	fe, first := ff.edges(meet)
+	be, last := fb.edges(meet)
+	return &path{first: first, last: last, edges: append(fe, be...)}, best
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/brechtvm/osm/tags"
+	"slices"
+	"testing"
+)
+
+func TestCHAlgorithms(t *testing.T) {
+	g, err := New(grid(8, mixedTags), Car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []Metric{Shortest, Fastest} {
+		ch, err := Contract(g, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pq := range gridRoutes {
+			want, err := g.Route(pq[0], pq[1], m, Dijkstra)
+			if err != nil {
+				t.Fatalf("%v %v to %v: %v", m, pq[0], pq[1], err)
+			}
+			r, err := ch.Route(pq[0], pq[1])
+			if err != nil {
+				t.Errorf("%v contraction hierarchy %v to %v: %v", m, pq[0], pq[1], err)
+			} else if !sameCost(cost(r, m), cost(want, m)) {
+				t.Errorf("%v contraction hierarchy %v to %v costs %f, Dijkstra %f", m, pq[0], pq[1], cost(r, m), cost(want, m))
+			}
+		}
+	}
+}
+
+func TestCHFile(t *testing.T) {
+	g, err := New(grid(8, mixedTags), Car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := Contract(g, Fastest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = ch.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	read, err := ReadCH(bytes.NewReader(data), g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.Metric != Fastest || !slices.Equal(read.Rank, ch.Rank) || len(read.Edges) != len(ch.Edges) {
+		t.Fatalf("read %v hierarchy with %d edges, want %v with %d", read.Metric, len(read.Edges), ch.Metric, len(ch.Edges))
+	}
+	for _, pq := range gridRoutes {
+		want, err := ch.Route(pq[0], pq[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := read.Route(pq[0], pq[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(r.Edges, want.Edges) {
+			t.Errorf("%v to %v: edges %v, want %v", pq[0], pq[1], r.Edges, want.Edges)
+		}
+	}
+
+	// the same grid with other speeds
+	other, err := New(grid(8, func(id int64) tags.Tags { return tags.Tags{"highway": "primary"} }), Car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadCH(bytes.NewReader(data), other); err == nil {
+		t.Error("no error for a hierarchy of another graph")
+	}
+}
+
+// hierarchy files with a wrong shortcut count, ranks which are not a
+// permutation and a shortcut which does not join its skipped edges
+func TestCHFileCorrupt(t *testing.T) {
+	g, err := New(grid(4, mixedTags), Car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch, err := Contract(g, Shortest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = ch.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	hdSize := binary.Size(chHeader{})
+	rankAt := func(v int) int { return hdSize + 4*v }
+	shortcutAt := func(i int) int { return rankAt(len(ch.Rank)) + i*binary.Size(chShortcut{}) }
+	if len(ch.Edges) == len(g.Edges) {
+		t.Fatal("no shortcuts")
+	}
+	for _, c := range []struct {
+		name   string
+		change func(b []byte)
+	}{
+		{"shortcut count", func(b []byte) { binary.LittleEndian.PutUint32(b[20:], 1<<31) }},
+		{"repeated rank", func(b []byte) { copy(b[rankAt(1):rankAt(2)], b[rankAt(0):rankAt(1)]) }},
+		{"rank out of range", func(b []byte) { binary.LittleEndian.PutUint32(b[rankAt(0):], uint32(len(ch.Rank))) }},
+		// the first shortcut skips itself
+		{"shortcut skips", func(b []byte) { binary.LittleEndian.PutUint32(b[shortcutAt(0)+16:], 0) }},
+	} {
+		b := bytes.Clone(buf.Bytes())
+		c.change(b)
+		if _, err := ReadCH(bytes.NewReader(b), g); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+	if _, err := ReadCH(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), g); err == nil {
+		t.Error("no error for a truncated file")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
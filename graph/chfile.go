@@ -0,0 +1,172 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+)
+
+// the first bytes of a contraction hierarchy file, the last one is the
+// version of the format
+var chMagic = [8]byte{'o', 's', 'm', '-', 'c', 'h', 0, 1}
+
+type chHeader struct {
+	Magic     [8]byte
+	Metric    uint32
+	Vertices  uint32
+	Edges     uint32 // of the graph
+	Shortcuts uint32
+	Checksum  uint64 // of the graph
+}
+
+// the number of shortcuts read at once
+const chReadChunk = 4096
+
+type chShortcut struct {
+	From, To     uint32
+	Weight       float64
+	Skip1, Skip2 uint32
+}
+
+// A checksum of the vertices and weighted edges of a graph, a hierarchy
+// can only be used with the graph it was built for.
+func (g *Graph) checksum(m Metric) uint64 {
+	h := fnv.New64a()
+	b := make([]byte, 8)
+	put := func(v uint64) {
+		binary.LittleEndian.PutUint64(b, v)
+		h.Write(b)
+	}
+	for _, v := range g.Vertices {
+		put(uint64(v.Node))
+	}
+	for i := range g.Edges {
+		e := &g.Edges[i]
+		put(uint64(e.From))
+		put(uint64(e.To))
+		put(uint64(e.Way))
+		put(math.Float64bits(g.weight(e, m)))
+	}
+	return h.Sum64()
+}
+
+// Writes the hierarchy in a binary format: the ranks and the shortcuts,
+// the edges of the graph are not stored. It is read back with ReadCH.
+func (c *CH) Write(w io.Writer) error {
+	g := c.Graph
+	bw := bufio.NewWriter(w)
+	hd := chHeader{Magic: chMagic, Metric: uint32(c.Metric), Vertices: uint32(len(c.Rank)), Edges: uint32(len(g.Edges)), Shortcuts: uint32(len(c.Edges) - len(g.Edges)), Checksum: g.checksum(c.Metric)}
+	if err := binary.Write(bw, binary.LittleEndian, &hd); err != nil {
+		return err
+	}
+	rank := make([]uint32, len(c.Rank))
+	for i, r := range c.Rank {
+		rank[i] = uint32(r)
+	}
+	if err := binary.Write(bw, binary.LittleEndian, rank); err != nil {
+		return err
+	}
+	sl := make([]chShortcut, 0, hd.Shortcuts)
+	for _, e := range c.Edges[len(g.Edges):] {
+		sl = append(sl, chShortcut{From: uint32(e.From), To: uint32(e.To), Weight: e.Weight, Skip1: uint32(e.Skip1), Skip2: uint32(e.Skip2)})
+	}
+	if err := binary.Write(bw, binary.LittleEndian, sl); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writes the hierarchy to the file path, see Write
+func (c *CH) WriteFile(path string) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = c.Write(fh); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// Reads a hierarchy written by Write for the graph g. An error is
+// returned if g differs from the graph the hierarchy was built for, e.g.
+// when it was built from other data or with another profile, or if g has
+// turn restrictions. The ranks must be a permutation of the vertices and
+// every shortcut must join its two skipped edges, the shortcuts are read
+// in chunks, so a wrong count in the header ends with an error at the end
+// of the input instead of a huge allocation.
+func ReadCH(r io.Reader, g *Graph) (*CH, error) {
+	if err := g.checkNoTurns(); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(r)
+	var hd chHeader
+	if err := binary.Read(br, binary.LittleEndian, &hd); err != nil {
+		return nil, err
+	}
+	if hd.Magic != chMagic {
+		return nil, errors.New("Not a contraction hierarchy file")
+	}
+	m := Metric(hd.Metric)
+	if int(hd.Vertices) != len(g.Vertices) || int(hd.Edges) != len(g.Edges) || hd.Checksum != g.checksum(m) {
+		return nil, errors.New(fmt.Sprintf("The contraction hierarchy (%d vertices, %d edges) was built for another graph", hd.Vertices, hd.Edges))
+	}
+	rank := make([]uint32, hd.Vertices)
+	if err := binary.Read(br, binary.LittleEndian, rank); err != nil {
+		return nil, err
+	}
+	c := &CH{Graph: g, Metric: m, Rank: make([]int, len(rank)), Edges: make([]CHEdge, 0, len(g.Edges))}
+	seen := make([]bool, len(rank))
+	for i, r := range rank {
+		if int(r) >= len(rank) || seen[r] {
+			return nil, errors.New(fmt.Sprintf("Invalid rank %d of vertex #%d", r, i))
+		}
+		seen[r] = true
+		c.Rank[i] = int(r)
+	}
+	for i := range g.Edges {
+		e := &g.Edges[i]
+		c.Edges = append(c.Edges, CHEdge{From: e.From, To: e.To, Weight: g.weight(e, m), Skip1: -1, Skip2: -1})
+	}
+	sl := make([]chShortcut, chReadChunk)
+	for left := int(hd.Shortcuts); left > 0; left -= len(sl) {
+		sl = sl[:min(left, chReadChunk)]
+		if err := binary.Read(br, binary.LittleEndian, sl); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, errors.New(fmt.Sprintf("Reading %d shortcuts: %s", hd.Shortcuts, err))
+		}
+		for _, s := range sl {
+			id := len(c.Edges)
+			if int(s.From) >= len(rank) || int(s.To) >= len(rank) || int(s.Skip1) >= id || int(s.Skip2) >= id {
+				return nil, errors.New(fmt.Sprintf("Invalid shortcut #%d", id))
+			}
+			e1, e2 := &c.Edges[s.Skip1], &c.Edges[s.Skip2]
+			if e1.From != int(s.From) || e1.To != e2.From || e2.To != int(s.To) {
+				return nil, errors.New(fmt.Sprintf("Shortcut #%d does not join the edges #%d and #%d", id, s.Skip1, s.Skip2))
+			}
+			c.Edges = append(c.Edges, CHEdge{From: int(s.From), To: int(s.To), Weight: s.Weight, Skip1: int(s.Skip1), Skip2: int(s.Skip2)})
+		}
+	}
+	c.index()
+	return c, nil
+}
+
+// reads a hierarchy for the graph g from the file path, see ReadCH
+func ReadCHFile(path string, g *Graph) (*CH, error) {
+	fh, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return ReadCH(fh, g)
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
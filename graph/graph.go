@@ -0,0 +1,182 @@
+// Package graph builds a directed routing graph from the highway ways of
+// an OSM and searches routes on it.
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/spatial"
+	"github.com/brechtvm/osm/way"
+	"sync"
+	"time"
+)
+
+// a vertex of the graph, an OSM node where ways meet or end
+type Vertex struct {
+	Node     int64
+	Position *point.Point
+}
+
+// A directed edge between two vertices along one way. The edge follows
+// the nodes of the way from FromOffset to ToOffset, these are indexes in
+// the node list of the way, ToOffset is less than FromOffset for an edge
+// against the direction of the way.
+type Edge struct {
+	Id         int
+	From, To   int // vertex indexes
+	Way        int64
+	FromOffset int
+	ToOffset   int
+	Length     distance.Distance
+	Duration   time.Duration
+}
+
+// checks if the edge runs against the direction of its way
+func (e *Edge) Backward() bool {
+	return e.ToOffset < e.FromOffset
+}
+
+// The routing graph. Vertices and edges are identified by their index in
+// Vertices and Edges. The ids are stable: building a graph from the same
+// data with the same profile gives the same ids, as the ways are
+// processed in id order, their parts in node order and forward edges
+// before backward ones.
+type Graph struct {
+	Profile  *Profile
+	Vertices []Vertex
+	Edges    []Edge
+	// outgoing and incoming edge ids per vertex
+	Out, In [][]int
+	// the turn restrictions which apply to the profile
+	Restrictions []*relation.Restriction
+	// the restriction relations which are invalid or which cannot be
+	// driven with the profile
+	RestrictionErrors []error
+	turns             *turns
+	vertexOf          map[int64]int
+	ways              map[int64]*way.Way
+	wayEdges          map[int64][]int
+	// the segment index for Snap(), built on first use
+	indexOnce sync.Once
+	index     *spatial.Index
+}
+
+// Builds the graph of the ways of o which are routable with the profile.
+// The turn restrictions of o which apply to the profile are enforced by
+// the route searches.
+//
+// For an OSM with a NodeStore this changes o: the nodes of the routable
+// ways and of the member ways of turn restrictions are resolved in place
+// (see OSM.ResolveWay). An error is returned if nodes of a routable way
+// are missing or have no position.
+func New(o *osm.OSM, p *Profile) (*Graph, error) {
+	g := &Graph{Profile: p, vertexOf: make(map[int64]int), ways: make(map[int64]*way.Way), wayEdges: make(map[int64][]int)}
+	var wl []*way.Way
+	uses := make(map[int64]int)
+	for w := range o.SortedWays() {
+		if !p.Routable(w) {
+			continue
+		}
+		if err := o.ResolveWay(w); err != nil {
+			return nil, err
+		}
+		if len(w.Nodes_) < 2 {
+			continue
+		}
+		for _, n := range w.Nodes_ {
+			if n.Position_ == nil {
+				return nil, errors.New(fmt.Sprintf("Node #%d in way #%d has no position", n.Id_, w.Id_))
+			}
+		}
+		wl = append(wl, w)
+		g.ways[w.Id_] = w
+		for _, n := range w.Nodes_ {
+			uses[n.Id_]++
+		}
+	}
+	for _, w := range wl {
+		forward, backward := p.Directions(w)
+		speed := p.Speed(w)
+		from := 0
+		var length distance.Distance
+		for i := 1; i < len(w.Nodes_); i++ {
+			length += w.Nodes_[i-1].Position_.DistanceOf(w.Nodes_[i].Position_)
+			if i < len(w.Nodes_)-1 && uses[w.Nodes_[i].Id_] < 2 {
+				continue
+			}
+			a, b := g.vertex(w.Nodes_[from]), g.vertex(w.Nodes_[i])
+			d := travelTime(length, speed)
+			if forward {
+				g.addEdge(Edge{From: a, To: b, Way: w.Id_, FromOffset: from, ToOffset: i, Length: length, Duration: d})
+			}
+			if backward {
+				g.addEdge(Edge{From: b, To: a, Way: w.Id_, FromOffset: i, ToOffset: from, Length: length, Duration: d})
+			}
+			from, length = i, 0
+		}
+	}
+	g.addRestrictions(o)
+	return g, nil
+}
+
+func travelTime(d distance.Distance, kmh float64) time.Duration {
+	return time.Duration(float64(d) / (kmh / 3.6) * float64(time.Second))
+}
+
+func (g *Graph) vertex(n *node.Node) int {
+	if v, ok := g.vertexOf[n.Id_]; ok {
+		return v
+	}
+	v := len(g.Vertices)
+	g.Vertices = append(g.Vertices, Vertex{Node: n.Id_, Position: n.Position_})
+	g.Out = append(g.Out, nil)
+	g.In = append(g.In, nil)
+	g.vertexOf[n.Id_] = v
+	return v
+}
+
+func (g *Graph) addEdge(e Edge) {
+	e.Id = len(g.Edges)
+	g.Edges = append(g.Edges, e)
+	g.Out[e.From] = append(g.Out[e.From], e.Id)
+	g.In[e.To] = append(g.In[e.To], e.Id)
+	g.wayEdges[e.Way] = append(g.wayEdges[e.Way], e.Id)
+}
+
+// returns the vertex index of an OSM node, false if the node is not a
+// vertex
+func (g *Graph) VertexOf(nodeId int64) (int, bool) {
+	v, ok := g.vertexOf[nodeId]
+	return v, ok
+}
+
+// returns a way of the graph
+func (g *Graph) Way(id int64) *way.Way {
+	return g.ways[id]
+}
+
+// returns the nodes along the edge, including both vertices
+func (g *Graph) EdgeNodes(id int) []*node.Node {
+	e := &g.Edges[id]
+	w := g.ways[e.Way]
+	if !e.Backward() {
+		return append([]*node.Node(nil), w.Nodes_[e.FromOffset:e.ToOffset+1]...)
+	}
+	nl := make([]*node.Node, 0, e.FromOffset-e.ToOffset+1)
+	for i := e.FromOffset; i >= e.ToOffset; i-- {
+		nl = append(nl, w.Nodes_[i])
+	}
+	return nl
+}
+
+// returns the ids of the edges along the way (in both directions)
+func (g *Graph) WayEdges(wayId int64) []int {
+	return g.wayEdges[wayId]
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
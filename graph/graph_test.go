@@ -0,0 +1,172 @@
+package graph
+
+import (
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// the grid spacing, about 111m in both directions at latitude 50
+const (
+	gridLat = 0.001
+	gridLon = 0.001556
+)
+
+// the position of row r and column c of the grid, rows go north
+func gridPoint(r, c float64) *point.Point {
+	return point.New(50+r*gridLat, 4+c*gridLon)
+}
+
+// the ids of the ways of the grid, a way per segment from row r and
+// column c to the east or north
+func eastWay(r, c int) int64  { return int64(1000 + 10*r + c) }
+func northWay(r, c int) int64 { return int64(2000 + 10*r + c) }
+
+// Returns a grid of size x size nodes with a way for every segment, the
+// tags of a way are set by wayTags.
+func grid(size int, wayTags func(id int64) tags.Tags) *osm.OSM {
+	o := osm.NewOSM(nil)
+	nodes := make([][]*node.Node, size)
+	for r := range nodes {
+		for c := 0; c < size; c++ {
+			t := tags.Tags{}
+			n := &node.Node{Id_: int64(r*size + c + 1), Position_: gridPoint(float64(r), float64(c)), Tags_: &t}
+			nodes[r] = append(nodes[r], n)
+			o.AddNode(n)
+		}
+	}
+	addWay := func(id int64, a, b *node.Node) {
+		t := wayTags(id)
+		o.AddWay(&way.Way{Id_: id, Nodes_: []*node.Node{a, b}, Tags_: &t})
+	}
+	for r := 0; r < size; r++ {
+		for c := 0; c < size; c++ {
+			if c+1 < size {
+				addWay(eastWay(r, c), nodes[r][c], nodes[r][c+1])
+			}
+			if r+1 < size {
+				addWay(northWay(r, c), nodes[r][c], nodes[r+1][c])
+			}
+		}
+	}
+	return o
+}
+
+// The ways of a small network on the equator, the nodes are 0.001 degrees
+// (one unit) of latitude or longitude apart:
+//
+//	         7
+//	         |
+//	         6    (way 2, oneway north)
+//	         |
+//	1 -- 2 -- 3 -- 4 -- 5    (way 1)
+func lineOSM() *osm.OSM {
+	o := osm.NewOSM(nil)
+	pos := map[int64][2]float64{1: {0, 0}, 2: {0, 1}, 3: {0, 2}, 4: {0, 3}, 5: {0, 4}, 6: {1, 2}, 7: {2, 2}}
+	for id, p := range pos {
+		t := tags.Tags{}
+		o.AddNode(&node.Node{Id_: id, Position_: point.New(p[0]*0.001, p[1]*0.001), Tags_: &t})
+	}
+	addWay := func(id int64, t tags.Tags, ids ...int64) {
+		var nl []*node.Node
+		for _, n := range ids {
+			nl = append(nl, o.GetNode(n))
+		}
+		o.AddWay(&way.Way{Id_: id, Nodes_: nl, NodeIDs: ids, Tags_: &t})
+	}
+	addWay(1, tags.Tags{"highway": "residential"}, 1, 2, 3, 4, 5)
+	addWay(2, tags.Tags{"highway": "residential", "oneway": "yes"}, 3, 6, 7)
+	return o
+}
+
+// the length of 0.001 degrees on a great circle
+var unit = distance.Distance(0.001*math.Pi/180) * distance.EarthRadius
+
+func sameDistance(a, b distance.Distance) bool {
+	return math.Abs(float64(a-b)) < 1e-6
+}
+
+func TestNew(t *testing.T) {
+	g, err := New(lineOSM(), Car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the ends of the ways and the shared node 3, not the nodes 2, 4 and 6
+	var vertices []int64
+	for _, v := range g.Vertices {
+		vertices = append(vertices, v.Node)
+	}
+	if want := []int64{1, 3, 5, 7}; !slices.Equal(vertices, want) {
+		t.Fatalf("vertices at the nodes %v, want %v", vertices, want)
+	}
+	residential := func(d distance.Distance) time.Duration { return travelTime(d, 30) }
+	want := []Edge{
+		{Id: 0, From: 0, To: 1, Way: 1, FromOffset: 0, ToOffset: 2, Length: 2 * unit},
+		{Id: 1, From: 1, To: 0, Way: 1, FromOffset: 2, ToOffset: 0, Length: 2 * unit},
+		{Id: 2, From: 1, To: 2, Way: 1, FromOffset: 2, ToOffset: 4, Length: 2 * unit},
+		{Id: 3, From: 2, To: 1, Way: 1, FromOffset: 4, ToOffset: 2, Length: 2 * unit},
+		// oneway, no backward edge
+		{Id: 4, From: 1, To: 3, Way: 2, FromOffset: 0, ToOffset: 2, Length: 2 * unit},
+	}
+	if len(g.Edges) != len(want) {
+		t.Fatalf("%d edges, want %d: %v", len(g.Edges), len(want), g.Edges)
+	}
+	for i, w := range want {
+		e := g.Edges[i]
+		if e.Id != w.Id || e.From != w.From || e.To != w.To || e.Way != w.Way || e.FromOffset != w.FromOffset || e.ToOffset != w.ToOffset {
+			t.Errorf("edge %d is %+v, want %+v", i, e, w)
+		}
+		if !sameDistance(e.Length, w.Length) {
+			t.Errorf("edge %d is %v long, want %v", i, e.Length, w.Length)
+		}
+		if d := residential(w.Length); (e.Duration - d).Abs() > time.Microsecond {
+			t.Errorf("edge %d takes %v, want %v", i, e.Duration, d)
+		}
+	}
+	var nodes []int64
+	for _, n := range g.EdgeNodes(3) {
+		nodes = append(nodes, n.Id_)
+	}
+	if want := []int64{5, 4, 3}; !slices.Equal(nodes, want) {
+		t.Errorf("nodes of edge 3 are %v, want %v", nodes, want)
+	}
+	if got, want := g.WayEdges(1), []int{0, 1, 2, 3}; !slices.Equal(got, want) {
+		t.Errorf("edges of way 1 are %v, want %v", got, want)
+	}
+}
+
+// a routable way with a node without a position, e.g. read in handler
+// mode without a location index, is an error, other ways may have one
+func TestNewWithoutPosition(t *testing.T) {
+	o := lineOSM()
+	o.GetNode(6).Position_ = nil
+	if g, err := New(o, Car); err == nil || g != nil {
+		t.Errorf("no error for node 6 without a position")
+	}
+	o.GetWay(2).Tags_.Add("highway", "construction")
+	if g, err := New(o, Car); err != nil || len(g.Edges) != 2 {
+		t.Errorf("graph without way 2: %v, want the 2 edges of way 1", err)
+	}
+}
+
+// primary roads every third row and column, residential ones elsewhere,
+// some of them oneway
+func mixedTags(id int64) tags.Tags {
+	t := tags.Tags{"highway": "residential"}
+	if id%10%3 == 0 || id/10%100%3 == 0 {
+		t["highway"] = "primary"
+	}
+	if id%7 == 0 {
+		t["oneway"] = "yes"
+	}
+	return t
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,214 @@
+package graph
+
+import (
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"strconv"
+	"strings"
+)
+
+// A Profile decides which ways a vehicle may use, in which directions and
+// how fast.
+type Profile struct {
+	Name string
+	// the speed in km/h per highway value, ways with other highway values
+	// are not routable
+	Speeds map[string]float64
+	// the access keys from the most general to the most specific, the
+	// most specific one present decides, e.g. access, vehicle,
+	// motor_vehicle, motorcar
+	Access []string
+	// the oneway keys, the first one present decides, an empty list
+	// ignores oneway restrictions (e.g. for pedestrians)
+	Oneway []string
+	// roundabouts and motorways are oneway without a oneway tag
+	ImpliedOneway bool
+	// use a lower maxspeed tag instead of the profile speed
+	UseMaxspeed bool
+	// the names of the vehicle in restriction:<vehicle> and except tags of
+	// turn restrictions, no turn restrictions apply if empty
+	Vehicles []string
+}
+
+// the values of the access keys which allow access
+var allowed = map[string]bool{
+	"yes":         true,
+	"permissive":  true,
+	"designated":  true,
+	"destination": true,
+	"delivery":    true,
+	"customers":   true,
+}
+
+var Car = &Profile{
+	Name: "car",
+	Speeds: map[string]float64{
+		"motorway":       120,
+		"motorway_link":  60,
+		"trunk":          90,
+		"trunk_link":     50,
+		"primary":        70,
+		"primary_link":   40,
+		"secondary":      60,
+		"secondary_link": 40,
+		"tertiary":       50,
+		"tertiary_link":  30,
+		"unclassified":   40,
+		"residential":    30,
+		"living_street":  10,
+		"service":        20,
+		"road":           30,
+	},
+	Access:        []string{"access", "vehicle", "motor_vehicle", "motorcar"},
+	Oneway:        []string{"oneway:motorcar", "oneway"},
+	ImpliedOneway: true,
+	UseMaxspeed:   true,
+	Vehicles:      []string{"motorcar", "motor_vehicle", "vehicle"},
+}
+
+var Bicycle = &Profile{
+	Name: "bicycle",
+	Speeds: map[string]float64{
+		"trunk":          18,
+		"trunk_link":     18,
+		"primary":        18,
+		"primary_link":   18,
+		"secondary":      18,
+		"secondary_link": 18,
+		"tertiary":       18,
+		"tertiary_link":  18,
+		"unclassified":   18,
+		"residential":    18,
+		"living_street":  12,
+		"service":        15,
+		"road":           15,
+		"track":          12,
+		"cycleway":       20,
+		"path":           12,
+		"pedestrian":     6,
+		"footway":        6,
+		"bridleway":      8,
+	},
+	Access:        []string{"access", "vehicle", "bicycle"},
+	Oneway:        []string{"oneway:bicycle", "oneway"},
+	ImpliedOneway: true,
+	Vehicles:      []string{"bicycle", "vehicle"},
+}
+
+var Foot = &Profile{
+	Name: "foot",
+	Speeds: map[string]float64{
+		"primary":        5,
+		"primary_link":   5,
+		"secondary":      5,
+		"secondary_link": 5,
+		"tertiary":       5,
+		"tertiary_link":  5,
+		"unclassified":   5,
+		"residential":    5,
+		"living_street":  5,
+		"service":        5,
+		"road":           5,
+		"track":          5,
+		"path":           5,
+		"pedestrian":     5,
+		"footway":        5,
+		"steps":          3,
+		"cycleway":       5,
+		"bridleway":      5,
+	},
+	Access: []string{"access", "foot"},
+	Oneway: []string{"oneway:foot"},
+}
+
+// checks if the vehicle may use the way
+func (p *Profile) Routable(w *way.Way) bool {
+	if w.Tags_ == nil || w.Tags_.Get("area") == "yes" {
+		return false
+	}
+	if _, ok := p.Speeds[w.Tags_.Get("highway")]; !ok {
+		return false
+	}
+	for i := len(p.Access) - 1; i >= 0; i-- {
+		if w.Tags_.Has(p.Access[i]) {
+			return allowed[w.Tags_.Get(p.Access[i])]
+		}
+	}
+	return true
+}
+
+// the travel directions allowed on the way: along the nodes (forward)
+// and against them (backward)
+func (p *Profile) Directions(w *way.Way) (forward, backward bool) {
+	if len(p.Oneway) == 0 {
+		return true, true
+	}
+	for _, k := range p.Oneway {
+		if !w.Tags_.Has(k) {
+			continue
+		}
+		switch w.Tags_.Get(k) {
+		case "yes", "true", "1":
+			return true, false
+		case "-1", "reverse":
+			return false, true
+		case "no", "false", "0":
+			return true, true
+		}
+	}
+	if p.ImpliedOneway && impliedOneway(w.Tags_) {
+		return true, false
+	}
+	return true, true
+}
+
+func impliedOneway(t *tags.Tags) bool {
+	switch t.Get("junction") {
+	case "roundabout", "circular":
+		return true
+	}
+	return t.Get("highway") == "motorway"
+}
+
+// the speed in km/h on the way, 0 if it is not routable
+func (p *Profile) Speed(w *way.Way) float64 {
+	if w.Tags_ == nil {
+		return 0
+	}
+	s := p.Speeds[w.Tags_.Get("highway")]
+	if p.UseMaxspeed {
+		if m := maxspeed(w.Tags_.Get("maxspeed")); m > 0 && m < s {
+			s = m
+		}
+	}
+	return s
+}
+
+// parses a maxspeed value in km/h ("50", "30 mph"), 0 if not numeric
+func maxspeed(v string) float64 {
+	f := strings.Fields(v)
+	if len(f) == 0 {
+		return 0
+	}
+	s, err := strconv.ParseFloat(f[0], 64)
+	if err != nil {
+		return 0
+	}
+	if len(f) > 1 && f[1] == "mph" {
+		s *= 1.609344
+	}
+	return s
+}
+
+// the highest speed of the profile in km/h
+func (p *Profile) MaxSpeed() float64 {
+	var m float64
+	for _, s := range p.Speeds {
+		if s > m {
+			m = s
+		}
+	}
+	return m
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,112 @@
+package graph
+
+import (
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"math"
+	"testing"
+)
+
+func taggedWay(kv ...string) *way.Way {
+	t := tags.New()
+	for i := 0; i+1 < len(kv); i += 2 {
+		t.Add(kv[i], kv[i+1])
+	}
+	return &way.Way{Tags_: t}
+}
+
+func TestRoutable(t *testing.T) {
+	for _, c := range []struct {
+		p    *Profile
+		tags []string
+		want bool
+	}{
+		{Car, []string{"highway", "residential"}, true},
+		{Car, []string{"highway", "footway"}, false},
+		{Car, []string{"building", "yes"}, false},
+		{Foot, []string{"highway", "pedestrian"}, true},
+		{Foot, []string{"highway", "pedestrian", "area", "yes"}, false},
+		{Car, []string{"highway", "residential", "access", "private"}, false},
+		{Car, []string{"highway", "residential", "motor_vehicle", "destination"}, true},
+		// the most specific key decides
+		{Car, []string{"highway", "residential", "access", "no", "motorcar", "yes"}, true},
+		{Car, []string{"highway", "residential", "access", "yes", "vehicle", "no"}, false},
+		{Car, []string{"highway", "residential", "vehicle", "no", "motor_vehicle", "permissive"}, true},
+		{Bicycle, []string{"highway", "residential", "vehicle", "no", "motor_vehicle", "permissive"}, false},
+		{Bicycle, []string{"highway", "footway", "access", "no", "bicycle", "designated"}, true},
+		{Foot, []string{"highway", "cycleway", "access", "no", "bicycle", "yes"}, false},
+	} {
+		if got := c.p.Routable(taggedWay(c.tags...)); got != c.want {
+			t.Errorf("%s Routable(%v) = %v, want %v", c.p.Name, c.tags, got, c.want)
+		}
+	}
+	if Car.Routable(&way.Way{}) {
+		t.Error("way without tags is routable")
+	}
+}
+
+func TestDirections(t *testing.T) {
+	for _, c := range []struct {
+		p                 *Profile
+		tags              []string
+		forward, backward bool
+	}{
+		{Car, []string{"highway", "residential"}, true, true},
+		{Car, []string{"highway", "residential", "oneway", "yes"}, true, false},
+		{Car, []string{"highway", "residential", "oneway", "1"}, true, false},
+		{Car, []string{"highway", "residential", "oneway", "-1"}, false, true},
+		{Car, []string{"highway", "residential", "oneway", "reverse"}, false, true},
+		{Car, []string{"highway", "residential", "oneway", "alternating"}, true, true},
+		// implied oneway
+		{Car, []string{"highway", "primary", "junction", "roundabout"}, true, false},
+		{Car, []string{"highway", "motorway"}, true, false},
+		{Car, []string{"highway", "motorway_link"}, true, true},
+		{Car, []string{"highway", "motorway", "oneway", "no"}, true, true},
+		{Car, []string{"highway", "primary", "junction", "circular", "oneway", "-1"}, false, true},
+		// the first oneway key present decides
+		{Bicycle, []string{"highway", "residential", "oneway", "yes", "oneway:bicycle", "no"}, true, true},
+		{Car, []string{"highway", "residential", "oneway", "yes", "oneway:bicycle", "no"}, true, false},
+		{Bicycle, []string{"highway", "primary", "junction", "roundabout", "oneway:bicycle", "no"}, true, true},
+		// pedestrians ignore oneway and implied oneway, only oneway:foot
+		// applies to them
+		{Foot, []string{"highway", "residential", "oneway", "yes"}, true, true},
+		{Foot, []string{"highway", "primary", "junction", "roundabout"}, true, true},
+		{Foot, []string{"highway", "footway", "oneway:foot", "yes"}, true, false},
+	} {
+		f, b := c.p.Directions(taggedWay(c.tags...))
+		if f != c.forward || b != c.backward {
+			t.Errorf("%s Directions(%v) = %v %v, want %v %v", c.p.Name, c.tags, f, b, c.forward, c.backward)
+		}
+	}
+}
+
+func TestSpeed(t *testing.T) {
+	for _, c := range []struct {
+		p    *Profile
+		tags []string
+		want float64
+	}{
+		{Car, []string{"highway", "residential"}, 30},
+		{Car, []string{"highway", "primary", "maxspeed", "50"}, 50},
+		{Car, []string{"highway", "primary", "maxspeed", "30 mph"}, 30 * 1.609344},
+		// only a lower maxspeed is used
+		{Car, []string{"highway", "residential", "maxspeed", "50"}, 30},
+		{Car, []string{"highway", "motorway", "maxspeed", "none"}, 120},
+		{Car, []string{"highway", "primary", "maxspeed", "BE:urban"}, 70},
+		{Car, []string{"highway", "footway"}, 0},
+		// the bicycle profile ignores maxspeed
+		{Bicycle, []string{"highway", "residential", "maxspeed", "10"}, 18},
+	} {
+		if got := c.p.Speed(taggedWay(c.tags...)); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s Speed(%v) = %v, want %v", c.p.Name, c.tags, got, c.want)
+		}
+	}
+	if s := Car.Speed(&way.Way{}); s != 0 {
+		t.Errorf("speed %v on a way without tags, want 0", s)
+	}
+	if s := Car.MaxSpeed(); s != 120 {
+		t.Errorf("highest speed of the car profile %v, want 120", s)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,222 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/way"
+)
+
+// The turn restrictions as forbidden sequences of edges, matched by an
+// Aho-Corasick automaton over edge ids. A search keeps the automaton state
+// with every edge, a turn is forbidden if it leads to a final state.
+type turns struct {
+	next  []map[int]int
+	fail  []int
+	final []bool
+}
+
+func newTurns() *turns {
+	return &turns{next: []map[int]int{{}}, fail: []int{0}, final: []bool{false}}
+}
+
+// adds a forbidden edge sequence
+func (t *turns) add(seq []int) {
+	s := 0
+	for _, e := range seq {
+		n, ok := t.next[s][e]
+		if !ok {
+			n = len(t.next)
+			t.next = append(t.next, map[int]int{})
+			t.fail = append(t.fail, 0)
+			t.final = append(t.final, false)
+			t.next[s][e] = n
+		}
+		s = n
+	}
+	t.final[s] = true
+}
+
+// computes the failure links, after all sequences are added
+func (t *turns) build() {
+	queue := []int{}
+	for _, n := range t.next[0] {
+		queue = append(queue, n)
+	}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		for e, n := range t.next[s] {
+			f := t.fail[s]
+			for {
+				if m, ok := t.next[f][e]; ok && m != n {
+					t.fail[n] = m
+					break
+				}
+				if f == 0 {
+					break
+				}
+				f = t.fail[f]
+			}
+			t.final[n] = t.final[n] || t.final[t.fail[n]]
+			queue = append(queue, n)
+		}
+	}
+}
+
+// the state after taking edge e in state s
+func (t *turns) step(s, e int) int {
+	for {
+		if n, ok := t.next[s][e]; ok {
+			return n
+		}
+		if s == 0 {
+			return 0
+		}
+		s = t.fail[s]
+	}
+}
+
+// Collects the turn restrictions of o which apply to the profile.
+// Conditional restrictions are not enforced as their conditions are not
+// evaluated, restrictions on ways which are not in the graph are ignored.
+// The member ways are resolved in o, which changes o for an OSM with a
+// NodeStore.
+func (g *Graph) addRestrictions(o *osm.OSM) {
+	if len(g.Profile.Vehicles) == 0 {
+		return
+	}
+	t := newTurns()
+relations:
+	for r := range o.SortedRelations() {
+		if !r.IsRestriction() {
+			continue
+		}
+		for _, m := range r.Members_ {
+			if w, ok := m.Ref.(*way.Way); ok && w != nil {
+				if err := o.ResolveWay(w); err != nil {
+					g.RestrictionErrors = append(g.RestrictionErrors, err)
+					continue relations
+				}
+			}
+		}
+		rs, err := r.Restriction()
+		if err != nil {
+			g.RestrictionErrors = append(g.RestrictionErrors, err)
+			continue
+		}
+		if rs.Conditional || !rs.AppliesTo(g.Profile.Vehicles...) {
+			continue
+		}
+		seqs, err := g.restrictionSequences(rs)
+		if err != nil {
+			g.RestrictionErrors = append(g.RestrictionErrors, err)
+			continue
+		}
+		if len(seqs) == 0 {
+			continue
+		}
+		for _, s := range seqs {
+			t.add(s)
+		}
+		g.Restrictions = append(g.Restrictions, rs)
+	}
+	if len(g.Restrictions) > 0 {
+		t.build()
+		g.turns = t
+	}
+}
+
+// Returns the forbidden edge sequences of a restriction. A no_*
+// restriction forbids going from the from way along the via ways into the
+// to way, an only_* restriction forbids leaving this path anywhere.
+// Restrictions on ways which are not part of the graph have no sequences,
+// an error is returned if the ways cannot be driven along the restriction.
+func (g *Graph) restrictionSequences(rs *relation.Restriction) ([][]int, error) {
+	for _, w := range append([]*way.Way{rs.From, rs.To}, rs.ViaWays...) {
+		if g.ways[w.Id_] == nil {
+			return nil, nil
+		}
+	}
+	chain, err := rs.ViaChain()
+	if err != nil {
+		return nil, err
+	}
+	vertices := make([]int, len(chain))
+	for i, id := range chain {
+		v, ok := g.vertexOf[id]
+		if !ok {
+			return nil, nil
+		}
+		vertices[i] = v
+	}
+	// the edges along the via ways
+	var via []int
+	for i, w := range rs.ViaWays {
+		backward := w.FirstNode().Id_ != chain[i]
+		for v := vertices[i]; v != vertices[i+1]; {
+			next := -1
+			for _, id := range g.wayEdges[w.Id_] {
+				if e := &g.Edges[id]; e.From == v && e.Backward() == backward {
+					next = id
+					break
+				}
+			}
+			if next == -1 || len(via) > len(g.Edges) {
+				return nil, errors.New(fmt.Sprintf("Relation #%d: via way #%d cannot be driven towards the to way", rs.Relation.Id_, w.Id_))
+			}
+			via = append(via, next)
+			v = g.Edges[next].To
+		}
+	}
+	end := vertices[len(vertices)-1]
+	var from, to []int
+	for _, id := range g.wayEdges[rs.From.Id_] {
+		if g.Edges[id].To == vertices[0] {
+			from = append(from, id)
+		}
+	}
+	for _, id := range g.wayEdges[rs.To.Id_] {
+		if g.Edges[id].From == end {
+			to = append(to, id)
+		}
+	}
+	if len(from) == 0 {
+		return nil, errors.New(fmt.Sprintf("Relation #%d: from way #%d cannot be driven towards the via member", rs.Relation.Id_, rs.From.Id_))
+	}
+	if len(to) == 0 {
+		return nil, errors.New(fmt.Sprintf("Relation #%d: to way #%d cannot be driven from the via member", rs.Relation.Id_, rs.To.Id_))
+	}
+
+	var seqs [][]int
+	for _, f := range from {
+		prefix := append([]int{f}, via...)
+		if rs.Kind == relation.NoTurn {
+			for _, t := range to {
+				seqs = append(seqs, append(append([]int(nil), prefix...), t))
+			}
+			continue
+		}
+		// only_*: at every vertex of the path all other edges are forbidden
+		for i := 0; i <= len(via); i++ {
+			v := g.Edges[prefix[i]].To
+			allowed := map[int]bool{}
+			if i < len(via) {
+				allowed[via[i]] = true
+			} else {
+				for _, t := range to {
+					allowed[t] = true
+				}
+			}
+			for _, id := range g.Out[v] {
+				if !allowed[id] {
+					seqs = append(seqs, append(append([]int(nil), prefix[:i+1]...), id))
+				}
+			}
+		}
+	}
+	return seqs, nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
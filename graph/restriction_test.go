@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Routes on a 3 x 3 grid from the middle of the oneway east way into the
+// center to the middle of the way north of it, with a restriction from
+// that oneway via the center.
+func TestRestrictions(t *testing.T) {
+	from, to := gridPoint(1, 0.5), gridPoint(1.5, 1)
+	fromWay := eastWay(1, 0)
+	var direct float64
+	for _, c := range []struct {
+		restriction string
+		toWay       int64
+	}{
+		{"", 0},
+		{"no_left_turn", northWay(1, 1)},
+		{"only_straight_on", eastWay(1, 1)},
+	} {
+		o := grid(3, func(id int64) tags.Tags {
+			t := tags.Tags{"highway": "residential"}
+			if id == fromWay {
+				t["oneway"] = "yes"
+			}
+			return t
+		})
+		if c.restriction != "" {
+			rt := tags.Tags{"type": "restriction", "restriction": c.restriction}
+			o.AddRelation(&relation.Relation{Id_: 1, Tags_: &rt, Members_: []*relation.Member{
+				{Role: "from", Type_: item.TypeWay, Id_: fromWay, Ref: o.Ways[fromWay]},
+				{Role: "via", Type_: item.TypeNode, Id_: 5, Ref: o.GetNode(5)},
+				{Role: "to", Type_: item.TypeWay, Id_: c.toWay, Ref: o.Ways[c.toWay]},
+			}})
+		}
+		g, err := New(o, Car)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(g.RestrictionErrors) > 0 || len(g.Restrictions) != len(o.Relations) {
+			t.Fatalf("%s: %d restrictions, errors %v", c.restriction, len(g.Restrictions), g.RestrictionErrors)
+		}
+		for _, a := range []Algorithm{Dijkstra, AStar, Bidirectional} {
+			r, err := g.Route(from, to, Shortest, a)
+			if err != nil {
+				t.Fatalf("%s %v: %v", c.restriction, a, err)
+			}
+			if c.restriction == "" {
+				if want := []int64{fromWay, northWay(1, 1)}; !slices.Equal(r.Ways, want) {
+					t.Errorf("%v: ways %v, want %v", a, r.Ways, want)
+				}
+				direct = float64(r.Distance)
+				continue
+			}
+			// the way after the from way, which is left at the center
+			next := r.Ways[slices.Index(r.Ways, fromWay)+1]
+			if strings.HasPrefix(c.restriction, "no_") && next == c.toWay || strings.HasPrefix(c.restriction, "only_") && next != c.toWay {
+				t.Errorf("%s %v: ways %v", c.restriction, a, r.Ways)
+			}
+			if float64(r.Distance) <= direct {
+				t.Errorf("%s %v: distance %v, not longer than without restriction", c.restriction, a, r.Distance)
+			}
+		}
+	}
+}
+
+// Routes on a 3 x 3 grid from the middle of the oneway east way at the
+// bottom to the middle of the way north of the center, with a restriction
+// from that oneway via the way north of it to the way north of the center.
+func TestViaWayRestrictions(t *testing.T) {
+	from, to := gridPoint(0, 0.5), gridPoint(1.5, 1)
+	fromWay, viaWay := eastWay(0, 0), northWay(0, 1)
+	var direct float64
+	for _, c := range []struct {
+		restriction string
+		toWay       int64
+	}{
+		{"", 0},
+		{"no_straight_on", northWay(1, 1)},
+		{"only_right_turn", eastWay(1, 1)},
+	} {
+		o := grid(3, func(id int64) tags.Tags {
+			t := tags.Tags{"highway": "residential"}
+			if id == fromWay {
+				t["oneway"] = "yes"
+			}
+			return t
+		})
+		if c.restriction != "" {
+			rt := tags.Tags{"type": "restriction", "restriction": c.restriction}
+			o.AddRelation(&relation.Relation{Id_: 1, Tags_: &rt, Members_: []*relation.Member{
+				{Role: "from", Type_: item.TypeWay, Id_: fromWay, Ref: o.Ways[fromWay]},
+				{Role: "via", Type_: item.TypeWay, Id_: viaWay, Ref: o.Ways[viaWay]},
+				{Role: "to", Type_: item.TypeWay, Id_: c.toWay, Ref: o.Ways[c.toWay]},
+			}})
+		}
+		g, err := New(o, Car)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(g.RestrictionErrors) > 0 || len(g.Restrictions) != len(o.Relations) {
+			t.Fatalf("%s: %d restrictions, errors %v", c.restriction, len(g.Restrictions), g.RestrictionErrors)
+		}
+		for _, a := range []Algorithm{Dijkstra, AStar, Bidirectional} {
+			r, err := g.Route(from, to, Shortest, a)
+			if err != nil {
+				t.Fatalf("%s %v: %v", c.restriction, a, err)
+			}
+			if c.restriction == "" {
+				if want := []int64{fromWay, viaWay, northWay(1, 1)}; !slices.Equal(r.Ways, want) {
+					t.Errorf("%v: ways %v, want %v", a, r.Ways, want)
+				}
+				direct = float64(r.Distance)
+				continue
+			}
+			// the way after the via way where the route takes it from the
+			// from way, on the edges as the route may turn on the via way
+			var ways []int64
+			for _, id := range r.Edges {
+				ways = append(ways, g.Edges[id].Way)
+			}
+			for i := 0; i+2 < len(ways); i++ {
+				if ways[i] != fromWay || ways[i+1] != viaWay {
+					continue
+				}
+				if strings.HasPrefix(c.restriction, "no_") && ways[i+2] == c.toWay || strings.HasPrefix(c.restriction, "only_") && ways[i+2] != c.toWay {
+					t.Errorf("%s %v: edges on the ways %v", c.restriction, a, ways)
+				}
+			}
+			if float64(r.Distance) <= direct {
+				t.Errorf("%s %v: distance %v, not longer than without restriction", c.restriction, a, r.Distance)
+			}
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
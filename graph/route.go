@@ -0,0 +1,450 @@
+package graph
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/spatial"
+	"github.com/brechtvm/osm/way"
+	"maps"
+	"math"
+	"slices"
+	"time"
+)
+
+// what a route search minimizes
+type Metric int
+
+const (
+	Shortest Metric = iota // the distance
+	Fastest                // the travel time
+)
+
+// the search algorithm of a route query, all give routes of the same
+// (optimal) cost
+type Algorithm int
+
+const (
+	Dijkstra Algorithm = iota
+	// A* with the great circle distance to the destination as heuristic
+	AStar
+	// Dijkstra from both ends, faster for long routes
+	Bidirectional
+)
+
+func (m Metric) String() string {
+	switch m {
+	case Shortest:
+		return "shortest"
+	case Fastest:
+		return "fastest"
+	}
+	return "unknown"
+}
+
+func (a Algorithm) String() string {
+	switch a {
+	case Dijkstra:
+		return "Dijkstra"
+	case AStar:
+		return "A*"
+	case Bidirectional:
+		return "bidirectional Dijkstra"
+	}
+	return "unknown"
+}
+
+// positions farther than this from a routable way are not snapped
+var DefaultSnapRadius = 500 * distance.Meter
+
+// the weight of an edge in the metric, metres or seconds
+func (g *Graph) weight(e *Edge, m Metric) float64 {
+	if m == Fastest {
+		return e.Duration.Seconds()
+	}
+	return float64(e.Length)
+}
+
+// a position on an edge
+type edgePos struct {
+	edge    int
+	segment int     // index of the segment in the edge nodes
+	frac    float64 // of the edge length from its From vertex
+}
+
+// a position snapped to the graph
+type Location struct {
+	Point    *point.Point      // the position on the way
+	Distance distance.Distance // from the query position
+	Way      int64
+	edges    []edgePos
+}
+
+// snaps p to the nearest routable way within the radius. Of ways at the
+// same distance the same one is chosen for every graph built from the
+// same data, the index is built from the ways in id order.
+func (g *Graph) Snap(p *point.Point, radius distance.Distance) (*Location, error) {
+	g.indexOnce.Do(func() {
+		ids := slices.Sorted(maps.Keys(g.ways))
+		wl := make([]*way.Way, len(ids))
+		for i, id := range ids {
+			wl[i] = g.ways[id]
+		}
+		g.index = spatial.NewFromWays(slices.Values(wl))
+	})
+	s, ok := g.index.NearestSegment(p, radius, nil)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("No routable way within %s of %v", radius, p))
+	}
+	w := s.Way
+	l := &Location{Point: s.Point, Distance: s.Distance, Way: w.Id_}
+	// distance from the first node of the way to each node
+	cum := make([]distance.Distance, len(w.Nodes_))
+	for i := 1; i < len(w.Nodes_); i++ {
+		cum[i] = cum[i-1] + w.Nodes_[i-1].Position_.DistanceOf(w.Nodes_[i].Position_)
+	}
+	for _, id := range g.wayEdges[w.Id_] {
+		e := &g.Edges[id]
+		lo, hi := e.FromOffset, e.ToOffset
+		if e.Backward() {
+			lo, hi = hi, lo
+		}
+		if s.Index < lo || s.Index >= hi {
+			continue
+		}
+		along, seg := s.Offset-cum[lo], s.Index-lo
+		if e.Backward() {
+			along, seg = cum[hi]-s.Offset, hi-s.Index-1
+		}
+		frac := 0.0
+		if e.Length > 0 {
+			frac = math.Max(0, math.Min(1, float64(along/e.Length)))
+		}
+		l.edges = append(l.edges, edgePos{edge: id, segment: seg, frac: frac})
+	}
+	if len(l.edges) == 0 {
+		return nil, errors.New(fmt.Sprintf("Way #%d has no edges", w.Id_))
+	}
+	return l, nil
+}
+
+// a route found by a search
+type Route struct {
+	// the positions along the route, starting and ending at the snapped
+	// locations
+	Points []*point.Point
+	// the OSM nodes passed
+	Nodes []*node.Node
+	// the ids of the ways used, in order, without repetitions
+	Ways []int64
+	// the edge ids, the first and last one may be used partially
+	Edges    []int
+	Distance distance.Distance
+	Duration time.Duration
+}
+
+// Searches the best route between two positions which are snapped to the
+// nearest routable ways (see DefaultSnapRadius).
+func (g *Graph) Route(from, to *point.Point, m Metric, a Algorithm) (*Route, error) {
+	s, err := g.Snap(from, DefaultSnapRadius)
+	if err != nil {
+		return nil, err
+	}
+	t, err := g.Snap(to, DefaultSnapRadius)
+	if err != nil {
+		return nil, err
+	}
+	return g.RouteLocations(s, t, m, a)
+}
+
+// a start or end of a search: a vertex reached through part of an edge
+type seed struct {
+	vertex int
+	cost   float64
+	pos    edgePos
+}
+
+// the result of a search: the edges of the route
+type path struct {
+	first, last edgePos
+	edges       []int // between first and last
+	direct      bool  // on the same edge, edges is empty
+}
+
+// Searches the best route between two snapped locations. If the graph
+// has turn restrictions the search runs on its edges, a bidirectional
+// search then runs as Dijkstra.
+func (g *Graph) RouteLocations(from, to *Location, m Metric, a Algorithm) (*Route, error) {
+	sources, targets, best, result := g.seeds(from, to, m)
+	var p *path
+	var cost float64
+	var h func(v int) float64
+	if a == AStar {
+		h = g.heuristic(to.Point, m)
+	}
+	switch {
+	case g.turns != nil:
+		p, cost = g.edgeBased(sources, targets, m, h)
+	case a == Bidirectional:
+		p, cost = g.bidirectional(sources, targets, m)
+	default:
+		p, cost = g.unidirectional(sources, targets, m, h)
+	}
+	if p != nil && cost < best {
+		result = p
+	}
+	if result == nil {
+		return nil, errors.New("No route found")
+	}
+	return g.route(from, to, result), nil
+}
+
+// Returns the seeds of a search between two locations, and the best
+// direct path if both are on the same edge (with its cost, +Inf if none).
+func (g *Graph) seeds(from, to *Location, m Metric) (sources, targets []seed, best float64, direct *path) {
+	best = math.Inf(1)
+	for _, s := range from.edges {
+		e := &g.Edges[s.edge]
+		sources = append(sources, seed{vertex: e.To, cost: (1 - s.frac) * g.weight(e, m), pos: s})
+		for _, t := range to.edges {
+			if t.edge == s.edge && t.frac >= s.frac {
+				if c := (t.frac - s.frac) * g.weight(e, m); c < best {
+					best, direct = c, &path{first: s, last: t, direct: true}
+				}
+			}
+		}
+	}
+	for _, t := range to.edges {
+		e := &g.Edges[t.edge]
+		targets = append(targets, seed{vertex: e.From, cost: t.frac * g.weight(e, m), pos: t})
+	}
+	return
+}
+
+// a lower bound of the cost from a vertex to the destination
+func (g *Graph) heuristic(to *point.Point, m Metric) func(v int) float64 {
+	max := g.Profile.MaxSpeed() / 3.6
+	return func(v int) float64 {
+		d := float64(g.Vertices[v].Position.DistanceOf(to))
+		if m == Fastest {
+			return d / max
+		}
+		return d
+	}
+}
+
+type queued struct {
+	vertex int
+	key    float64
+}
+
+type queue []queued
+
+func (q queue) Len() int            { return len(q) }
+func (q queue) Less(i, j int) bool  { return q[i].key < q[j].key }
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(queued)) }
+func (q *queue) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// one direction of a search
+type frontier struct {
+	g        *Graph
+	metric   Metric
+	backward bool
+	h        func(int) float64
+	dist     []float64
+	pred     []int // edge to the vertex, -1 for seeds
+	seedPos  map[int]edgePos
+	settled  []bool
+	q        *queue
+}
+
+func newFrontier(g *Graph, m Metric, backward bool, h func(int) float64, seeds []seed) *frontier {
+	n := len(g.Vertices)
+	f := &frontier{g: g, metric: m, backward: backward, h: h, dist: make([]float64, n), pred: make([]int, n), seedPos: make(map[int]edgePos), settled: make([]bool, n), q: &queue{}}
+	for i := range f.dist {
+		f.dist[i] = math.Inf(1)
+		f.pred[i] = -1
+	}
+	for _, s := range seeds {
+		if s.cost < f.dist[s.vertex] {
+			f.dist[s.vertex] = s.cost
+			f.seedPos[s.vertex] = s.pos
+			f.push(s.vertex)
+		}
+	}
+	return f
+}
+
+func (f *frontier) push(v int) {
+	k := f.dist[v]
+	if f.h != nil {
+		k += f.h(v)
+	}
+	heap.Push(f.q, queued{vertex: v, key: k})
+}
+
+// the smallest key in the queue, +Inf if it is empty
+func (f *frontier) top() float64 {
+	for f.q.Len() > 0 {
+		if x := (*f.q)[0]; !f.settled[x.vertex] {
+			return x.key
+		}
+		heap.Pop(f.q)
+	}
+	return math.Inf(1)
+}
+
+// settles the next vertex and relaxes its edges, returns -1 if the queue
+// is empty; relaxed is called for every improved vertex
+func (f *frontier) step(relaxed func(v int)) int {
+	if math.IsInf(f.top(), 1) {
+		return -1
+	}
+	v := heap.Pop(f.q).(queued).vertex
+	f.settled[v] = true
+	edges := f.g.Out[v]
+	if f.backward {
+		edges = f.g.In[v]
+	}
+	for _, id := range edges {
+		e := &f.g.Edges[id]
+		w := e.To
+		if f.backward {
+			w = e.From
+		}
+		if d := f.dist[v] + f.g.weight(e, f.metric); d < f.dist[w] {
+			f.dist[w] = d
+			f.pred[w] = id
+			delete(f.seedPos, w)
+			f.push(w)
+			if relaxed != nil {
+				relaxed(w)
+			}
+		}
+	}
+	return v
+}
+
+// the edges from the seed to v, in the direction of travel
+func (f *frontier) edges(v int) ([]int, edgePos) {
+	var el []int
+	for f.pred[v] != -1 {
+		e := &f.g.Edges[f.pred[v]]
+		el = append(el, e.Id)
+		if f.backward {
+			v = e.To
+		} else {
+			v = e.From
+		}
+	}
+	if !f.backward {
+		for i, j := 0, len(el)-1; i < j; i, j = i+1, j-1 {
+			el[i], el[j] = el[j], el[i]
+		}
+	}
+	return el, f.seedPos[v]
+}
+
+func (g *Graph) unidirectional(sources, targets []seed, m Metric, h func(int) float64) (*path, float64) {
+	tc := make(map[int]seed)
+	for _, t := range targets {
+		if s, ok := tc[t.vertex]; !ok || t.cost < s.cost {
+			tc[t.vertex] = t
+		}
+	}
+	f := newFrontier(g, m, false, h, sources)
+	best, meet := math.Inf(1), -1
+	for f.top() < best {
+		v := f.step(nil)
+		if t, ok := tc[v]; ok && f.dist[v]+t.cost < best {
+			best, meet = f.dist[v]+t.cost, v
+		}
+	}
+	if meet == -1 {
+		return nil, best
+	}
+	el, first := f.edges(meet)
+	return &path{first: first, last: tc[meet].pos, edges: el}, best
+}
+
+func (g *Graph) bidirectional(sources, targets []seed, m Metric) (*path, float64) {
+	ff := newFrontier(g, m, false, nil, sources)
+	fb := newFrontier(g, m, true, nil, targets)
+	best, meet := math.Inf(1), -1
+	check := func(v int) {
+		if d := ff.dist[v] + fb.dist[v]; d < best {
+			best, meet = d, v
+		}
+	}
+	for _, s := range sources {
+		check(s.vertex)
+	}
+	for ff.top()+fb.top() < best {
+		if ff.top() <= fb.top() {
+			ff.step(check)
+		} else {
+			fb.step(check)
+		}
+	}
+	if meet == -1 {
+		return nil, best
+	}
+	fe, first := ff.edges(meet)
+	be, last := fb.edges(meet)
+	return &path{first: first, last: last, edges: append(fe, be...)}, best
+}
+
+// builds the route from a path
+func (g *Graph) route(from, to *Location, p *path) *Route {
+	r := &Route{Points: []*point.Point{from.Point}}
+	addWay := func(id int64) {
+		if len(r.Ways) == 0 || r.Ways[len(r.Ways)-1] != id {
+			r.Ways = append(r.Ways, id)
+		}
+	}
+	addNodes := func(nl []*node.Node) {
+		for _, n := range nl {
+			// the vertex between two edges is passed once
+			if len(r.Nodes) > 0 && r.Nodes[len(r.Nodes)-1].Id_ == n.Id_ {
+				continue
+			}
+			r.Nodes = append(r.Nodes, n)
+			r.Points = append(r.Points, n.Position_)
+		}
+	}
+	part := func(e *Edge, frac float64) {
+		r.Distance += distance.Distance(frac) * e.Length
+		r.Duration += time.Duration(frac * float64(e.Duration))
+		r.Edges = append(r.Edges, e.Id)
+		addWay(e.Way)
+	}
+
+	first := &g.Edges[p.first.edge]
+	if p.direct {
+		part(first, p.last.frac-p.first.frac)
+		addNodes(g.EdgeNodes(first.Id)[p.first.segment+1 : p.last.segment+1])
+	} else {
+		part(first, 1-p.first.frac)
+		addNodes(g.EdgeNodes(first.Id)[p.first.segment+1:])
+		for _, id := range p.edges {
+			part(&g.Edges[id], 1)
+			addNodes(g.EdgeNodes(id))
+		}
+		last := &g.Edges[p.last.edge]
+		part(last, p.last.frac)
+		addNodes(g.EdgeNodes(last.Id)[:p.last.segment+1])
+	}
+	r.Points = append(r.Points, to.Point)
+	return r
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
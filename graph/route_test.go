@@ -0,0 +1,179 @@
+package graph
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// A position between the nodes 2 and 3 of way 1 of lineOSM(), it lies on
+// the forward edge 0 and the backward edge 1.
+func TestSnap(t *testing.T) {
+	g, err := New(lineOSM(), Car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := g.Snap(point.New(0.0001, 0.0015), DefaultSnapRadius)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Way != 1 || math.Abs(l.Point.Lat) > 1e-9 || math.Abs(l.Point.Lon-0.0015) > 1e-8 {
+		t.Errorf("snapped to way %d at %v, want way 1 at 0,0.0015", l.Way, l.Point)
+	}
+	if !sameDistance(l.Distance, unit/10) {
+		t.Errorf("snapped %v away, want %v", l.Distance, unit/10)
+	}
+	// 1.5 of the 2 units of the forward edge from node 1, the second
+	// segment; 0.5 units of the backward edge from node 5, its first
+	// segment (from node 3 to node 2). The projection onto the segment is
+	// approximate, the margins allow for that.
+	want := []edgePos{{edge: 0, segment: 1, frac: 0.75}, {edge: 1, segment: 0, frac: 0.25}}
+	if len(l.edges) != len(want) {
+		t.Fatalf("on the edges %v, want %v", l.edges, want)
+	}
+	for i, w := range want {
+		e := l.edges[i]
+		if e.edge != w.edge || e.segment != w.segment || math.Abs(e.frac-w.frac) > 1e-5 {
+			t.Errorf("on %+v, want %+v", e, w)
+		}
+	}
+
+	if _, err = g.Snap(point.New(0.01, 0.01), 100); err == nil {
+		t.Error("no error for a position far from the ways")
+	}
+}
+
+// The ways 3 to 8 have the same geometry as way 1 of lineOSM(), with
+// nodes of their own. Every graph built from the data snaps to the same
+// one of them.
+func TestSnapTie(t *testing.T) {
+	o := lineOSM()
+	for id := int64(3); id <= 8; id++ {
+		a := &node.Node{Id_: 10 * id, Position_: point.New(0, 0), Tags_: tags.New()}
+		b := &node.Node{Id_: 10*id + 1, Position_: point.New(0, 0.004), Tags_: tags.New()}
+		o.AddNode(a)
+		o.AddNode(b)
+		o.AddWay(&way.Way{Id_: id, Nodes_: []*node.Node{a, b}, NodeIDs: []int64{a.Id_, b.Id_}, Tags_: &tags.Tags{"highway": "residential"}})
+	}
+	var first int64
+	for i := 0; i < 20; i++ {
+		g, err := New(o, Car)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := g.Snap(point.New(0.0001, 0.0015), DefaultSnapRadius)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			first = l.Way
+		} else if l.Way != first {
+			t.Fatalf("graph %d snapped to way %d, the first one to way %d", i, l.Way, first)
+		}
+	}
+}
+
+// routes on lineOSM() with the expected distance, nodes and ways
+func TestRoute(t *testing.T) {
+	g, err := New(lineOSM(), Car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		name     string
+		from, to *point.Point
+		distance float64 // units
+		nodes    []int64
+		ways     []int64
+		edges    []int
+	}{
+		{"turn into the oneway", point.New(0.0001, 0.0005), point.New(0.0015, 0.0021), 3, []int64{2, 3, 6}, []int64{1, 2}, []int{0, 4}},
+		{"against the way direction", point.New(0, 0.0035), point.New(0, 0.0005), 3, []int64{4, 3, 2}, []int64{1}, []int{3, 1}},
+		{"on one backward edge", point.New(0, 0.0018), point.New(0, 0.0012), 0.6, nil, []int64{1}, []int{1}},
+		{"against the oneway", point.New(0.0015, 0.002), point.New(0, 0.0005), -1, nil, nil, nil},
+	} {
+		for _, a := range []Algorithm{Dijkstra, AStar, Bidirectional} {
+			r, err := g.Route(c.from, c.to, Shortest, a)
+			if c.distance < 0 {
+				if err == nil {
+					t.Errorf("%s %v: no error", c.name, a)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s %v: %v", c.name, a, err)
+				continue
+			}
+			if want := unit * distance.Distance(c.distance); math.Abs(float64(r.Distance-want)) > 1e-3 {
+				t.Errorf("%s %v: distance %v, want %v", c.name, a, r.Distance, want)
+			}
+			if want := travelTime(unit*distance.Distance(c.distance), 30); (r.Duration - want).Abs() > time.Millisecond {
+				t.Errorf("%s %v: duration %v, want %v", c.name, a, r.Duration, want)
+			}
+			var nodes []int64
+			for _, n := range r.Nodes {
+				nodes = append(nodes, n.Id_)
+			}
+			if !slices.Equal(nodes, c.nodes) || !slices.Equal(r.Ways, c.ways) || !slices.Equal(r.Edges, c.edges) {
+				t.Errorf("%s %v: nodes %v, ways %v, edges %v, want %v, %v, %v", c.name, a, nodes, r.Ways, r.Edges, c.nodes, c.ways, c.edges)
+			}
+			if len(r.Points) != len(c.nodes)+2 {
+				t.Errorf("%s %v: %d points, want %d", c.name, a, len(r.Points), len(c.nodes)+2)
+			}
+		}
+	}
+}
+
+// the routes between these positions on the mixed grid
+var gridRoutes = [][2]*point.Point{
+	{gridPoint(0, 0.5), gridPoint(7, 6.5)},
+	{gridPoint(3.5, 0), gridPoint(4.5, 7)},
+	{gridPoint(7, 2.25), gridPoint(0, 4.75)},
+	{gridPoint(2, 2.5), gridPoint(5.5, 5)},
+	{gridPoint(6, 6.5), gridPoint(1.5, 1)},
+	{gridPoint(4, 0.5), gridPoint(4, 0.75)},
+}
+
+// the cost of a route, compared with a margin for the rounding
+func cost(r *Route, m Metric) float64 {
+	if m == Fastest {
+		return r.Duration.Seconds()
+	}
+	return float64(r.Distance)
+}
+
+func sameCost(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6*math.Max(1, math.Abs(a))+float64(time.Millisecond)/float64(time.Second)
+}
+
+func TestAlgorithms(t *testing.T) {
+	g, err := New(grid(8, mixedTags), Car)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []Metric{Shortest, Fastest} {
+		for _, pq := range gridRoutes {
+			want, err := g.Route(pq[0], pq[1], m, Dijkstra)
+			if err != nil {
+				t.Fatalf("%v %v to %v: %v", m, pq[0], pq[1], err)
+			}
+			for _, a := range []Algorithm{AStar, Bidirectional} {
+				r, err := g.Route(pq[0], pq[1], m, a)
+				if err != nil {
+					t.Errorf("%v %v %v to %v: %v", m, a, pq[0], pq[1], err)
+				} else if !sameCost(cost(r, m), cost(want, m)) {
+					t.Errorf("%v %v %v to %v costs %f, Dijkstra %f", m, a, pq[0], pq[1], cost(r, m), cost(want, m))
+				}
+			}
+		}
+	}
+}
+
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,103 @@
+package graph
+
+import (
+	"container/heap"
+	"math"
+)
+
+// a state of the edge based search: the last edge taken and the state of
+// the turn automaton after it
+type turnState struct {
+	edge, turn int
+}
+
+// Searches the best path on the edges of the graph instead of on its
+// vertices, so that forbidden turns can be skipped. h is the heuristic of
+// A*, nil for Dijkstra.
+func (g *Graph) edgeBased(sources, targets []seed, m Metric, h func(int) float64) (*path, float64) {
+	var states []turnState
+	index := make(map[turnState]int)
+	var dist []float64
+	var pred []int
+	var settled []bool
+	seedPos := make(map[int]edgePos)
+	q := &queue{}
+	relax := func(s turnState, d float64, from int) int {
+		i, ok := index[s]
+		if !ok {
+			i = len(states)
+			index[s] = i
+			states = append(states, s)
+			dist = append(dist, math.Inf(1))
+			pred = append(pred, -1)
+			settled = append(settled, false)
+		}
+		if d >= dist[i] {
+			return -1
+		}
+		dist[i], pred[i] = d, from
+		k := d
+		if h != nil {
+			k += h(g.Edges[s.edge].To)
+		}
+		heap.Push(q, queued{vertex: i, key: k})
+		return i
+	}
+	for _, s := range sources {
+		if i := relax(turnState{s.pos.edge, g.turns.step(0, s.pos.edge)}, s.cost, -1); i != -1 {
+			seedPos[i] = s.pos
+		}
+	}
+	// the targets by the vertex they are entered from
+	tv := make(map[int][]seed)
+	for _, t := range targets {
+		tv[t.vertex] = append(tv[t.vertex], t)
+	}
+
+	best, meet := math.Inf(1), -1
+	var last edgePos
+	for q.Len() > 0 {
+		x := heap.Pop(q).(queued)
+		i := x.vertex
+		if settled[i] {
+			continue
+		}
+		if x.key >= best {
+			break
+		}
+		settled[i] = true
+		s := states[i]
+		v := g.Edges[s.edge].To
+		for _, t := range tv[v] {
+			if g.turns.final[g.turns.step(s.turn, t.pos.edge)] {
+				continue
+			}
+			if d := dist[i] + t.cost; d < best {
+				best, meet, last = d, i, t.pos
+			}
+		}
+		for _, id := range g.Out[v] {
+			next := g.turns.step(s.turn, id)
+			if g.turns.final[next] {
+				continue
+			}
+			if j := relax(turnState{id, next}, dist[i]+g.weight(&g.Edges[id], m), i); j != -1 {
+				delete(seedPos, j)
+			}
+		}
+	}
+	if meet == -1 {
+		return nil, best
+	}
+	var el []int
+	i := meet
+	for ; pred[i] != -1; i = pred[i] {
+		el = append(el, states[i].edge)
+	}
+	for a, b := 0, len(el)-1; a < b; a, b = a+1, b-1 {
+		el[a], el[b] = el[b], el[a]
+	}
+	return &path{first: seedPos[i], last: last, edges: el}, best
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,174 @@
+package history
+
+import (
+	"fmt"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"sort"
+	"strings"
+)
+
+func position(p *point.Point) string {
+	if p == nil {
+		return "none"
+	}
+	return fmt.Sprintf("%v,%v", p.Lat, p.Lon)
+}
+
+// moves the node n to the position p, see node.MoveTo()
+func (h *History) MoveNode(n *node.Node, p *point.Point) error {
+	return h.record([]item.Item{n}, func() ([]item.Item, []Change, error) {
+		from := position(n.Position_)
+		err := n.MoveTo(p)
+		return nil, []Change{{"move", item.TypeNode, n.Id_, from + " -> " + position(p)}}, err
+	})
+}
+
+// splits the way w, see way.Split(). The new ways and nodes are added to
+// the OSM. Relations w is a member of are not changed.
+func (h *History) SplitWay(w *way.Way, ids ...int64) (ws []*way.Way, err error) {
+	err = h.record([]item.Item{w}, func() ([]item.Item, []Change, error) {
+		var added []item.Item
+		ws, err = w.Split(ids...)
+		if err != nil {
+			return nil, nil, err
+		}
+		var newIds []string
+		for _, nw := range ws[1:] {
+			for _, n := range nw.Nodes_ {
+				if h.o.GetNode(n.Id_) == nil {
+					added = append(added, n)
+				}
+			}
+			added = append(added, nw)
+			newIds = append(newIds, fmt.Sprintf("#%d", nw.Id_))
+		}
+		return added, []Change{{"split", item.TypeWay, w.Id_, "new ways " + strings.Join(newIds, ", ")}}, nil
+	})
+	return
+}
+
+// joins the ways to w, see way.Join(). The joined ways are marked deleted.
+func (h *History) JoinWays(w *way.Way, ways ...*way.Way) error {
+	items := []item.Item{w}
+	var joined []string
+	for _, y := range ways {
+		items = append(items, y)
+		joined = append(joined, fmt.Sprintf("#%d", y.Id_))
+	}
+	return h.record(items, func() ([]item.Item, []Change, error) {
+		err := w.Join(ways...)
+		c := []Change{{"join", item.TypeWay, w.Id_, "joined " + strings.Join(joined, ", ")}}
+		for _, y := range ways {
+			c = append(c, Change{"delete", item.TypeWay, y.Id_, fmt.Sprintf("joined to #%d", w.Id_)})
+		}
+		return nil, c, err
+	})
+}
+
+// inserts the node n at the position pos into w, see way.InsertAt(). If
+// n is not yet part of the OSM, it is added.
+func (h *History) InsertNode(w *way.Way, pos int, n *node.Node) error {
+	return h.record([]item.Item{w}, func() ([]item.Item, []Change, error) {
+		var added []item.Item
+		if h.o.GetNode(n.Id_) == nil {
+			added = append(added, n)
+		}
+		w.InsertAt(pos, n)
+		return added, []Change{{"insert", item.TypeWay, w.Id_, fmt.Sprintf("node #%d at %d", n.Id_, pos)}}, nil
+	})
+}
+
+// sets the tags of the item i, see osm.SetTags(). t may be the tags of i
+// changed in place: the tags i had before are then taken from the tag
+// index of the OSM. Without a tag index such a change is recorded, but
+// it is empty and can not be undone.
+func (h *History) SetTags(i item.Item, t *tags.Tags) error {
+	if t != nil && t == i.Tags() && h.o.TagIndex != nil {
+		old := h.o.TagIndex.Tags(i.Type(), i.Id())
+		if old == nil {
+			old = tags.New()
+		}
+		// back to the indexed tags, so they are saved by record()
+		switch v := i.(type) {
+		case *node.Node:
+			v.Tags_ = old
+		case *way.Way:
+			v.Tags_ = old
+		case *relation.Relation:
+			v.Tags_ = old
+		}
+	}
+	return h.record([]item.Item{i}, func() ([]item.Item, []Change, error) {
+		d := tagDiff(i.Tags(), t)
+		h.o.SetTags(i, t)
+		return nil, []Change{{"tags", i.Type(), i.Id(), d}}, nil
+	})
+}
+
+// adds the item i with the given role to the relation r
+func (h *History) AddMember(r *relation.Relation, i item.Item, role string) error {
+	return h.record([]item.Item{r}, func() ([]item.Item, []Change, error) {
+		r.AddMember(i, role)
+		return nil, []Change{{"member", item.TypeRelation, r.Id_, fmt.Sprintf("added %s #%d as '%s'", i.Type(), i.Id(), role)}}, nil
+	})
+}
+
+// marks the item i as deleted
+func (h *History) Delete(i item.Item) error {
+	return h.record([]item.Item{i}, func() ([]item.Item, []Change, error) {
+		switch i.(type) {
+		case *node.Node:
+			i.(*node.Node).Delete()
+		case *way.Way:
+			i.(*way.Way).Delete()
+		case *relation.Relation:
+			i.(*relation.Relation).Delete()
+		}
+		return nil, []Change{{"delete", i.Type(), i.Id(), ""}}, nil
+	})
+}
+
+// returns the tag changes as "+k=v", "-k=v" and "k=old->new", sorted by key
+func tagDiff(old, new *tags.Tags) string {
+	o := map[string]string{}
+	n := map[string]string{}
+	if old != nil {
+		o = map[string]string(*old)
+	}
+	if new != nil {
+		n = map[string]string(*new)
+	}
+	keys := map[string]bool{}
+	for k := range o {
+		keys[k] = true
+	}
+	for k := range n {
+		keys[k] = true
+	}
+	var sorted []string
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	var d []string
+	for _, k := range sorted {
+		ov, inOld := o[k]
+		nv, inNew := n[k]
+		switch {
+		case !inOld:
+			d = append(d, "+"+k+"="+nv)
+		case !inNew:
+			d = append(d, "-"+k+"="+ov)
+		case ov != nv:
+			d = append(d, k+"="+ov+"->"+nv)
+		}
+	}
+	return strings.Join(d, " ")
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,331 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/way"
+	"strings"
+)
+
+// A Change describes one edit for review, e.g.
+//
+//	{Action: "move", Type: item.TypeNode, Id: 42, Detail: "51.1,4.2 -> 51.2,4.2"}
+type Change struct {
+	Action string
+	Type   item.ItemType
+	Id     int64
+	Detail string
+}
+
+func (c Change) String() string {
+	if c.Detail == "" {
+		return fmt.Sprintf("%s %s #%d", c.Action, c.Type, c.Id)
+	}
+	return fmt.Sprintf("%s %s #%d: %s", c.Action, c.Type, c.Id, c.Detail)
+}
+
+// one edit command with the state of all affected items before and after
+// the edit
+type command struct {
+	changes []Change
+	items   []item.Item // the items changed by the command
+	before  []func()
+	after   []func()
+	added   []item.Item // the items added to the OSM by the command
+}
+
+// A Group is a list of commands which are undone / redone together
+type Group struct {
+	Name     string
+	commands []*command
+}
+
+// returns the changes of all commands of the group
+func (g *Group) Changes() []Change {
+	var c []Change
+	for _, cmd := range g.commands {
+		c = append(c, cmd.changes...)
+	}
+	return c
+}
+
+// History is an edit layer over an *osm.OSM: all edits done through it
+// are recorded and can be undone and redone. Edits done directly on the
+// items or the OSM are not recorded and may break undo / redo of the
+// recorded ones.
+//
+// Every edit is a group of its own unless it is done between Begin() and
+// Commit().
+type History struct {
+	o      *osm.OSM
+	done   []*Group
+	undone []*Group
+	open   *Group
+}
+
+func New(o *osm.OSM) *History {
+	return &History{o: o}
+}
+
+// starts a new group, all following edits are undone together until
+// Commit() or Rollback() is called
+func (h *History) Begin(name string) error {
+	if h.open != nil {
+		return errors.New(fmt.Sprintf("Group '%s' is still open", h.open.Name))
+	}
+	h.open = &Group{Name: name}
+	return nil
+}
+
+// closes the current group
+func (h *History) Commit() error {
+	if h.open == nil {
+		return errors.New("No open group")
+	}
+	if len(h.open.commands) != 0 {
+		h.done = append(h.done, h.open)
+		h.undone = nil
+	}
+	h.open = nil
+	return nil
+}
+
+// undoes all edits of the current group and closes it
+func (h *History) Rollback() error {
+	if h.open == nil {
+		return errors.New("No open group")
+	}
+	h.undoGroup(h.open)
+	h.open = nil
+	return nil
+}
+
+func (h *History) CanUndo() bool { return len(h.done) != 0 && h.open == nil }
+func (h *History) CanRedo() bool { return len(h.undone) != 0 && h.open == nil }
+
+// undoes the last group, returns it
+func (h *History) Undo() (*Group, error) {
+	if h.open != nil {
+		return nil, errors.New(fmt.Sprintf("Group '%s' is still open", h.open.Name))
+	}
+	if len(h.done) == 0 {
+		return nil, errors.New("Nothing to undo")
+	}
+	g := h.done[len(h.done)-1]
+	h.done = h.done[:len(h.done)-1]
+	h.undoGroup(g)
+	h.undone = append(h.undone, g)
+	return g, nil
+}
+
+// redoes the last undone group, returns it
+func (h *History) Redo() (*Group, error) {
+	if h.open != nil {
+		return nil, errors.New(fmt.Sprintf("Group '%s' is still open", h.open.Name))
+	}
+	if len(h.undone) == 0 {
+		return nil, errors.New("Nothing to redo")
+	}
+	g := h.undone[len(h.undone)-1]
+	h.undone = h.undone[:len(h.undone)-1]
+	for _, cmd := range g.commands {
+		h.apply(cmd.items, cmd.after)
+		for _, i := range cmd.added {
+			h.add(i)
+		}
+	}
+	h.done = append(h.done, g)
+	return g, nil
+}
+
+func (h *History) undoGroup(g *Group) {
+	for i := len(g.commands) - 1; i >= 0; i-- {
+		cmd := g.commands[i]
+		for _, a := range cmd.added {
+			h.remove(a)
+		}
+		h.apply(cmd.items, cmd.before)
+	}
+}
+
+// returns all groups which can be undone, oldest first
+func (h *History) Groups() []*Group {
+	return append([]*Group(nil), h.done...)
+}
+
+// returns all recorded (and not undone) changes, oldest first
+func (h *History) Changes() []Change {
+	var c []Change
+	for _, g := range h.done {
+		c = append(c, g.Changes()...)
+	}
+	return c
+}
+
+// returns a human readable summary of all recorded changes, one line per
+// change, grouped by the group names
+func (h *History) Summary() string {
+	var s []string
+	for _, g := range h.done {
+		name := g.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		s = append(s, name+":")
+		for _, c := range g.Changes() {
+			s = append(s, "  "+c.String())
+		}
+	}
+	if len(s) == 0 {
+		return ""
+	}
+	return strings.Join(s, "\n") + "\n"
+}
+
+// runs fn, which changes the given items, and records it as a command
+func (h *History) record(items []item.Item, fn func() ([]item.Item, []Change, error)) error {
+	cmd := &command{items: items}
+	for _, i := range items {
+		cmd.before = append(cmd.before, snapshot(i))
+	}
+	if h.o.TagIndex != nil {
+		for _, i := range items {
+			h.o.TagIndex.Remove(i)
+		}
+	}
+	added, changes, err := fn()
+	if h.o.TagIndex != nil {
+		for _, i := range items {
+			h.o.TagIndex.Add(i)
+		}
+	}
+	if err != nil {
+		h.apply(items, cmd.before)
+		return err
+	}
+	for _, i := range items {
+		h.store(i)
+		cmd.after = append(cmd.after, snapshot(i))
+	}
+	for _, i := range added {
+		h.add(i)
+	}
+	cmd.added = added
+	cmd.changes = changes
+
+	if h.open != nil {
+		h.open.commands = append(h.open.commands, cmd)
+		return nil
+	}
+	h.done = append(h.done, &Group{commands: []*command{cmd}})
+	h.undone = nil
+	return nil
+}
+
+// restores the items to a recorded state
+func (h *History) apply(items []item.Item, state []func()) {
+	for n, i := range items {
+		if h.o.TagIndex != nil {
+			h.o.TagIndex.Remove(i)
+		}
+		state[n]()
+		if h.o.TagIndex != nil {
+			h.o.TagIndex.Add(i)
+		}
+		h.store(i)
+	}
+}
+
+// nodes materialised from a NodeStore must be written back
+func (h *History) store(i item.Item) {
+	n, ok := i.(*node.Node)
+	if !ok || h.o.NodeStore == nil {
+		return
+	}
+	if _, ok := h.o.Nodes[n.Id_]; !ok {
+		h.o.NodeStore.Put(n)
+	}
+}
+
+func (h *History) add(i item.Item) {
+	switch i.(type) {
+	case *node.Node:
+		h.o.AddNode(i.(*node.Node))
+	case *way.Way:
+		h.o.AddWay(i.(*way.Way))
+	case *relation.Relation:
+		h.o.AddRelation(i.(*relation.Relation))
+	}
+}
+
+func (h *History) remove(i item.Item) {
+	if h.o.TagIndex != nil {
+		h.o.TagIndex.Remove(i)
+	}
+	switch i.(type) {
+	case *node.Node:
+		delete(h.o.Nodes, i.Id())
+		if h.o.NodeStore != nil {
+			h.o.NodeStore.Delete(i.Id())
+		}
+	case *way.Way:
+		delete(h.o.Ways, i.Id())
+	case *relation.Relation:
+		delete(h.o.Relations, i.Id())
+	}
+}
+
+// returns a func which restores the item to its current state. The item
+// structs are copied as a whole, so the internal modified / deleted state
+// is restored too. The position and the tags may be changed in place, the
+// item gets fresh copies of the saved ones on every restore: the Point and
+// Tags it had before may be shared with other items and are not written.
+func snapshot(i item.Item) func() {
+	switch v := i.(type) {
+	case *node.Node:
+		s := *v
+		pos := clonePoint(v.Position_)
+		t := v.Tags_.Clone()
+		return func() {
+			*v = s
+			v.Position_ = clonePoint(pos)
+			v.Tags_ = t.Clone()
+		}
+	case *way.Way:
+		s := *v
+		nodes := append([]*node.Node(nil), v.Nodes_...)
+		ids := append([]int64(nil), v.NodeIDs...)
+		t := v.Tags_.Clone()
+		return func() {
+			*v = s
+			v.Nodes_ = append([]*node.Node(nil), nodes...)
+			v.NodeIDs = append([]int64(nil), ids...)
+			v.Tags_ = t.Clone()
+		}
+	case *relation.Relation:
+		s := *v
+		members := append([]*relation.Member(nil), v.Members_...)
+		t := v.Tags_.Clone()
+		return func() {
+			*v = s
+			v.Members_ = append([]*relation.Member(nil), members...)
+			v.Tags_ = t.Clone()
+		}
+	}
+	panic("unknown item type")
+}
+
+func clonePoint(p *point.Point) *point.Point {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,236 @@
+package history
+
+import (
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"testing"
+)
+
+// an OSM with the way 10 over the nodes 1, 2, 3 and 5; the nodes 1 and 4
+// share their Point and their Tags
+func testOSM() *osm.OSM {
+	o := osm.NewOSM(nil)
+	p := point.New(50, 4)
+	t := &tags.Tags{"amenity": "bench"}
+	nodes := []*node.Node{
+		{Id_: 1, Position_: p, Tags_: t, Visible_: true},
+		{Id_: 2, Position_: point.New(50, 4.1), Tags_: tags.New(), Visible_: true},
+		{Id_: 3, Position_: point.New(50, 4.2), Tags_: tags.New(), Visible_: true},
+		{Id_: 4, Position_: p, Tags_: t, Visible_: true},
+		{Id_: 5, Position_: point.New(50, 4.3), Tags_: tags.New(), Visible_: true},
+	}
+	for _, n := range nodes {
+		o.AddNode(n)
+	}
+	o.AddWay(&way.Way{Id_: 10, Nodes_: []*node.Node{nodes[0], nodes[1], nodes[2], nodes[4]}, NodeIDs: []int64{1, 2, 3, 5}, Tags_: &tags.Tags{"highway": "residential"}, Visible_: true})
+	return o
+}
+
+func samePosition(p *point.Point, lat, lon float64) bool {
+	return p != nil && p.Lat == lat && p.Lon == lon
+}
+
+func TestUndoRedoMove(t *testing.T) {
+	o := testOSM()
+	h := New(o)
+	n1, n4 := o.GetNode(1), o.GetNode(4)
+	if err := h.MoveNode(n1, point.New(51, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.MoveNode(n1, point.New(52, 6)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if !samePosition(n1.Position_, 51, 5) {
+		t.Errorf("node 1 at %v after one undo, want 51,5", n1.Position_)
+	}
+	if _, err := h.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if !samePosition(n1.Position_, 50, 4) {
+		t.Errorf("node 1 at %v after two undos, want 50,4", n1.Position_)
+	}
+	// restoring node 1 must not write the Point shared with node 4
+	n1.Position_.Lat = 49
+	if !samePosition(n4.Position_, 50, 4) {
+		t.Errorf("node 4 at %v, changed through node 1", n4.Position_)
+	}
+	if _, err := h.Undo(); err == nil {
+		t.Error("no error when there is nothing to undo")
+	}
+
+	if _, err := h.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if !samePosition(n1.Position_, 52, 6) {
+		t.Errorf("node 1 at %v after two redos, want 52,6", n1.Position_)
+	}
+	if _, err := h.Redo(); err == nil {
+		t.Error("no error when there is nothing to redo")
+	}
+}
+
+func TestUndoSetTags(t *testing.T) {
+	o := testOSM()
+	o.BuildTagIndex()
+	h := New(o)
+	n1, n4 := o.GetNode(1), o.GetNode(4)
+	if err := h.SetTags(n1, &tags.Tags{"amenity": "waste_basket"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := o.NodesWithTag("amenity", "bench").Len(); got != 1 {
+		t.Errorf("%d benches after the change, want 1", got)
+	}
+	if _, err := h.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if got := n1.Tags_.Get("amenity"); got != "bench" {
+		t.Errorf("node 1 is a %q after undo, want bench", got)
+	}
+	if got := o.NodesWithTag("amenity", "bench").Len(); got != 2 {
+		t.Errorf("%d benches after undo, want 2", got)
+	}
+	// the restored tags are a copy, node 4 keeps its own
+	n1.Tags_.Add("amenity", "shelter")
+	if got := n4.Tags_.Get("amenity"); got != "bench" {
+		t.Errorf("node 4 is a %q, changed through node 1", got)
+	}
+	if _, err := h.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if got := n1.Tags_.Get("amenity"); got != "waste_basket" {
+		t.Errorf("node 1 is a %q after redo, want waste_basket", got)
+	}
+}
+
+// the tags of way 10 and node 2 are changed in place and passed back to
+// SetTags()
+func TestUndoSetTagsInPlace(t *testing.T) {
+	o := testOSM()
+	o.BuildTagIndex()
+	h := New(o)
+	w, n2 := o.GetWay(10), o.GetNode(2)
+	wt := w.Tags()
+	wt.Add("highway", "service")
+	wt.Add("name", "Kerkstraat")
+	if err := h.SetTags(w, wt); err != nil {
+		t.Fatal(err)
+	}
+	n2.Tags().Add("amenity", "bench")
+	if err := h.SetTags(n2, n2.Tags()); err != nil {
+		t.Fatal(err)
+	}
+	c := h.Changes()
+	if len(c) != 2 || c[0].Detail != "highway=residential->service +name=Kerkstraat" || c[1].Detail != "+amenity=bench" {
+		t.Errorf("changes %v", c)
+	}
+	if o.WaysWithTag("highway", "service").Len() != 1 || o.NodesWithTag("amenity", "bench").Len() != 3 {
+		t.Error("tag index not updated")
+	}
+
+	h.Undo()
+	h.Undo()
+	if w.Tags_.Get("highway") != "residential" || w.Tags_.Get("name") != "" || len(*n2.Tags_) != 0 {
+		t.Errorf("tags %v and %v after undo", w.Tags_, n2.Tags_)
+	}
+	if o.WaysWithTag("highway", "residential").Len() != 1 || o.WaysWithKey("name").Len() != 0 || o.NodesWithTag("amenity", "bench").Len() != 2 {
+		t.Error("tag index not restored by undo")
+	}
+	h.Redo()
+	if w.Tags_.Get("name") != "Kerkstraat" || o.WaysWithTag("highway", "service").Len() != 1 {
+		t.Errorf("tags %v after redo", w.Tags_)
+	}
+}
+
+func TestUndoGroup(t *testing.T) {
+	o := testOSM()
+	h := New(o)
+	w := o.GetWay(10)
+	if err := h.Begin("split and tag"); err != nil {
+		t.Fatal(err)
+	}
+	ws, err := h.SplitWay(w, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.SetTags(w, &tags.Tags{"highway": "service"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.Undo(); err == nil {
+		t.Error("no error for an undo while a group is open")
+	}
+	if err = h.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if len(ws) != 2 || o.GetWay(ws[1].Id_) == nil || len(w.Nodes_) != 2 {
+		t.Fatalf("split into %d ways, way 10 has %d nodes", len(ws), len(w.Nodes_))
+	}
+	if c := h.Changes(); len(c) != 2 || c[0].Action != "split" || c[1].Action != "tags" {
+		t.Errorf("changes %v, want a split and a tag change", c)
+	}
+
+	if _, err = h.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if o.GetWay(ws[1].Id_) != nil {
+		t.Errorf("new way #%d still in the OSM after undo", ws[1].Id_)
+	}
+	if len(w.Nodes_) != 4 || w.Tags_.Get("highway") != "residential" {
+		t.Errorf("way 10 has %d nodes and highway=%s after undo, want 4 and residential", len(w.Nodes_), w.Tags_.Get("highway"))
+	}
+	if _, err = h.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if o.GetWay(ws[1].Id_) == nil || len(w.Nodes_) != 2 || w.Tags_.Get("highway") != "service" {
+		t.Errorf("split and tag change not redone")
+	}
+
+	if err = h.Begin("rolled back"); err != nil {
+		t.Fatal(err)
+	}
+	if err = h.MoveNode(o.GetNode(3), point.New(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err = h.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if !samePosition(o.GetNode(3).Position_, 50, 4.2) {
+		t.Errorf("node 3 at %v after rollback, want 50,4.2", o.GetNode(3).Position_)
+	}
+	if len(h.Groups()) != 1 {
+		t.Errorf("%d groups, the rolled back one must not be recorded", len(h.Groups()))
+	}
+}
+
+// nodes of an OSM with a NodeStore
+func TestUndoCompact(t *testing.T) {
+	o := osm.NewCompactOSM(nil)
+	o.AddNode(&node.Node{Id_: 1, Position_: point.New(50, 4), Tags_: tags.New(), Visible_: true})
+	o.AddNode(&node.Node{Id_: 2, Tags_: tags.New(), Visible_: true})
+	h := New(o)
+	if err := h.MoveNode(o.GetNode(1), point.New(51, 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.MoveNode(o.GetNode(2), point.New(52, 6)); err != nil {
+		t.Fatal(err)
+	}
+	h.Undo()
+	h.Undo()
+	o.FlushNodes()
+	if p, ok := o.NodeStore.Position(1); !ok || !samePosition(p, 50, 4) {
+		t.Errorf("stored node 1 at %v after undo, want 50,4", p)
+	}
+	if n := o.GetNode(2); n == nil || n.Position_ != nil {
+		t.Errorf("node 2 has a position after undo")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,104 @@
+package item
+
+import (
+	"iter"
+)
+
+// the order used to sort NodeList, WayList and RelationList: new
+// (negative) ids on top in ascending math.Abs(id), followed by the other
+// ids ascending
+func IdLess(a, b int64) bool {
+	if a < 0 && b < 0 {
+		return a > b
+	}
+	if a < 0 && b >= 0 {
+		return true
+	}
+	if a >= 0 && b < 0 {
+		return false
+	}
+	return a < b
+}
+
+// returns the items of seq for which pred returns true
+func Filter[T Item](seq iter.Seq[T], pred func(T) bool) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for i := range seq {
+			if pred(i) && !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// returns the number of items in seq
+func Count[T Item](seq iter.Seq[T]) int {
+	c := 0
+	for range seq {
+		c++
+	}
+	return c
+}
+
+// returns the first item of seq and true, or false if seq is empty
+func First[T Item](seq iter.Seq[T]) (T, bool) {
+	for i := range seq {
+		return i, true
+	}
+	var zero T
+	return zero, false
+}
+
+// returns a predicate matching items of the given types
+func OfType[T Item](types ...ItemType) func(T) bool {
+	return func(i T) bool {
+		for _, t := range types {
+			if i.Type() == t {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// returns a predicate matching items having the key k
+func HasKey[T Item](k string) func(T) bool {
+	return func(i T) bool {
+		t := i.Tags()
+		return t != nil && t.Has(k)
+	}
+}
+
+// returns a predicate matching items with the tag k=v
+func HasTag[T Item](k, v string) func(T) bool {
+	return func(i T) bool {
+		t := i.Tags()
+		return t != nil && t.Has(k) && t.Get(k) == v
+	}
+}
+
+// returns a predicate matching items matching all given predicates
+func And[T Item](preds ...func(T) bool) func(T) bool {
+	return func(i T) bool {
+		for _, p := range preds {
+			if !p(i) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// returns a predicate matching items matching any of the given predicates
+func Or[T Item](preds ...func(T) bool) func(T) bool {
+	return func(i T) bool {
+		for _, p := range preds {
+			if p(i) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
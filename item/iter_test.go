@@ -0,0 +1,124 @@
+package item
+
+import (
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/user"
+	"iter"
+	"slices"
+	"testing"
+	"time"
+)
+
+// an Item with only a type, an id and tags
+type testItem struct {
+	t    ItemType
+	id   int64
+	tags *tags.Tags
+}
+
+func (i *testItem) Id() int64            { return i.id }
+func (i *testItem) Type() ItemType       { return i.t }
+func (i *testItem) User() *user.User     { return nil }
+func (i *testItem) Tags() *tags.Tags     { return i.tags }
+func (i *testItem) Timestamp() time.Time { return time.Time{} }
+func (i *testItem) Version() uint16      { return 0 }
+func (i *testItem) Changeset() uint64    { return 0 }
+func (i *testItem) Visible() bool        { return true }
+
+// two benches, a road, a relation without tags and a shelter; n counts
+// the items yielded
+func testItems(n *int) iter.Seq[Item] {
+	items := []Item{
+		&testItem{TypeNode, 1, &tags.Tags{"amenity": "bench"}},
+		&testItem{TypeWay, 2, &tags.Tags{"highway": "residential", "name": "x"}},
+		&testItem{TypeNode, 3, &tags.Tags{"amenity": "bench", "backrest": "no"}},
+		&testItem{TypeRelation, 4, nil},
+		&testItem{TypeWay, 5, &tags.Tags{"amenity": "shelter"}},
+	}
+	return func(yield func(Item) bool) {
+		for _, i := range items {
+			*n++
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+func ids(seq iter.Seq[Item]) []int64 {
+	var ids []int64
+	for i := range seq {
+		ids = append(ids, i.Id())
+	}
+	return ids
+}
+
+func TestFilter(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		pred func(Item) bool
+		want []int64
+	}{
+		{"key amenity", HasKey[Item]("amenity"), []int64{1, 3, 5}},
+		{"bench", HasTag[Item]("amenity", "bench"), []int64{1, 3}},
+		{"empty value", HasTag[Item]("backrest", ""), nil},
+		{"nodes", OfType[Item](TypeNode), []int64{1, 3}},
+		{"ways and relations", OfType[Item](TypeWay, TypeRelation), []int64{2, 4, 5}},
+		{"no types", OfType[Item](), nil},
+		{"amenity ways", And(HasKey[Item]("amenity"), OfType[Item](TypeWay)), []int64{5}},
+		{"and nothing", And[Item](), []int64{1, 2, 3, 4, 5}},
+		{"shelter or name", Or(HasTag[Item]("amenity", "shelter"), HasKey[Item]("name")), []int64{2, 5}},
+		{"or nothing", Or[Item](), nil},
+	} {
+		var n int
+		if got := ids(Filter(testItems(&n), c.pred)); !slices.Equal(got, c.want) {
+			t.Errorf("%s: %v, want %v", c.name, got, c.want)
+		}
+		var m int
+		if got := Count(Filter(testItems(&m), c.pred)); got != len(c.want) || n != 5 || m != 5 {
+			t.Errorf("%s: count %d of %d and %d items, want %d of 5", c.name, got, n, m, len(c.want))
+		}
+	}
+}
+
+// the source is not read any further after a break
+func TestFilterBreak(t *testing.T) {
+	var n int
+	for i := range Filter(testItems(&n), OfType[Item](TypeWay)) {
+		if i.Id() != 2 {
+			t.Errorf("first way %d, want 2", i.Id())
+		}
+		break
+	}
+	if n != 2 {
+		t.Errorf("%d items read after a break at the second, want 2", n)
+	}
+
+	n = 0
+	if i, ok := First(Filter(testItems(&n), HasTag[Item]("amenity", "bench"))); !ok || i.Id() != 1 || n != 1 {
+		t.Errorf("first bench %v %v after %d items, want 1 after 1", i, ok, n)
+	}
+	n = 0
+	if i, ok := First(Filter(testItems(&n), HasKey[Item]("fixme"))); ok || i != nil || n != 5 {
+		t.Errorf("first fixme %v %v after %d items, want none after 5", i, ok, n)
+	}
+}
+
+// new items first, by ascending math.Abs(id), then the others
+func TestIdLess(t *testing.T) {
+	ids := []int64{3, -1, 0, 1, -3, 2, -2}
+	slices.SortFunc(ids, func(a, b int64) int {
+		switch {
+		case IdLess(a, b):
+			return -1
+		case IdLess(b, a):
+			return 1
+		}
+		return 0
+	})
+	if want := []int64{-1, -2, -3, 0, 1, 2, 3}; !slices.Equal(ids, want) {
+		t.Errorf("sorted %v, want %v", ids, want)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,163 @@
+package osm
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/way"
+	"iter"
+	"slices"
+)
+
+// The iterators walk the data without copying it into a list first, the
+// Sorted* variants only collect and sort the ids (in the order of
+// item.IdLess(), as used by NodeList, WayList and RelationList). Nodes from
+// a NodeStore are materialised one by one and not kept in the Nodes map,
+// changes to them must be written back with AddNode().
+//
+// The OSM must not be changed while iterating, e.g.
+//
+//	for w := range item.Filter(o.AllWays(), item.HasKey[*way.Way]("highway")) {
+//		...
+//	}
+
+// returns all nodes in no particular order
+func (o *OSM) AllNodes() iter.Seq[*node.Node] {
+	return func(yield func(*node.Node) bool) {
+		for _, n := range o.Nodes {
+			if !yield(n) {
+				return
+			}
+		}
+		if o.NodeStore == nil {
+			return
+		}
+		o.NodeStore.Each(func(n *node.Node) bool {
+			if _, ok := o.Nodes[n.Id_]; ok {
+				return true
+			}
+			return yield(n)
+		})
+	}
+}
+
+// returns all ways in no particular order
+func (o *OSM) AllWays() iter.Seq[*way.Way] {
+	return func(yield func(*way.Way) bool) {
+		for _, w := range o.Ways {
+			if !yield(w) {
+				return
+			}
+		}
+	}
+}
+
+// returns all relations in no particular order
+func (o *OSM) AllRelations() iter.Seq[*relation.Relation] {
+	return func(yield func(*relation.Relation) bool) {
+		for _, r := range o.Relations {
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
+
+// returns all nodes, then all ways and then all relations
+func (o *OSM) All() iter.Seq[item.Item] {
+	return func(yield func(item.Item) bool) {
+		for n := range o.AllNodes() {
+			if !yield(n) {
+				return
+			}
+		}
+		for w := range o.AllWays() {
+			if !yield(w) {
+				return
+			}
+		}
+		for r := range o.AllRelations() {
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
+
+func sortedIds[T any](m map[int64]T, extra []int64) []int64 {
+	ids := make([]int64, 0, len(m)+len(extra))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	ids = append(ids, extra...)
+	slices.SortFunc(ids, func(a, b int64) int {
+		switch {
+		case item.IdLess(a, b):
+			return -1
+		case item.IdLess(b, a):
+			return 1
+		}
+		return 0
+	})
+	return slices.Compact(ids)
+}
+
+// returns all nodes sorted by id
+func (o *OSM) SortedNodes() iter.Seq[*node.Node] {
+	return func(yield func(*node.Node) bool) {
+		var extra []int64
+		if o.NodeStore != nil {
+			extra = o.NodeStore.Ids()
+		}
+		for _, id := range sortedIds(o.Nodes, extra) {
+			if !yield(o.peekNode(id)) {
+				return
+			}
+		}
+	}
+}
+
+// returns all ways sorted by id
+func (o *OSM) SortedWays() iter.Seq[*way.Way] {
+	return func(yield func(*way.Way) bool) {
+		for _, id := range sortedIds(o.Ways, nil) {
+			if !yield(o.Ways[id]) {
+				return
+			}
+		}
+	}
+}
+
+// returns all relations sorted by id
+func (o *OSM) SortedRelations() iter.Seq[*relation.Relation] {
+	return func(yield func(*relation.Relation) bool) {
+		for _, id := range sortedIds(o.Relations, nil) {
+			if !yield(o.Relations[id]) {
+				return
+			}
+		}
+	}
+}
+
+// returns all nodes, ways and relations, each sorted by id
+func (o *OSM) SortedAll() iter.Seq[item.Item] {
+	return func(yield func(item.Item) bool) {
+		for n := range o.SortedNodes() {
+			if !yield(n) {
+				return
+			}
+		}
+		for w := range o.SortedWays() {
+			if !yield(w) {
+				return
+			}
+		}
+		for r := range o.SortedRelations() {
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,146 @@
+package osm
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"iter"
+	"slices"
+	"testing"
+)
+
+func itemIds[T item.Item](seq iter.Seq[T]) []int64 {
+	var ids []int64
+	for i := range seq {
+		ids = append(ids, i.Id())
+	}
+	return ids
+}
+
+// the ids of the first n items of seq, the iteration is stopped after
+// the n-th
+func firstIds[T item.Item](seq iter.Seq[T], n int) []int64 {
+	var ids []int64
+	for i := range seq {
+		ids = append(ids, i.Id())
+		if len(ids) == n {
+			break
+		}
+	}
+	return ids
+}
+
+// an OSM with new (negative) and existing ids
+func iterOSM() *OSM {
+	o := NewOSM(nil)
+	for _, id := range []int64{3, -2, 1, -1, 2} {
+		o.AddNode(testNode(id, 50, 4))
+	}
+	o.AddWay(testWay(11, nil))
+	o.AddWay(testWay(-10, nil))
+	o.AddWay(testWay(10, nil))
+	o.AddRelation(&relation.Relation{Id_: 20, Tags_: tags.New()})
+	return o
+}
+
+func TestSortedIterators(t *testing.T) {
+	o := iterOSM()
+	for _, c := range []struct {
+		name string
+		got  []int64
+		want []int64
+	}{
+		{"nodes", itemIds(o.SortedNodes()), []int64{-1, -2, 1, 2, 3}},
+		{"ways", itemIds(o.SortedWays()), []int64{-10, 10, 11}},
+		{"relations", itemIds(o.SortedRelations()), []int64{20}},
+		{"all", itemIds(o.SortedAll()), []int64{-1, -2, 1, 2, 3, -10, 10, 11, 20}},
+		{"2 nodes", firstIds(o.SortedNodes(), 2), []int64{-1, -2}},
+		{"2 ways", firstIds(o.SortedWays(), 2), []int64{-10, 10}},
+		{"all up to a node", firstIds(o.SortedAll(), 3), []int64{-1, -2, 1}},
+		{"all up to a way", firstIds(o.SortedAll(), 6), []int64{-1, -2, 1, 2, 3, -10}},
+		{"all up to the relation", firstIds(o.SortedAll(), 9), []int64{-1, -2, 1, 2, 3, -10, 10, 11, 20}},
+		{"empty", itemIds(NewOSM(nil).SortedAll()), nil},
+	} {
+		if !slices.Equal(c.got, c.want) {
+			t.Errorf("%s: %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestAllIterators(t *testing.T) {
+	o := iterOSM()
+	all := itemIds(o.All())
+	if len(all) != 9 {
+		t.Fatalf("all %v, want 9 items", all)
+	}
+	// nodes, then ways, then relations
+	nodes, ways, relations := all[:5], all[5:8], all[8:]
+	slices.Sort(nodes)
+	slices.Sort(ways)
+	if !slices.Equal(nodes, []int64{-2, -1, 1, 2, 3}) || !slices.Equal(ways, []int64{-10, 10, 11}) || relations[0] != 20 {
+		t.Errorf("all %v, want the nodes, the ways and the relation", all)
+	}
+	if n := item.Count(o.AllNodes()); n != 5 {
+		t.Errorf("%d nodes, want 5", n)
+	}
+	if n := item.Count(o.AllWays()); n != 3 {
+		t.Errorf("%d ways, want 3", n)
+	}
+	if n := item.Count(o.AllRelations()); n != 1 {
+		t.Errorf("%d relations, want 1", n)
+	}
+	for _, c := range []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"nodes", len(firstIds(o.AllNodes(), 2)), 2},
+		{"ways", len(firstIds(o.AllWays(), 1)), 1},
+		{"all up to a way", len(firstIds(o.All(), 6)), 6},
+		{"all up to the relation", len(firstIds(o.All(), 9)), 9},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: %d items before the break, want %d", c.name, c.got, c.want)
+		}
+	}
+	if w, ok := item.First(item.Filter(o.SortedWays(), item.OfType[*way.Way](item.TypeWay))); !ok || w.Id_ != -10 {
+		t.Errorf("first way %v %v, want -10", w, ok)
+	}
+}
+
+// a node materialised from the NodeStore and changed in the Nodes map is
+// returned once, as it is in the Nodes map
+func TestCompactIterators(t *testing.T) {
+	o := compactOSM()
+	n1 := o.GetNode(1)
+	n1.Tags_.Add("name", "x")
+	o.AddNode(testNode(-1, 50, 4))
+	o.AddNode(testNode(5, 50, 4))
+	kept := len(o.Nodes)
+	if ids := itemIds(o.SortedNodes()); !slices.Equal(ids, []int64{-1, 1, 2, 3, 4, 5}) {
+		t.Errorf("sorted nodes %v", ids)
+	}
+	ids := itemIds(o.AllNodes())
+	slices.Sort(ids)
+	if !slices.Equal(ids, []int64{-1, 1, 2, 3, 4, 5}) {
+		t.Errorf("all nodes %v", ids)
+	}
+	for n := range o.SortedNodes() {
+		if n.Id_ == 1 && n != n1 {
+			t.Error("node 1 is not the node of the Nodes map")
+		}
+	}
+	if n, ok := item.First(item.Filter(o.AllNodes(), item.HasKey[*node.Node]("name"))); !ok || n != n1 {
+		t.Errorf("first node with a name %v %v, want node 1", n, ok)
+	}
+	if len(o.Nodes) != kept {
+		t.Errorf("%d nodes in the Nodes map, the iterators must not keep materialised nodes", len(o.Nodes))
+	}
+	if ids := firstIds(o.SortedAll(), 7); !slices.Equal(ids, []int64{-1, 1, 2, 3, 4, 5, 10}) {
+		t.Errorf("sorted items up to the first way %v", ids)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,63 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/point"
+)
+
+// the dense indexes grow in chunks of this many entries, at least to
+// twice their size
+const denseChunk = 1 << 20
+
+// the new number of entries of a dense index of size n to store id
+func denseGrow(n, id int64) int64 {
+	return max((id/denseChunk+1)*denseChunk, 2*n)
+}
+
+// DenseMem keeps the positions in an array indexed by the node id. It uses
+// 8 bytes for every id up to the largest one set, so it is the best choice
+// for input covering a big part of the id range. Negative ids cannot be
+// stored.
+type DenseMem struct {
+	pos   []uint64
+	count int
+}
+
+func NewDenseMem() *DenseMem {
+	return &DenseMem{}
+}
+
+func (d *DenseMem) Set(id int64, p *point.Point) error {
+	if id < 0 {
+		return errors.New(fmt.Sprintf("Cannot store negative node id #%d in dense index", id))
+	}
+	if id >= int64(len(d.pos)) {
+		n := make([]uint64, denseGrow(int64(len(d.pos)), id))
+		copy(n, d.pos)
+		d.pos = n
+	}
+	if d.pos[id] == 0 {
+		d.count++
+	}
+	d.pos[id] = encode(p)
+	return nil
+}
+
+func (d *DenseMem) Get(id int64) (*point.Point, bool) {
+	if id < 0 || id >= int64(len(d.pos)) {
+		return nil, false
+	}
+	return decode(d.pos[id])
+}
+
+func (d *DenseMem) Len() int {
+	return d.count
+}
+
+func (d *DenseMem) Close() error {
+	d.pos = nil
+	return nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
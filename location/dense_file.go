@@ -0,0 +1,105 @@
+//go:build unix
+
+package location
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/point"
+	"os"
+	"syscall"
+)
+
+// DenseFile is like DenseMem, but the array is a memory mapped file, so
+// the operating system can page out parts of it. The file is created if
+// it does not exist, an existing file is reused (e.g. to keep the index
+// of a previous run).
+type DenseFile struct {
+	fh    *os.File
+	data  []byte
+	count int
+}
+
+func NewDenseFile(path string) (*DenseFile, error) {
+	fh, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	d := &DenseFile{fh: fh}
+	fi, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return nil, err
+	}
+	if fi.Size()%8 != 0 {
+		fh.Close()
+		return nil, errors.New(fmt.Sprintf("Size %d of %s is not a multiple of 8, not a dense index", fi.Size(), path))
+	}
+	if fi.Size() > 0 {
+		if err = d.mmap(fi.Size()); err != nil {
+			fh.Close()
+			return nil, err
+		}
+		for i := 0; i < len(d.data); i += 8 {
+			if binary.LittleEndian.Uint64(d.data[i:]) != 0 {
+				d.count++
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *DenseFile) mmap(size int64) (err error) {
+	if d.data != nil {
+		if err = syscall.Munmap(d.data); err != nil {
+			return
+		}
+		d.data = nil
+	}
+	if err = d.fh.Truncate(size); err != nil {
+		return
+	}
+	d.data, err = syscall.Mmap(int(d.fh.Fd()), 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	return
+}
+
+func (d *DenseFile) Set(id int64, p *point.Point) error {
+	if id < 0 {
+		return errors.New(fmt.Sprintf("Cannot store negative node id #%d in dense index", id))
+	}
+	if off := id * 8; off >= int64(len(d.data)) {
+		if err := d.mmap(denseGrow(int64(len(d.data)/8), id) * 8); err != nil {
+			return err
+		}
+	}
+	b := d.data[id*8 : id*8+8]
+	if binary.LittleEndian.Uint64(b) == 0 {
+		d.count++
+	}
+	binary.LittleEndian.PutUint64(b, encode(p))
+	return nil
+}
+
+func (d *DenseFile) Get(id int64) (*point.Point, bool) {
+	if id < 0 || id*8 >= int64(len(d.data)) {
+		return nil, false
+	}
+	return decode(binary.LittleEndian.Uint64(d.data[id*8:]))
+}
+
+func (d *DenseFile) Len() int {
+	return d.count
+}
+
+func (d *DenseFile) Close() error {
+	if d.data != nil {
+		if err := syscall.Munmap(d.data); err != nil {
+			return err
+		}
+		d.data = nil
+	}
+	return d.fh.Close()
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package location
+
+import (
+	"errors"
+	"github.com/brechtvm/osm/point"
+)
+
+// DenseFile needs mmap(2), which is not available on this platform
+type DenseFile struct{}
+
+func NewDenseFile(path string) (*DenseFile, error) {
+	return nil, errors.New("dense_file index is not supported on this platform")
+}
+
+func (d *DenseFile) Set(id int64, p *point.Point) error { return errors.New("not supported") }
+func (d *DenseFile) Get(id int64) (*point.Point, bool)  { return nil, false }
+func (d *DenseFile) Len() int                           { return 0 }
+func (d *DenseFile) Close() error                       { return nil }
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,79 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/point"
+	"strings"
+)
+
+// A node location index stores only the position of nodes. It is filled
+// by the parsers (see pbf.LocationParser()) and used to set the node
+// positions of ways in handler mode, where the nodes are not kept in
+// memory by the osm.OSM.
+type Index interface {
+	// stores the position of the node with the given id
+	Set(id int64, p *point.Point) error
+	// returns the position of the node with the given id
+	Get(id int64) (*point.Point, bool)
+	// the number of stored positions
+	Len() int
+	// releases all resources, the index must not be used afterwards
+	Close() error
+}
+
+// Returns a new Index for the given spec, like osmium's location index
+// types:
+//
+//	sparse_mem        - sorted id/position arrays, for extracts
+//	dense_mem         - array indexed by node id, for large extracts
+//	dense_file,PATH   - memory mapped array indexed by node id, for
+//	                    planet sized input
+func Open(spec string) (Index, error) {
+	s := strings.SplitN(spec, ",", 2)
+	switch s[0] {
+	case "sparse_mem":
+		return NewSparseMem(), nil
+	case "dense_mem":
+		return NewDenseMem(), nil
+	case "dense_file":
+		if len(s) != 2 || s[1] == "" {
+			return nil, errors.New("dense_file index needs a file name")
+		}
+		f, err := NewDenseFile(s[1])
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown location index type '%s'", s[0]))
+}
+
+// positions are stored as fixed point values with 7 decimal places, the
+// latitude with an offset so that 0 can be used as "not set"
+const (
+	coordScale = 1e7
+	latOffset  = 900000001
+)
+
+func toFixed(v float64) int32 {
+	if v < 0 {
+		return int32(v*coordScale - 0.5)
+	}
+	return int32(v*coordScale + 0.5)
+}
+
+func encode(p *point.Point) uint64 {
+	return uint64(uint32(toFixed(p.Lat)+latOffset))<<32 | uint64(uint32(toFixed(p.Lon)))
+}
+
+func decode(v uint64) (*point.Point, bool) {
+	if v == 0 {
+		return nil, false
+	}
+	lat := int32(uint32(v>>32)) - latOffset
+	lon := int32(uint32(v))
+	return point.New(float64(lat)/coordScale, float64(lon)/coordScale), true
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,107 @@
+package location
+
+import (
+	"github.com/brechtvm/osm/point"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// the positions are stored to 7 decimal places
+func samePosition(p *point.Point, lat, lon float64) bool {
+	return p != nil && math.Abs(p.Lat-lat) <= 0.5e-7 && math.Abs(p.Lon-lon) <= 0.5e-7
+}
+
+func testIndex(t *testing.T, spec string) {
+	idx, err := Open(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idx.Close()
+	big := int64(denseChunk + 5)
+	set := []struct {
+		id       int64
+		lat, lon float64
+	}{
+		{big, 90, 180},
+		{1, 50.84661234, 4.35281234},
+		{3, -90, -180},
+		{2, 0, 0},
+		// set again, the last position is kept
+		{1, 51, 5},
+	}
+	for _, s := range set {
+		if err := idx.Set(s.id, point.New(s.lat, s.lon)); err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+	}
+	for _, s := range set[1:] {
+		p, ok := idx.Get(s.id)
+		if s.id == 1 && s.lat != 51 {
+			continue
+		}
+		if !ok || !samePosition(p, s.lat, s.lon) {
+			t.Errorf("%s: Get(%d) = %v %v, want %v,%v", spec, s.id, p, ok, s.lat, s.lon)
+		}
+	}
+	if p, ok := idx.Get(big); !ok || !samePosition(p, 90, 180) {
+		t.Errorf("%s: Get(%d) = %v %v, want 90,180", spec, big, p, ok)
+	}
+	for _, id := range []int64{4, -1, 2 * big} {
+		if p, ok := idx.Get(id); ok {
+			t.Errorf("%s: Get(%d) = %v for an id which was not set", spec, id, p)
+		}
+	}
+	if l := idx.Len(); l != 4 {
+		t.Errorf("%s: Len() = %d, want 4", spec, l)
+	}
+}
+
+func TestSparseMem(t *testing.T) {
+	testIndex(t, "sparse_mem")
+	// negative ids are allowed
+	s := NewSparseMem()
+	s.Set(-5, point.New(1, 1))
+	if p, ok := s.Get(-5); !ok || !samePosition(p, 1, 1) {
+		t.Errorf("Get(-5) = %v %v, want 1,1", p, ok)
+	}
+}
+
+func TestDenseMem(t *testing.T) {
+	testIndex(t, "dense_mem")
+	if err := NewDenseMem().Set(-1, point.New(0, 0)); err == nil {
+		t.Error("no error for a negative id")
+	}
+}
+
+func TestDenseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nodes.idx")
+	testIndex(t, "dense_file,"+path)
+	// the positions are kept in the file
+	d, err := NewDenseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if p, ok := d.Get(3); !ok || !samePosition(p, -90, -180) || d.Len() != 4 {
+		t.Errorf("reopened index: Get(3) = %v %v, Len() = %d", p, ok, d.Len())
+	}
+	if err := d.Set(-1, point.New(0, 0)); err == nil {
+		t.Error("no error for a negative id")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, spec := range []string{"dense_file", "dense_file,", "btree"} {
+		if _, err := Open(spec); err == nil {
+			t.Errorf("no error for %q", spec)
+		}
+	}
+	// a failed open returns a nil interface, not a nil *DenseFile
+	idx, err := Open("dense_file," + t.TempDir() + "/missing/nodes.idx")
+	if err == nil || idx != nil {
+		t.Errorf("Open of a file in a missing directory returned %v %v, want nil and an error", idx, err)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,83 @@
+package location
+
+import (
+	"github.com/brechtvm/osm/point"
+	"sort"
+)
+
+// SparseMem keeps id and position in two arrays sorted by id. It uses 16
+// bytes per node and is the best choice when only a small part of the
+// id range is used, e.g. for extracts.
+type SparseMem struct {
+	ids   []int64
+	pos   []uint64
+	dirty bool
+}
+
+func NewSparseMem() *SparseMem {
+	return &SparseMem{}
+}
+
+type byId SparseMem
+
+func (s *byId) Len() int           { return len(s.ids) }
+func (s *byId) Less(i, j int) bool { return s.ids[i] < s.ids[j] }
+func (s *byId) Swap(i, j int) {
+	s.ids[i], s.ids[j] = s.ids[j], s.ids[i]
+	s.pos[i], s.pos[j] = s.pos[j], s.pos[i]
+}
+
+func (s *SparseMem) sort() {
+	if !s.dirty {
+		return
+	}
+	sort.Stable((*byId)(s))
+	// if an id was set more than once, keep the last position
+	w := 0
+	for r := range s.ids {
+		if r+1 < len(s.ids) && s.ids[r] == s.ids[r+1] {
+			continue
+		}
+		s.ids[w], s.pos[w] = s.ids[r], s.pos[r]
+		w++
+	}
+	s.ids, s.pos = s.ids[:w], s.pos[:w]
+	s.dirty = false
+}
+
+// sorts positions added out of order, this is done on the next lookup
+// anyway. Call this before the index is read concurrently, as lookups on
+// an unsorted index change it.
+func (s *SparseMem) Sort() {
+	s.sort()
+}
+
+func (s *SparseMem) Set(id int64, p *point.Point) error {
+	if l := len(s.ids); l > 0 && id <= s.ids[l-1] {
+		s.dirty = true
+	}
+	s.ids = append(s.ids, id)
+	s.pos = append(s.pos, encode(p))
+	return nil
+}
+
+func (s *SparseMem) Get(id int64) (*point.Point, bool) {
+	s.sort()
+	i := sort.Search(len(s.ids), func(i int) bool { return s.ids[i] >= id })
+	if i < len(s.ids) && s.ids[i] == id {
+		return decode(s.pos[i])
+	}
+	return nil, false
+}
+
+func (s *SparseMem) Len() int {
+	s.sort()
+	return len(s.ids)
+}
+
+func (s *SparseMem) Close() error {
+	s.ids, s.pos = nil, nil
+	return nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
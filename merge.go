@@ -3,14 +3,9 @@ package osm
 import ()
 
 func (self *OSM) Merge(other *OSM) {
-	for _, n := range other.Nodes {
-		nid := n.Id()
-		if self.Nodes[nid] == nil {
-			self.Nodes[nid] = n
-			continue
-		}
-		if self.Nodes[nid].MergeOther(n) {
-			self.Nodes[nid] = n
+	for n := range other.AllNodes() {
+		if old := self.peekNode(n.Id()); old == nil || old.MergeOther(n) {
+			self.AddNode(n)
 		}
 	}
 	for _, w := range other.Ways {
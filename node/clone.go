@@ -0,0 +1,22 @@
+package node
+
+import (
+	"github.com/brechtvm/osm/user"
+)
+
+// returns a deep copy of the node with the same id, the position, the
+// user and the tags are copied too
+func (n *Node) DeepCopy() *Node {
+	c := *n
+	if n.Position_ != nil {
+		p := *n.Position_
+		c.Position_ = &p
+	}
+	if n.User_ != nil {
+		c.User_ = user.New(n.User_.Id, n.User_.Name)
+	}
+	c.Tags_ = n.Tags_.Clone()
+	return &c
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
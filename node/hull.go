@@ -0,0 +1,68 @@
+package node
+
+import (
+	"errors"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+	"sort"
+)
+
+// Returns the convex hull of the node positions as closed counter
+// clockwise ring (monotone chain algorithm, on lat/lon as planar
+// coordinates). An error is returned if there are less than 3 distinct
+// positions or if all positions are on a line.
+func (nl NodeList) ConvexHull() (polygon.Ring, error) {
+	pl := make([]*point.Point, 0, len(nl))
+	for _, n := range nl {
+		if n.Position_ != nil {
+			pl = append(pl, n.Position_)
+		}
+	}
+	sort.Slice(pl, func(i, j int) bool {
+		if pl[i].Lon != pl[j].Lon {
+			return pl[i].Lon < pl[j].Lon
+		}
+		return pl[i].Lat < pl[j].Lat
+	})
+	// drop duplicate positions
+	distinct := pl[:0]
+	for _, p := range pl {
+		if len(distinct) == 0 || !p.Equal(distinct[len(distinct)-1]) {
+			distinct = append(distinct, p)
+		}
+	}
+	pl = distinct
+	if len(pl) < 3 {
+		return nil, errors.New("Too few points for a convex hull")
+	}
+	cross := func(o, a, b *point.Point) float64 {
+		return (a.Lon-o.Lon)*(b.Lat-o.Lat) - (a.Lat-o.Lat)*(b.Lon-o.Lon)
+	}
+	var hull []*point.Point
+	// lower hull, then upper hull
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for i := range pl {
+			p := pl[i]
+			if pass == 1 {
+				p = pl[len(pl)-1-i]
+			}
+			for len(hull) >= start+2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		// the last point is the first one of the next part
+		hull = hull[:len(hull)-1]
+	}
+	if len(hull) < 3 {
+		return nil, errors.New("Too few points for a convex hull")
+	}
+	r := make(polygon.Ring, 0, len(hull)+1)
+	for _, p := range hull {
+		r = append(r, point.New(p.Lat, p.Lon))
+	}
+	return append(r, point.New(hull[0].Lat, hull[0].Lon)), nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
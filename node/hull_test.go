@@ -0,0 +1,88 @@
+package node
+
+import (
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+	"testing"
+)
+
+// nodes at the positions given as lat, lon pairs, the ids are the indexes
+func testNodes(ll ...float64) NodeList {
+	var nl NodeList
+	for i := 0; i+1 < len(ll); i += 2 {
+		nl = append(nl, &Node{Id_: int64(i / 2), Position_: point.New(ll[i], ll[i+1])})
+	}
+	return nl
+}
+
+// checks if the ring is over the positions given as lat, lon pairs
+func sameRing(r polygon.Ring, ll ...float64) bool {
+	if len(r)*2 != len(ll) {
+		return false
+	}
+	for i, p := range r {
+		if p.Lat != ll[2*i] || p.Lon != ll[2*i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestConvexHull(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		nl   NodeList
+		want []float64
+	}{
+		{"triangle", testNodes(0, 0, 2, 1, 0, 2), []float64{0, 0, 0, 2, 2, 1, 0, 0}},
+		// counter clockwise from the westernmost (then southernmost) point
+		{"clockwise triangle", testNodes(2, 1, 0, 2, 0, 0), []float64{0, 0, 0, 2, 2, 1, 0, 0}},
+		{"inside", testNodes(0, 0, 0, 2, 2, 2, 2, 0, 1, 1, 0.5, 1.5), []float64{0, 0, 0, 2, 2, 2, 2, 0, 0, 0}},
+		// points on the edges are not part of the hull
+		{"on the edges", testNodes(0, 0, 0, 1, 0, 2, 1, 2, 2, 2, 2, 1, 2, 0, 1, 0), []float64{0, 0, 0, 2, 2, 2, 2, 0, 0, 0}},
+		{"duplicates", testNodes(0, 0, 0, 0, 2, 1, 0, 2, 2, 1, 0, 0), []float64{0, 0, 0, 2, 2, 1, 0, 0}},
+		// collinear points and a duplicate on the western edge
+		{"western edge", testNodes(0, 0, 1, 0, 2, 0, 2, 2, 1, 0), []float64{0, 0, 2, 2, 2, 0, 0, 0}},
+	} {
+		r, err := c.nl.ConvexHull()
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !sameRing(r, c.want...) {
+			t.Errorf("%s: hull %v, want %v", c.name, r, c.want)
+		}
+	}
+
+	withoutPosition := testNodes(0, 0, 0, 2, 2, 1)
+	withoutPosition = append(withoutPosition, &Node{Id_: 3})
+	if r, err := withoutPosition.ConvexHull(); err != nil || !sameRing(r, 0, 0, 0, 2, 2, 1, 0, 0) {
+		t.Errorf("hull of nodes with one without position is %v %v", r, err)
+	}
+
+	for _, c := range []struct {
+		name string
+		nl   NodeList
+	}{
+		{"none", nil},
+		{"two", testNodes(0, 0, 1, 1)},
+		{"the same position", testNodes(1, 1, 1, 1, 1, 1)},
+		{"two positions", testNodes(0, 0, 1, 1, 0, 0, 1, 1)},
+		{"collinear", testNodes(0, 0, 1, 1, 2, 2, 3, 3)},
+		{"collinear unsorted", testNodes(2, 2, 0, 0, 3, 3, 1, 1, 2, 2)},
+		{"vertical", testNodes(0, 5, 2, 5, 1, 5)},
+	} {
+		if r, err := c.nl.ConvexHull(); err == nil {
+			t.Errorf("%s: no error, hull %v", c.name, r)
+		}
+	}
+	// the positions of the nodes are not reused or changed
+	nl := testNodes(0, 0, 0, 2, 2, 1)
+	r, _ := nl.ConvexHull()
+	r[0].Lat = 5
+	if nl[0].Position_.Lat != 0 {
+		t.Error("changing the hull changed a node")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
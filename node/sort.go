@@ -1,26 +1,14 @@
 package node
 
-import ()
+import (
+	"github.com/brechtvm/osm/item"
+)
 
-// sort negative ids on top of all other in ascending math.Abs(Id)
+// sort negative ids on top of all other in ascending math.Abs(Id), see
+// item.IdLess()
 func (nl *NodeList) Less(i, j int) bool {
 	n := []*Node(*nl)
-	if n[i].Id_ < 0 && n[j].Id_ < 0 {
-		if n[i].Id_ > n[j].Id_ {
-			return true
-		}
-		return false
-	}
-	if n[i].Id_ < 0 && n[j].Id_ > 0 {
-		return false
-	}
-	if n[i].Id_ > 0 && n[j].Id_ < 0 {
-		return true
-	}
-	if n[i].Id_ < n[j].Id_ {
-		return true
-	}
-	return false
+	return item.IdLess(n[i].Id_, n[j].Id_)
 }
 
 func (nl *NodeList) Len() int {
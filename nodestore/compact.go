@@ -0,0 +1,398 @@
+package nodestore
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/user"
+	"sort"
+	"strings"
+	"time"
+)
+
+// coordinates are stored as fixed point values with 7 decimal places, the
+// same precision as used in PBF files and by the OSM API
+const CoordScale = 1e7
+
+const (
+	flagVisible uint8 = 1 << iota
+	flagDeleted
+	flagNoPosition
+)
+
+// Compact is a columnar node storage: instead of one heap object per node
+// all attributes are kept in parallel slices sorted by id. Users and tag
+// sets are interned, i.e. stored once and referenced by index.
+//
+// Nodes returned by Get() and Each() are materialised copies: changes to
+// them are not seen by the store until they are written back with Put().
+// An OSM with a NodeStore keeps the nodes it hands out in its Nodes map,
+// see OSM.GetNode().
+//
+// Timestamps are stored in seconds since the unix epoch, like in OSM
+// files, fractions of a second are lost.
+type Compact struct {
+	ids        []int64
+	lats       []int32
+	lons       []int32
+	versions   []uint16
+	changesets []uint64
+	timestamps []int64
+	users      []uint32
+	tagSets    []uint32
+	flags      []uint8
+	dirty      bool
+	deleted    int
+
+	userList  []*user.User
+	userIndex map[uint32]uint32
+	tagList   []*tags.Tags
+	tagIndex  map[string]uint32
+}
+
+// returns a new and empty Compact store
+func NewCompact() *Compact {
+	return &Compact{
+		userIndex: make(map[uint32]uint32),
+		// tag set 0 is the empty tag set
+		tagList:  []*tags.Tags{tags.New()},
+		tagIndex: map[string]uint32{"": 0},
+	}
+}
+
+func toFixed(v float64) int32 {
+	if v < 0 {
+		return int32(v*CoordScale - 0.5)
+	}
+	return int32(v*CoordScale + 0.5)
+}
+
+func fromFixed(v int32) float64 {
+	return float64(v) / CoordScale
+}
+
+func (c *Compact) internUser(u *user.User) uint32 {
+	if u == nil {
+		u = user.New(0, "")
+	}
+	if i, ok := c.userIndex[u.Id]; ok {
+		if c.userList[i].Name == u.Name {
+			return i
+		}
+	}
+	i := uint32(len(c.userList))
+	c.userList = append(c.userList, user.New(u.Id, u.Name))
+	c.userIndex[u.Id] = i
+	return i
+}
+
+func tagKey(t *tags.Tags) string {
+	if t == nil || len(*t) == 0 {
+		return ""
+	}
+	kv := make([]string, 0, len(*t))
+	for k, v := range *t {
+		kv = append(kv, k+"\x00"+v)
+	}
+	sort.Strings(kv)
+	return strings.Join(kv, "\x01")
+}
+
+func (c *Compact) internTags(t *tags.Tags) uint32 {
+	key := tagKey(t)
+	if i, ok := c.tagIndex[key]; ok {
+		return i
+	}
+	nt := tags.New()
+	for k, v := range *t {
+		nt.Add(k, v)
+	}
+	i := uint32(len(c.tagList))
+	c.tagList = append(c.tagList, nt)
+	c.tagIndex[key] = i
+	return i
+}
+
+// sort.Interface, used to restore the id order after out of order inserts
+type byId struct{ *Compact }
+
+func (c byId) Len() int           { return len(c.ids) }
+func (c byId) Less(i, j int) bool { return c.ids[i] < c.ids[j] }
+func (c byId) Swap(i, j int) {
+	c.ids[i], c.ids[j] = c.ids[j], c.ids[i]
+	c.lats[i], c.lats[j] = c.lats[j], c.lats[i]
+	c.lons[i], c.lons[j] = c.lons[j], c.lons[i]
+	c.versions[i], c.versions[j] = c.versions[j], c.versions[i]
+	c.changesets[i], c.changesets[j] = c.changesets[j], c.changesets[i]
+	c.timestamps[i], c.timestamps[j] = c.timestamps[j], c.timestamps[i]
+	c.users[i], c.users[j] = c.users[j], c.users[i]
+	c.tagSets[i], c.tagSets[j] = c.tagSets[j], c.tagSets[i]
+	c.flags[i], c.flags[j] = c.flags[j], c.flags[i]
+}
+
+// sorts the columns by id, if a node id was added more than once, the
+// last added one wins
+func (c *Compact) sort() {
+	if !c.dirty {
+		return
+	}
+	sort.Stable(byId{c})
+	w := 0
+	for r := 0; r < len(c.ids); r++ {
+		if r+1 < len(c.ids) && c.ids[r] == c.ids[r+1] {
+			continue
+		}
+		c.move(r, w)
+		w++
+	}
+	c.truncate(w)
+	c.dirty = false
+}
+
+func (c *Compact) move(from, to int) {
+	if from == to {
+		return
+	}
+	c.ids[to] = c.ids[from]
+	c.lats[to] = c.lats[from]
+	c.lons[to] = c.lons[from]
+	c.versions[to] = c.versions[from]
+	c.changesets[to] = c.changesets[from]
+	c.timestamps[to] = c.timestamps[from]
+	c.users[to] = c.users[from]
+	c.tagSets[to] = c.tagSets[from]
+	c.flags[to] = c.flags[from]
+}
+
+func (c *Compact) truncate(l int) {
+	c.ids = c.ids[:l]
+	c.lats = c.lats[:l]
+	c.lons = c.lons[:l]
+	c.versions = c.versions[:l]
+	c.changesets = c.changesets[:l]
+	c.timestamps = c.timestamps[:l]
+	c.users = c.users[:l]
+	c.tagSets = c.tagSets[:l]
+	c.flags = c.flags[:l]
+	c.deleted = 0
+	for _, f := range c.flags {
+		if f&flagDeleted != 0 {
+			c.deleted++
+		}
+	}
+}
+
+// sorts nodes added out of order, this is done on the next lookup anyway.
+// Call this before the store is read concurrently, as lookups on an
+// unsorted store change it.
+func (c *Compact) Sort() {
+	c.sort()
+}
+
+// returns the index of the node with the given id or -1
+func (c *Compact) find(id int64) int {
+	c.sort()
+	i := sort.Search(len(c.ids), func(i int) bool { return c.ids[i] >= id })
+	if i < len(c.ids) && c.ids[i] == id {
+		return i
+	}
+	return -1
+}
+
+func (c *Compact) set(i int, n *node.Node) {
+	var flags uint8
+	if n.Position_ != nil {
+		c.lats[i] = toFixed(n.Position_.Lat)
+		c.lons[i] = toFixed(n.Position_.Lon)
+	} else {
+		c.lats[i], c.lons[i] = 0, 0
+		flags |= flagNoPosition
+	}
+	c.versions[i] = n.Version_
+	c.changesets[i] = n.Changeset_
+	c.timestamps[i] = n.Timestamp_.Unix()
+	c.users[i] = c.internUser(n.User_)
+	c.tagSets[i] = c.internTags(n.Tags_)
+	if n.Visible_ {
+		flags |= flagVisible
+	}
+	c.flags[i] = flags
+}
+
+// Adds (or replaces) the node n. Adding nodes in ascending id order (as
+// they appear in OSM files) is cheap, otherwise the store is sorted on
+// the next lookup.
+func (c *Compact) Put(n *node.Node) {
+	l := len(c.ids)
+	if l > 0 && n.Id_ <= c.ids[l-1] {
+		if !c.dirty {
+			if i := c.find(n.Id_); i != -1 {
+				if c.flags[i]&flagDeleted != 0 {
+					c.deleted--
+				}
+				c.set(i, n)
+				return
+			}
+		}
+		c.dirty = true
+	}
+	c.ids = append(c.ids, n.Id_)
+	c.lats = append(c.lats, 0)
+	c.lons = append(c.lons, 0)
+	c.versions = append(c.versions, 0)
+	c.changesets = append(c.changesets, 0)
+	c.timestamps = append(c.timestamps, 0)
+	c.users = append(c.users, 0)
+	c.tagSets = append(c.tagSets, 0)
+	c.flags = append(c.flags, 0)
+	c.set(l, n)
+}
+
+func (c *Compact) materialise(i int) *node.Node {
+	u := c.userList[c.users[i]]
+	t := tags.New()
+	for k, v := range *c.tagList[c.tagSets[i]] {
+		t.Add(k, v)
+	}
+	var pos *point.Point
+	if c.flags[i]&flagNoPosition == 0 {
+		pos = point.New(fromFixed(c.lats[i]), fromFixed(c.lons[i]))
+	}
+	return &node.Node{
+		Id_:        c.ids[i],
+		Position_:  pos,
+		User_:      user.New(u.Id, u.Name),
+		Tags_:      t,
+		Timestamp_: time.Unix(c.timestamps[i], 0).UTC(),
+		Version_:   c.versions[i],
+		Changeset_: c.changesets[i],
+		Visible_:   c.flags[i]&flagVisible != 0,
+	}
+}
+
+// returns a materialised copy of the node with the given id, nil if the
+// node is not in the store
+func (c *Compact) Get(id int64) *node.Node {
+	i := c.find(id)
+	if i == -1 || c.flags[i]&flagDeleted != 0 {
+		return nil
+	}
+	return c.materialise(i)
+}
+
+// checks if the node with the given id is in the store
+func (c *Compact) Has(id int64) bool {
+	i := c.find(id)
+	return i != -1 && c.flags[i]&flagDeleted == 0
+}
+
+// returns only the position of the node with the given id, this does not
+// materialise the full node. For a node without a position nil and false
+// are returned.
+func (c *Compact) Position(id int64) (*point.Point, bool) {
+	i := c.find(id)
+	if i == -1 || c.flags[i]&(flagDeleted|flagNoPosition) != 0 {
+		return nil, false
+	}
+	return point.New(fromFixed(c.lats[i]), fromFixed(c.lons[i])), true
+}
+
+// checks if the node has any tags without materialising it
+func (c *Compact) Tagged(id int64) bool {
+	i := c.find(id)
+	return i != -1 && c.flags[i]&flagDeleted == 0 && c.tagSets[i] != 0
+}
+
+// removes the node with the given id from the store
+func (c *Compact) Delete(id int64) {
+	i := c.find(id)
+	if i == -1 || c.flags[i]&flagDeleted != 0 {
+		return
+	}
+	c.flags[i] |= flagDeleted
+	c.deleted++
+}
+
+// the number of nodes in the store
+func (c *Compact) Count() int {
+	c.sort()
+	return len(c.ids) - c.deleted
+}
+
+// returns the ids of all nodes in ascending order
+func (c *Compact) Ids() []int64 {
+	c.sort()
+	ids := make([]int64, 0, len(c.ids)-c.deleted)
+	for i, id := range c.ids {
+		if c.flags[i]&flagDeleted == 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// calls fn with a materialised copy of every node in ascending id order
+// until fn returns false
+func (c *Compact) Each(fn func(*node.Node) bool) {
+	c.sort()
+	for i := range c.ids {
+		if c.flags[i]&flagDeleted != 0 {
+			continue
+		}
+		if !fn(c.materialise(i)) {
+			return
+		}
+	}
+}
+
+// returns a copy of the store, the interned users and tags are shared as
+// they are never changed
+func (c *Compact) Clone() *Compact {
+	n := *c
+	n.ids = append([]int64(nil), c.ids...)
+	n.lats = append([]int32(nil), c.lats...)
+	n.lons = append([]int32(nil), c.lons...)
+	n.versions = append([]uint16(nil), c.versions...)
+	n.changesets = append([]uint64(nil), c.changesets...)
+	n.timestamps = append([]int64(nil), c.timestamps...)
+	n.users = append([]uint32(nil), c.users...)
+	n.tagSets = append([]uint32(nil), c.tagSets...)
+	n.flags = append([]uint8(nil), c.flags...)
+	n.userList = append([]*user.User(nil), c.userList...)
+	n.userIndex = make(map[uint32]uint32, len(c.userIndex))
+	for k, v := range c.userIndex {
+		n.userIndex[k] = v
+	}
+	n.tagList = append([]*tags.Tags(nil), c.tagList...)
+	n.tagIndex = make(map[string]uint32, len(c.tagIndex))
+	for k, v := range c.tagIndex {
+		n.tagIndex[k] = v
+	}
+	return &n
+}
+
+// releases unused capacity and drops deleted nodes, call this after
+// loading is finished
+func (c *Compact) Shrink() {
+	c.sort()
+	w := 0
+	for r := range c.ids {
+		if c.flags[r]&flagDeleted != 0 {
+			continue
+		}
+		c.move(r, w)
+		w++
+	}
+	c.truncate(w)
+	c.ids = append([]int64(nil), c.ids...)
+	c.lats = append([]int32(nil), c.lats...)
+	c.lons = append([]int32(nil), c.lons...)
+	c.versions = append([]uint16(nil), c.versions...)
+	c.changesets = append([]uint64(nil), c.changesets...)
+	c.timestamps = append([]int64(nil), c.timestamps...)
+	c.users = append([]uint32(nil), c.users...)
+	c.tagSets = append([]uint32(nil), c.tagSets...)
+	c.flags = append([]uint8(nil), c.flags...)
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
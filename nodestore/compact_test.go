@@ -0,0 +1,215 @@
+package nodestore
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/user"
+	"testing"
+	"time"
+)
+
+func testNode(id int64, lat, lon float64, kv ...string) *node.Node {
+	t := tags.New()
+	for i := 0; i+1 < len(kv); i += 2 {
+		t.Add(kv[i], kv[i+1])
+	}
+	return &node.Node{Id_: id, Position_: point.New(lat, lon), Tags_: t, Version_: 1, Visible_: true}
+}
+
+func sameIds(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// nodes put out of order are found after the store sorts itself, a node
+// put twice keeps the last version
+func TestCompactUnsorted(t *testing.T) {
+	c := NewCompact()
+	for _, id := range []int64{5, 1, 9, -3, 7, 1} {
+		c.Put(testNode(id, float64(id), 0))
+	}
+	c.Put(testNode(9, 50, 4, "name", "last"))
+	if ids := c.Ids(); !sameIds(ids, []int64{-3, 1, 5, 7, 9}) {
+		t.Errorf("ids %v, want -3 1 5 7 9", ids)
+	}
+	if c.Count() != 5 {
+		t.Errorf("%d nodes, want 5", c.Count())
+	}
+	for _, id := range []int64{-3, 1, 5, 7} {
+		if n := c.Get(id); n == nil || n.Id_ != id || n.Position_.Lat != float64(id) {
+			t.Errorf("node %d is %v", id, n)
+		}
+	}
+	if n := c.Get(9); n == nil || n.Position_.Lat != 50 || n.Tags_.Get("name") != "last" {
+		t.Errorf("node 9 is %v, want the last one put", n)
+	}
+	for _, id := range []int64{0, 2, 10, -4} {
+		if c.Has(id) || c.Get(id) != nil {
+			t.Errorf("node %d found", id)
+		}
+	}
+	var each []int64
+	c.Each(func(n *node.Node) bool {
+		each = append(each, n.Id_)
+		return n.Id_ < 5
+	})
+	if !sameIds(each, []int64{-3, 1, 5}) {
+		t.Errorf("Each() stopped after %v, want -3 1 5", each)
+	}
+}
+
+// overwriting a node in order replaces it without adding a row
+func TestCompactOverwrite(t *testing.T) {
+	c := NewCompact()
+	c.Put(testNode(1, 50, 4, "amenity", "bench"))
+	c.Put(testNode(2, 51, 5))
+	c.Put(&node.Node{Id_: 1, Tags_: tags.New(), Version_: 2})
+	if len(c.ids) != 2 || c.dirty {
+		t.Errorf("%d rows after overwriting, dirty %v, want 2 sorted rows", len(c.ids), c.dirty)
+	}
+	n := c.Get(1)
+	if n == nil || n.Position_ != nil || n.Version_ != 2 || n.Visible_ || n.Tags_.Get("amenity") != "" {
+		t.Errorf("node 1 after overwriting is %v", n)
+	}
+	if _, ok := c.Position(1); ok || c.Tagged(1) {
+		t.Error("overwritten node 1 has a position or tags")
+	}
+}
+
+func TestCompactDelete(t *testing.T) {
+	c := NewCompact()
+	for id := int64(1); id <= 4; id++ {
+		c.Put(testNode(id, 50, 4, "name", "x"))
+	}
+	c.Delete(2)
+	c.Delete(2)
+	c.Delete(5)
+	if c.Count() != 3 || c.Has(2) || c.Get(2) != nil || c.Tagged(2) {
+		t.Errorf("%d nodes after deleting node 2, want 3", c.Count())
+	}
+	if _, ok := c.Position(2); ok {
+		t.Error("deleted node 2 has a position")
+	}
+	if ids := c.Ids(); !sameIds(ids, []int64{1, 3, 4}) {
+		t.Errorf("ids %v, want 1 3 4", ids)
+	}
+	// put again in order and out of order
+	c.Put(testNode(2, 51, 5))
+	c.Delete(3)
+	c.Put(testNode(0, 52, 6))
+	c.Put(testNode(3, 53, 7))
+	if ids := c.Ids(); !sameIds(ids, []int64{0, 1, 2, 3, 4}) || c.Count() != 5 {
+		t.Errorf("ids %v after putting deleted nodes again, want 0 1 2 3 4", ids)
+	}
+	c.Delete(4)
+	c.Shrink()
+	if len(c.ids) != 4 || c.Count() != 4 || c.Has(4) {
+		t.Errorf("%d rows and %d nodes after Shrink(), want 4", len(c.ids), c.Count())
+	}
+	if p, ok := c.Position(3); !ok || p.Lat != 53 || p.Lon != 7 {
+		t.Errorf("position of node 3 after Shrink() is %v", p)
+	}
+}
+
+// tag sets and users are stored once, the nodes get copies
+func TestCompactInterning(t *testing.T) {
+	c := NewCompact()
+	alice, bob := user.New(1, "alice"), user.New(2, "bob")
+	for id := int64(1); id <= 4; id++ {
+		n := testNode(id, 50, 4, "amenity", "bench", "backrest", "yes")
+		n.User_ = alice
+		c.Put(n)
+	}
+	n := testNode(5, 50, 4)
+	n.User_ = bob
+	c.Put(n)
+	c.Put(&node.Node{Id_: 6, Position_: point.New(50, 4)})
+	// a renamed user
+	n = testNode(7, 50, 4, "backrest", "yes", "amenity", "bench")
+	n.User_ = user.New(1, "alice2")
+	c.Put(n)
+
+	if len(c.tagList) != 2 {
+		t.Errorf("%d tag sets, want the empty one and the bench", len(c.tagList))
+	}
+	if len(c.userList) != 4 {
+		t.Errorf("%d users, want alice, bob, the anonymous user and alice2", len(c.userList))
+	}
+	if !c.Tagged(1) || !c.Tagged(7) || c.Tagged(5) || c.Tagged(6) {
+		t.Error("wrong tagged nodes")
+	}
+	for id, name := range map[int64]string{1: "alice", 5: "bob", 6: "", 7: "alice2"} {
+		if n := c.Get(id); n.User_ == nil || n.User_.Name != name {
+			t.Errorf("user of node %d is %v, want %q", id, n.User_, name)
+		}
+	}
+	// changing a returned node does not change the store
+	n = c.Get(1)
+	n.Tags_.Add("amenity", "shelter")
+	n.User_.Name = "mallory"
+	n.Position_.Lat = 0
+	if n = c.Get(2); n.Tags_.Get("amenity") != "bench" || n.User_.Name != "alice" {
+		t.Errorf("interned tags or user changed through a returned node: %v", n)
+	}
+	if p, _ := c.Position(1); p.Lat != 50 {
+		t.Errorf("stored position changed through a returned node: %v", p)
+	}
+}
+
+// changesets beyond 32 bits, timestamps after 2038 and before 1970, and
+// coordinates at the fixed point precision
+func TestCompactRoundTrip(t *testing.T) {
+	c := NewCompact()
+	ts := []time.Time{
+		time.Date(2106, 2, 7, 6, 28, 16, 0, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC),
+		time.Date(2024, 5, 1, 12, 0, 0, 999000000, time.UTC),
+	}
+	for i, tm := range ts {
+		c.Put(&node.Node{
+			Id_:        int64(i + 1),
+			Position_:  point.New(-33.85678914, 151.21529994),
+			Tags_:      tags.New(),
+			Timestamp_: tm,
+			Version_:   65535,
+			Changeset_: 1<<40 + uint64(i),
+			Visible_:   true,
+		})
+	}
+	for i, tm := range ts {
+		n := c.Get(int64(i + 1))
+		if want := tm.Truncate(time.Second); !n.Timestamp_.Equal(want) {
+			t.Errorf("timestamp %v, want %v", n.Timestamp_, want)
+		}
+		if n.Changeset_ != 1<<40+uint64(i) || n.Version_ != 65535 {
+			t.Errorf("changeset %d version %d, want %d 65535", n.Changeset_, n.Version_, uint64(1<<40+i))
+		}
+		if n.Position_.Lat != -33.8567891 || n.Position_.Lon != 151.2152999 {
+			t.Errorf("position %v, want -33.8567891,151.2152999", n.Position_)
+		}
+	}
+}
+
+func TestCompactClone(t *testing.T) {
+	c := NewCompact()
+	c.Put(testNode(1, 50, 4, "name", "a"))
+	d := c.Clone()
+	d.Put(testNode(1, 51, 5, "name", "b"))
+	d.Put(testNode(2, 52, 6))
+	if n := c.Get(1); n.Position_.Lat != 50 || n.Tags_.Get("name") != "a" || c.Count() != 1 {
+		t.Errorf("original changed through the clone: %v, %d nodes", n, c.Count())
+	}
+	if n := d.Get(1); n.Position_.Lat != 51 || n.Tags_.Get("name") != "b" || d.Count() != 2 {
+		t.Errorf("clone is %v, %d nodes", n, d.Count())
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -2,10 +2,14 @@ package osm
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/location"
 	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/nodestore"
 	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tagindex"
 	"github.com/brechtvm/osm/user"
 	"github.com/brechtvm/osm/way"
 	"sort"
@@ -33,6 +37,20 @@ func NewOSM(handler OSMReader) *OSM {
 	}
 }
 
+// returns a new and empty OSM which keeps its nodes in a compact columnar
+// store instead of the Nodes map, see nodestore.Compact. Use this for
+// country sized data sets.
+//
+// NOTE: ways added to such an OSM by a parser keep only their NodeIDs,
+// their Nodes_ are empty. Way methods which use the nodes (Length(),
+// Closed(), Points(), ...) return zero values for such ways until the
+// nodes are filled with ResolveWay().
+func NewCompactOSM(handler OSMReader) *OSM {
+	o := NewOSM(handler)
+	o.NodeStore = nodestore.NewCompact()
+	return o
+}
+
 type OSMReader interface {
 	ReadBounds(n *bbox.BBox) bool
 	ReadNode(*node.Node) bool
@@ -40,7 +58,8 @@ type OSMReader interface {
 	ReadRelation(*relation.Relation) bool
 }
 
-// the main entry point for OSM data
+// the main entry point for OSM data. An OSM is not safe for concurrent
+// use, see Shared.
 type OSM struct {
 	Version    string
 	BBox       bbox.BBox
@@ -51,14 +70,129 @@ type OSM struct {
 	Users      map[uint32]*user.User
 	Timestamps map[string]time.Time
 	Handler    OSMReader
+	NodeStore  *nodestore.Compact
+	Locations  location.Index
+	TagIndex   *tagindex.Index
 }
 
 func (o *OSM) BoundingBox() (*bbox.BBox, error) {
 	return o.GetNodeList().BoundingBox()
 }
 
+// returns the node with the given id. For an OSM created by NewCompactOSM()
+// a node which is not in the Nodes map is materialised from the NodeStore
+// and kept in the Nodes map: every lookup of an id returns the same node,
+// ways and relations share it and changes made to it are seen by later
+// lookups. See FlushNodes() to write the nodes back to the NodeStore.
 func (o *OSM) GetNode(id int64) *node.Node {
-	return o.Nodes[id]
+	if n, ok := o.Nodes[id]; ok || o.NodeStore == nil {
+		return n
+	}
+	n := o.NodeStore.Get(id)
+	if n != nil {
+		o.Nodes[id] = n
+	}
+	return n
+}
+
+// returns the node with the given id like GetNode(), but a node
+// materialised from the NodeStore is not kept in the Nodes map
+func (o *OSM) peekNode(id int64) *node.Node {
+	if n, ok := o.Nodes[id]; ok || o.NodeStore == nil {
+		return n
+	}
+	return o.NodeStore.Get(id)
+}
+
+// adds the node to the OSM, i.e. to the Nodes map or to the NodeStore. For
+// an OSM with a NodeStore n replaces a node of the same id which is kept
+// in the Nodes map, otherwise n is only written to the NodeStore: use
+// GetNode() to get the node as it is kept by the OSM.
+func (o *OSM) AddNode(n *node.Node) {
+	if o.TagIndex != nil {
+		if old := o.peekNode(n.Id_); old != nil {
+			o.TagIndex.Remove(old)
+		}
+		o.TagIndex.Add(n)
+	}
+	if o.NodeStore == nil {
+		o.Nodes[n.Id_] = n
+		return
+	}
+	if _, ok := o.Nodes[n.Id_]; ok {
+		o.Nodes[n.Id_] = n
+	}
+	o.NodeStore.Put(n)
+}
+
+// writes all nodes of the Nodes map to the NodeStore and empties the
+// Nodes map. This releases the memory of the nodes materialised by
+// GetNode() and ResolveWay(), ways and relations which refer to them keep
+// their (now detached) copies. Does nothing for an OSM without a
+// NodeStore.
+func (o *OSM) FlushNodes() {
+	if o.NodeStore == nil {
+		return
+	}
+	for id, n := range o.Nodes {
+		o.NodeStore.Put(n)
+		delete(o.Nodes, id)
+	}
+}
+
+// adds the way to the OSM
+func (o *OSM) AddWay(w *way.Way) {
+	if o.TagIndex != nil {
+		if old := o.Ways[w.Id_]; old != nil {
+			o.TagIndex.Remove(old)
+		}
+		o.TagIndex.Add(w)
+	}
+	o.Ways[w.Id_] = w
+}
+
+// adds the relation to the OSM
+func (o *OSM) AddRelation(r *relation.Relation) {
+	if o.TagIndex != nil {
+		if old := o.Relations[r.Id_]; old != nil {
+			o.TagIndex.Remove(old)
+		}
+		o.TagIndex.Add(r)
+	}
+	o.Relations[r.Id_] = r
+}
+
+// returns the number of nodes in the OSM
+func (o *OSM) NodeCount() int {
+	if o.NodeStore == nil {
+		return len(o.Nodes)
+	}
+	c := o.NodeStore.Count()
+	for id := range o.Nodes {
+		if !o.NodeStore.Has(id) {
+			c++
+		}
+	}
+	return c
+}
+
+// fills the nodes of a way which has only NodeIDs set (as done by the
+// parsers for an OSM with a NodeStore), the nodes are looked up with
+// GetNode(), i.e. ways sharing a node share the same *node.Node
+func (o *OSM) ResolveWay(w *way.Way) error {
+	if len(w.NodeIDs) == 0 || len(w.Nodes_) == len(w.NodeIDs) {
+		return nil
+	}
+	nd := make([]*node.Node, 0, len(w.NodeIDs))
+	for _, id := range w.NodeIDs {
+		n := o.GetNode(id)
+		if n == nil {
+			return errors.New(fmt.Sprintf("Missing node #%d in way #%d", id, w.Id()))
+		}
+		nd = append(nd, n)
+	}
+	w.Nodes_ = nd
+	return nil
 }
 
 func (o *OSM) GetWay(id int64) *way.Way {
@@ -74,6 +208,15 @@ func (o *OSM) GetNodeList() *node.NodeList {
 	for _, n := range o.Nodes {
 		nl = append(nl, n)
 	}
+	if o.NodeStore != nil {
+		// materialised copies, these are not kept in the Nodes map
+		o.NodeStore.Each(func(n *node.Node) bool {
+			if _, ok := o.Nodes[n.Id_]; !ok {
+				nl = append(nl, n)
+			}
+			return true
+		})
+	}
 	nlist := node.NodeList(nl)
 	return &nlist
 }
@@ -0,0 +1,158 @@
+package osm
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"testing"
+)
+
+func testNode(id int64, lat, lon float64, kv ...string) *node.Node {
+	t := tags.New()
+	for i := 0; i+1 < len(kv); i += 2 {
+		t.Add(kv[i], kv[i+1])
+	}
+	return &node.Node{Id_: id, Position_: point.New(lat, lon), Tags_: t, Version_: 1, Visible_: true}
+}
+
+func testWay(id int64, ids []int64, kv ...string) *way.Way {
+	t := tags.New()
+	for i := 0; i+1 < len(kv); i += 2 {
+		t.Add(kv[i], kv[i+1])
+	}
+	return &way.Way{Id_: id, NodeIDs: ids, Tags_: t, Version_: 1, Visible_: true}
+}
+
+// a compact OSM with the nodes 1..4 in the NodeStore, node 2 is shared
+// by the ways 10 and 11, node 4 is a member of relation 20
+func compactOSM() *OSM {
+	o := NewCompactOSM(nil)
+	o.AddNode(testNode(1, 50.0, 4.0))
+	o.AddNode(testNode(2, 50.1, 4.1))
+	o.AddNode(testNode(3, 50.2, 4.2))
+	o.AddNode(testNode(4, 50.3, 4.3, "amenity", "bench"))
+	o.AddWay(testWay(10, []int64{1, 2}, "highway", "residential"))
+	o.AddWay(testWay(11, []int64{2, 3}))
+	o.AddRelation(&relation.Relation{
+		Id_:      20,
+		Tags_:    tags.New(),
+		Members_: []*relation.Member{{Type_: item.TypeNode, Id_: 4, Role: "label", Ref: o.GetNode(4)}},
+	})
+	return o
+}
+
+func TestCompactNodeIdentity(t *testing.T) {
+	o := compactOSM()
+	if a, b := o.GetNode(1), o.GetNode(1); a != b {
+		t.Errorf("GetNode(1) returned two different nodes")
+	}
+	for _, id := range []int64{10, 11} {
+		if err := o.ResolveWay(o.Ways[id]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b := o.Ways[10].Nodes_[1], o.Ways[11].Nodes_[0]
+	if a != b || a != o.GetNode(2) {
+		t.Errorf("ways 10 and 11 do not share node 2")
+	}
+	if m := o.Relations[20].Members_[0]; m.Ref != o.GetNode(4) {
+		t.Errorf("relation member is not the node of the OSM")
+	}
+}
+
+func TestCompactNodeEdit(t *testing.T) {
+	o := compactOSM()
+	n := o.GetNode(3)
+	n.Position_ = point.New(51, 5)
+	n.Tags_.Add("name", "x")
+	if p := o.GetNode(3).Position_; p.Lat != 51 || p.Lon != 5 {
+		t.Errorf("position after edit is %v, want 51,5", p)
+	}
+
+	o.FlushNodes()
+	if len(o.Nodes) != 0 {
+		t.Errorf("%d nodes in the Nodes map after FlushNodes(), want 0", len(o.Nodes))
+	}
+	p, ok := o.NodeStore.Position(3)
+	if !ok || p.Lat != 51 || p.Lon != 5 {
+		t.Errorf("stored position after FlushNodes() is %v, want 51,5", p)
+	}
+	if got := o.GetNode(3).Tags_.Get("name"); got != "x" {
+		t.Errorf("stored name after FlushNodes() is %q, want x", got)
+	}
+}
+
+func TestCompactNodeCount(t *testing.T) {
+	o := compactOSM()
+	if c := o.NodeCount(); c != 4 {
+		t.Errorf("NodeCount() = %d, want 4", c)
+	}
+	// nodes kept in the Nodes map are not counted twice
+	o.GetNode(1)
+	o.GetNode(2)
+	if c := o.NodeCount(); c != 4 {
+		t.Errorf("NodeCount() after GetNode() = %d, want 4", c)
+	}
+	// a node only in the Nodes map
+	o.Nodes[5] = testNode(5, 50.4, 4.4)
+	if c := o.NodeCount(); c != 5 {
+		t.Errorf("NodeCount() with a node only in the Nodes map = %d, want 5", c)
+	}
+	o.NodeStore.Delete(2)
+	delete(o.Nodes, 2)
+	if c := o.NodeCount(); c != 4 {
+		t.Errorf("NodeCount() after delete = %d, want 4", c)
+	}
+}
+
+func TestCompactNodeWithoutPosition(t *testing.T) {
+	o := NewCompactOSM(nil)
+	o.AddNode(&node.Node{Id_: 1, Tags_: tags.New()})
+	n := o.GetNode(1)
+	if n == nil || n.Position_ != nil {
+		t.Fatalf("GetNode(1) = %v, want a node without position", n)
+	}
+	if _, ok := o.NodeStore.Position(1); ok {
+		t.Errorf("Position(1) reports a position")
+	}
+}
+
+func TestCompactFilterTags(t *testing.T) {
+	o := compactOSM()
+	f := o.FilterTags(&tags.Tags{"amenity": "bench"}, &tags.Tags{"highway": "residential"})
+	if f.Nodes[4] == nil || f.Nodes[4] != o.GetNode(4) {
+		t.Errorf("tagged node 4 of the NodeStore not in the filtered OSM")
+	}
+	if f.Ways[10] == nil || f.Nodes[1] == nil || f.Nodes[2] == nil {
+		t.Errorf("way 10 or its nodes not in the filtered OSM")
+	}
+	if f.Nodes[3] != nil || f.Ways[11] != nil {
+		t.Errorf("untagged items in the filtered OSM")
+	}
+}
+
+func TestCompactMerge(t *testing.T) {
+	o := NewOSM(nil)
+	o.AddNode(testNode(1, 0, 0))
+	c := compactOSM()
+	n := c.GetNode(1)
+	n.Version_ = 2
+	o.Merge(c)
+	if o.NodeCount() != 4 {
+		t.Errorf("%d nodes after merge, want 4", o.NodeCount())
+	}
+	if o.Nodes[1].Version_ != 2 {
+		t.Errorf("node 1 has version %d after merge, want 2", o.Nodes[1].Version_)
+	}
+
+	c = compactOSM()
+	c.Merge(o)
+	if c.NodeCount() != 4 || c.GetNode(1).Version_ != 2 {
+		t.Errorf("merge into a compact OSM: %d nodes, version %d", c.NodeCount(), c.GetNode(1).Version_)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -8,6 +8,7 @@ import (
 	"github.com/brechtvm/osm"
 	"github.com/brechtvm/osm/bbox"
 	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/location"
 	"github.com/brechtvm/osm/node"
 	"github.com/brechtvm/osm/point"
 	"github.com/brechtvm/osm/relation"
@@ -41,11 +42,41 @@ func ByteParser(data []byte) osm.Parser {
 
 // returns an osm.Parser which can be used as argument to osm.New()
 func Parser(r io.Reader) osm.Parser {
-	return &Pbf{r}
+	return &Pbf{r: r}
+}
+
+// Returns an osm.Parser which stores the nodes in a compact node store,
+// see osm.NewCompactOSM(). The ways keep only their NodeIDs, call
+// OSM.ResolveWay() before using their nodes.
+func CompactParser(r io.Reader) osm.Parser {
+	return &Pbf{r: r, compact: true}
+}
+
+// returns an osm.Parser which stores all node positions in the given
+// location index (available as o.Locations). In handler mode the nodes
+// of each way are filled from this index before calling ReadWay(), so
+// the handler can use the way geometry (Length(), Centroid(), ...)
+// without keeping the nodes itself. These nodes have only an id and a
+// position. A way with a node missing from the index gets no nodes, the
+// number of such ways is logged once at the end of the parse and returned
+// by MissingLocations().
+func LocationParser(r io.Reader, idx location.Index) osm.Parser {
+	return &Pbf{r: r, locations: idx}
 }
 
 type Pbf struct {
-	r io.Reader
+	r         io.Reader
+	compact   bool
+	locations location.Index
+	// ways and nodes without location of the last Parse()
+	missingWays, missingNodes int
+}
+
+// returns the number of ways which got no nodes in the last Parse() as
+// the locations of some of their nodes were missing from the location
+// index, and the number of these nodes (counted once per way)
+func (p *Pbf) MissingLocations() (ways, nodes int) {
+	return p.missingWays, p.missingNodes
 }
 
 // implements the osm.Parser interface
@@ -59,8 +90,19 @@ func (p *Pbf) Parse(handler osm.OSMReader) (o *osm.OSM, err error) {
 	if err != nil {
 		return
 	}
+	p.missingWays, p.missingNodes = 0, 0
+	defer func() {
+		if p.missingWays > 0 {
+			log.Printf("WARNING: Missing locations of %d nodes, %d ways have no nodes\n", p.missingNodes, p.missingWays)
+		}
+	}()
 
-	o = osm.NewOSM(handler)
+	if p.compact {
+		o = osm.NewCompactOSM(handler)
+	} else {
+		o = osm.NewOSM(handler)
+	}
+	o.Locations = p.locations
 	o.Users = make(map[uint32]*user.User)
 	o.Timestamps = make(map[string]time.Time)
 	var v interface{}
@@ -108,6 +150,13 @@ func (p *Pbf) Parse(handler osm.OSMReader) (o *osm.OSM, err error) {
 				lowerlon = math.Min(lowerlon, newnode.Position_.Lon)
 				upperlon = math.Max(upperlon, newnode.Position_.Lon)
 
+				if o.Locations != nil {
+					err = o.Locations.Set(v.ID, newnode.Position_)
+					if err != nil {
+						return
+					}
+				}
+
 				if o.Handler != nil {
 					if o.Handler.ReadNode(newnode) == false {
 						return
@@ -123,7 +172,7 @@ func (p *Pbf) Parse(handler osm.OSMReader) (o *osm.OSM, err error) {
 					if _, ok := o.Timestamps[sTimestamp]; !ok {
 						o.Timestamps[sTimestamp] = v.Info.Timestamp
 					}
-					o.Nodes[v.ID] = newnode
+					o.AddNode(newnode)
 				}
 			case *osmpbf.Way:
 				t := tags.Tags(v.Tags)
@@ -139,9 +188,19 @@ func (p *Pbf) Parse(handler osm.OSMReader) (o *osm.OSM, err error) {
 				}
 
 				if o.Handler != nil {
+					if o.Locations != nil {
+						var missing int
+						if w.Nodes_, missing = locateNodes(o.Locations, v.NodeIDs); missing > 0 {
+							p.missingWays++
+							p.missingNodes += missing
+						}
+					}
 					if o.Handler.ReadWay(w) == false {
 						return
 					}
+				} else if o.NodeStore != nil {
+					// nodes are materialised by o.ResolveWay() when needed
+					o.AddWay(w)
 				} else {
 					var nd []*node.Node
 					for _, id := range v.NodeIDs {
@@ -154,7 +213,7 @@ func (p *Pbf) Parse(handler osm.OSMReader) (o *osm.OSM, err error) {
 						nd = append(nd, n)
 					}
 					w.Nodes_ = nd
-					o.Ways[v.ID] = w
+					o.AddWay(w)
 				}
 			case *osmpbf.Relation:
 				t := tags.Tags(v.Tags)
@@ -170,22 +229,29 @@ func (p *Pbf) Parse(handler osm.OSMReader) (o *osm.OSM, err error) {
 				}
 				var members []*relation.Member
 				for _, m := range v.Members {
+					// in handler mode the items are not kept, members
+					// have only a type, an id and a role then
 					member := &relation.Member{Role: m.Role, Id_: m.ID}
 					switch m.Type {
 					case osmpbf.NodeType:
 						member.Type_ = item.TypeNode
-						member.Ref = o.GetNode(m.ID)
+						if n := o.GetNode(m.ID); n != nil {
+							member.Ref = n
+						}
 					case osmpbf.WayType:
 						member.Type_ = item.TypeWay
-						member.Ref = o.GetWay(m.ID)
+						if w := o.GetWay(m.ID); w != nil {
+							member.Ref = w
+						}
 					case osmpbf.RelationType:
 						member.Type_ = item.TypeRelation
-						member.Ref = o.GetRelation(m.ID)
+						if r := o.GetRelation(m.ID); r != nil {
+							member.Ref = r
+						}
 					}
-					if member.Ref == nil {
-						err = errors.New(fmt.Sprintf("Missing member #%d (%s) in way #%d", m.ID, member.Type(), v.ID))
+					if member.Ref == nil && o.Handler == nil {
+						err = errors.New(fmt.Sprintf("Missing %s #%d in relation #%d", member.Type(), m.ID, v.ID))
 						o = nil
-						panic("mannekes toch, dit mag niet he!")
 						return
 					}
 					members = append(members, member)
@@ -196,7 +262,7 @@ func (p *Pbf) Parse(handler osm.OSMReader) (o *osm.OSM, err error) {
 						return
 					}
 				} else {
-					o.Relations[v.ID] = r
+					o.AddRelation(r)
 				}
 
 			default:
@@ -216,6 +282,25 @@ func (p *Pbf) Parse(handler osm.OSMReader) (o *osm.OSM, err error) {
 	return
 }
 
+// returns the nodes of a way from the location index and the number of
+// nodes whose position is missing, the way gets no nodes at all then
+func locateNodes(idx location.Index, ids []int64) ([]*node.Node, int) {
+	nd := make([]*node.Node, 0, len(ids))
+	missing := 0
+	for _, nid := range ids {
+		p, ok := idx.Get(nid)
+		if !ok {
+			missing++
+			continue
+		}
+		nd = append(nd, &node.Node{Id_: nid, Position_: p, Visible_: true})
+	}
+	if missing > 0 {
+		return nil, missing
+	}
+	return nd, 0
+}
+
 // vim: ts=4 sw=4 noexpandtab nolist syn=go
 // vim: ts=4 sw=4 noexpandtab nolist syn=go
 // vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,328 @@
+package pbf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/location"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/way"
+	"sort"
+	"testing"
+)
+
+// a minimal protocol buffers encoder for the test data
+type message []byte
+
+func (m *message) varint(v uint64) {
+	*m = binary.AppendUvarint(*m, v)
+}
+
+func (m *message) uint(field int, v uint64) {
+	m.varint(uint64(field<<3 | 0))
+	m.varint(v)
+}
+
+func (m *message) sint(field int, v int64) {
+	m.uint(field, uint64(v<<1^(v>>63)))
+}
+
+func (m *message) bytes(field int, b []byte) {
+	m.varint(uint64(field<<3 | 2))
+	m.varint(uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+func (m *message) packed(field int, vl []uint64) {
+	var p message
+	for _, v := range vl {
+		p.varint(v)
+	}
+	m.bytes(field, p)
+}
+
+// zig-zag encoded deltas
+func deltas(vl []int64) []uint64 {
+	var l []uint64
+	var prev int64
+	for _, v := range vl {
+		d := v - prev
+		l = append(l, uint64(d<<1^(d>>63)))
+		prev = v
+	}
+	return l
+}
+
+// the test data as OSM PBF file
+type pbfFile struct {
+	strings   []string
+	nodes     []message
+	ways      []message
+	relations []message
+}
+
+func (f *pbfFile) sid(s string) uint64 {
+	for i, x := range f.strings {
+		if x == s {
+			return uint64(i)
+		}
+	}
+	f.strings = append(f.strings, s)
+	return uint64(len(f.strings) - 1)
+}
+
+func (f *pbfFile) info() message {
+	var m message
+	m.uint(1, 1)           // version
+	m.uint(2, 1700000000)  // timestamp in seconds (date_granularity 1000)
+	m.uint(3, 1)           // changeset
+	m.uint(4, 1)           // uid
+	m.uint(5, f.sid("me")) // user
+	return m
+}
+
+// tags as keys and values fields
+func (f *pbfFile) tags(m *message, kv ...string) {
+	var keys, vals []uint64
+	for i := 0; i < len(kv); i += 2 {
+		keys = append(keys, f.sid(kv[i]))
+		vals = append(vals, f.sid(kv[i+1]))
+	}
+	if len(keys) > 0 {
+		m.packed(2, keys)
+		m.packed(3, vals)
+	}
+}
+
+func (f *pbfFile) node(id int64, lat, lon float64) {
+	var m message
+	m.sint(1, id)
+	m.bytes(4, f.info())
+	// granularity 100 nanodegrees
+	m.sint(8, int64(lat*1e7+0.5))
+	m.sint(9, int64(lon*1e7+0.5))
+	f.nodes = append(f.nodes, m)
+}
+
+func (f *pbfFile) way(id int64, refs []int64, kv ...string) {
+	var m message
+	m.uint(1, uint64(id))
+	f.tags(&m, kv...)
+	m.bytes(4, f.info())
+	m.packed(8, deltas(refs))
+	f.ways = append(f.ways, m)
+}
+
+// members as type ("n", "w", "r"), id and role
+type member struct {
+	t    string
+	id   int64
+	role string
+}
+
+func (f *pbfFile) relation(id int64, ml []member, kv ...string) {
+	var m message
+	m.uint(1, uint64(id))
+	f.tags(&m, kv...)
+	m.bytes(4, f.info())
+	var roles, types []uint64
+	var ids []int64
+	for _, x := range ml {
+		roles = append(roles, f.sid(x.role))
+		ids = append(ids, x.id)
+		types = append(types, map[string]uint64{"n": 0, "w": 1, "r": 2}[x.t])
+	}
+	m.packed(8, roles)
+	m.packed(9, deltas(ids))
+	m.packed(10, types)
+	f.relations = append(f.relations, m)
+}
+
+// a blob with its header
+func blob(w *bytes.Buffer, typ string, data []byte) {
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(data)
+	zw.Close()
+	var b message
+	b.uint(2, uint64(len(data)))
+	b.bytes(3, z.Bytes())
+	var h message
+	h.bytes(1, []byte(typ))
+	h.uint(3, uint64(len(b)))
+	binary.Write(w, binary.BigEndian, uint32(len(h)))
+	w.Write(h)
+	w.Write(b)
+}
+
+func (f *pbfFile) encode() []byte {
+	var w bytes.Buffer
+	var hb message
+	hb.bytes(4, []byte("OsmSchema-V0.6"))
+	blob(&w, "OSMHeader", hb)
+
+	var groups []message
+	for _, l := range [][]message{f.nodes, f.ways, f.relations} {
+		var g message
+		for _, m := range l {
+			field := 1
+			switch {
+			case len(groups) == 1:
+				field = 3
+			case len(groups) == 2:
+				field = 4
+			}
+			g.bytes(field, m)
+		}
+		groups = append(groups, g)
+	}
+	var st message
+	for _, s := range f.strings {
+		st.bytes(1, []byte(s))
+	}
+	var pb message
+	pb.bytes(1, st)
+	for _, g := range groups {
+		pb.bytes(2, g)
+	}
+	blob(&w, "OSMData", pb)
+	return w.Bytes()
+}
+
+// collects the ids of the items passed on by a filter
+type collector struct {
+	nodes, ways, relations []int64
+	// members with a Ref
+	refs int
+}
+
+func (c *collector) ReadBounds(b *bbox.BBox) bool { return true }
+func (c *collector) ReadNode(n *node.Node) bool {
+	c.nodes = append(c.nodes, n.Id_)
+	return true
+}
+func (c *collector) ReadWay(w *way.Way) bool {
+	c.ways = append(c.ways, w.Id_)
+	return true
+}
+func (c *collector) ReadRelation(r *relation.Relation) bool {
+	c.relations = append(c.relations, r.Id_)
+	for _, m := range r.Members_ {
+		if m.Ref != nil {
+			c.refs++
+		}
+	}
+	return true
+}
+
+func equalIds(got []int64, want ...int64) bool {
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// the multi pass extract of the ExtractFilter documentation on a pbf file
+// with relations, members of relations are not resolved in handler mode
+func TestExtractFilterPasses(t *testing.T) {
+	f := &pbfFile{strings: []string{""}}
+	f.node(1, 50.5, 4.5)
+	f.node(2, 50.6, 4.6)
+	f.node(3, 52.0, 6.0)
+	f.node(4, 52.1, 6.1)
+	f.way(10, []int64{1, 3}, "highway", "primary")
+	f.way(11, []int64{3, 4}, "highway", "primary")
+	f.relation(20, []member{{"w", 10, "outer"}, {"n", 99, "label"}}, "type", "route")
+	f.relation(21, []member{{"r", 20, ""}})
+	f.relation(22, []member{{"w", 11, ""}})
+	data := f.encode()
+
+	bb := bbox.BBox{LowerLeft: point.New(50, 4), UpperRight: point.New(51, 5)}
+	c := &collector{}
+	x := osm.NewExtractFilter(bb, osm.ExtractCompleteWays, c)
+	passes := 0
+	for x.NextPass() {
+		passes++
+		if _, err := osm.New(ByteParser(data), x); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if passes != 2 {
+		t.Errorf("%d passes, want 2", passes)
+	}
+	if !equalIds(c.nodes, 1, 2, 3) {
+		t.Errorf("nodes %v, want [1 2 3]", c.nodes)
+	}
+	if !equalIds(c.ways, 10) {
+		t.Errorf("ways %v, want [10]", c.ways)
+	}
+	if !equalIds(c.relations, 20, 21) {
+		t.Errorf("relations %v, want [20 21]", c.relations)
+	}
+	if c.refs != 0 {
+		t.Errorf("%d members with a Ref in handler mode", c.refs)
+	}
+}
+
+// a missing relation member is an error when the whole file is loaded
+func TestMissingMember(t *testing.T) {
+	f := &pbfFile{strings: []string{""}}
+	f.node(1, 50.5, 4.5)
+	f.relation(20, []member{{"n", 1, ""}, {"n", 99, "label"}})
+	data := f.encode()
+
+	if o, err := osm.New(ByteParser(data), nil); err == nil || o != nil {
+		t.Errorf("no error for the missing node #99")
+	}
+	if _, err := osm.New(ByteParser(data), &collector{}); err != nil {
+		t.Errorf("error %v in handler mode", err)
+	}
+}
+
+// ways with a node missing from the location index get no nodes, the
+// misses are counted
+func TestLocationParserMissing(t *testing.T) {
+	f := &pbfFile{strings: []string{""}}
+	f.node(1, 50.5, 4.5)
+	f.node(2, 50.6, 4.6)
+	f.way(10, []int64{1, 2})
+	f.way(11, []int64{1, 98, 2, 99})
+	f.way(12, []int64{2, 99})
+	data := f.encode()
+
+	ways := make(map[int64]*way.Way)
+	h := &wayHandler{ways: ways}
+	p := LocationParser(bytes.NewReader(data), location.NewSparseMem())
+	if _, err := osm.New(p, h); err != nil {
+		t.Fatal(err)
+	}
+	if len(ways[10].Nodes_) != 2 || ways[11].Nodes_ != nil || ways[12].Nodes_ != nil {
+		t.Errorf("ways with %d, %d and %d nodes, want 2, 0 and 0", len(ways[10].Nodes_), len(ways[11].Nodes_), len(ways[12].Nodes_))
+	}
+	if w, n := p.(*Pbf).MissingLocations(); w != 2 || n != 3 {
+		t.Errorf("%d ways and %d nodes without location, want 2 and 3", w, n)
+	}
+}
+
+// keeps the ways passed to it
+type wayHandler struct {
+	collector
+	ways map[int64]*way.Way
+}
+
+func (h *wayHandler) ReadWay(w *way.Way) bool {
+	h.ways[w.Id_] = w
+	return true
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
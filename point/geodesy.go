@@ -0,0 +1,235 @@
+package point
+
+import (
+	"errors"
+	"github.com/brechtvm/osm/distance"
+	"math"
+)
+
+// The spherical functions use a sphere with radius distance.EarthRadius
+// (as DistanceOf() does), see http://www.movable-type.co.uk/scripts/latlong.html
+// Bearings are in degrees clockwise from north (0 - 360).
+
+// the WGS84 ellipsoid, used by the Vincenty functions
+const (
+	WGS84SemiMajorAxis = 6378137.0 * distance.Meter
+	WGS84Flattening    = 1 / 298.257223563
+	WGS84SemiMinorAxis = WGS84SemiMajorAxis * (1 - WGS84Flattening)
+)
+
+func normBearing(b float64) float64 {
+	return math.Mod(b+360, 360)
+}
+
+// the initial bearing of the great circle path from p to q
+func (p *Point) Bearing(q *Point) float64 {
+	phi1, phi2 := deg2rad(p.Lat), deg2rad(q.Lat)
+	dl := deg2rad(q.Lon - p.Lon)
+	y := math.Sin(dl) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dl)
+	return normBearing(rad2deg(math.Atan2(y, x)))
+}
+
+// the bearing at q of the great circle path from p to q
+func (p *Point) FinalBearing(q *Point) float64 {
+	return normBearing(q.Bearing(p) + 180)
+}
+
+// the point reached when travelling the distance d from p on the great
+// circle with the given initial bearing
+func (p *Point) Destination(bearing float64, d distance.Distance) *Point {
+	delta := float64(d / distance.EarthRadius)
+	theta := deg2rad(bearing)
+	phi1, l1 := deg2rad(p.Lat), deg2rad(p.Lon)
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	l2 := l1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1), math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return New(rad2deg(phi2), normLon(rad2deg(l2)))
+}
+
+func normLon(l float64) float64 {
+	return math.Mod(l+540, 360) - 180
+}
+
+// the half way point on the great circle path from p to q
+func (p *Point) Midpoint(q *Point) *Point {
+	phi1, l1 := deg2rad(p.Lat), deg2rad(p.Lon)
+	phi2 := deg2rad(q.Lat)
+	dl := deg2rad(q.Lon - p.Lon)
+	bx := math.Cos(phi2) * math.Cos(dl)
+	by := math.Cos(phi2) * math.Sin(dl)
+	phi3 := math.Atan2(math.Sin(phi1)+math.Sin(phi2), math.Sqrt((math.Cos(phi1)+bx)*(math.Cos(phi1)+bx)+by*by))
+	l3 := l1 + math.Atan2(by, math.Cos(phi1)+bx)
+	return New(rad2deg(phi3), normLon(rad2deg(l3)))
+}
+
+// the (signed) distance of p from the great circle through start and end,
+// negative if p is left of the path
+func (p *Point) CrossTrackDistance(start, end *Point) distance.Distance {
+	d13 := float64(start.DistanceOf(p) / distance.EarthRadius)
+	t13 := deg2rad(start.Bearing(p))
+	t12 := deg2rad(start.Bearing(end))
+	return distance.Distance(math.Asin(math.Sin(d13)*math.Sin(t13-t12))) * distance.EarthRadius
+}
+
+// the distance from start to the point on the great circle through start
+// and end which is closest to p, negative if that point is before start
+func (p *Point) AlongTrackDistance(start, end *Point) distance.Distance {
+	d13 := float64(start.DistanceOf(p) / distance.EarthRadius)
+	t13 := deg2rad(start.Bearing(p))
+	t12 := deg2rad(start.Bearing(end))
+	dxt := math.Asin(math.Sin(d13) * math.Sin(t13-t12))
+	c := math.Cos(d13) / math.Cos(dxt)
+	// rounding errors
+	c = math.Max(-1, math.Min(1, c))
+	dat := math.Acos(c)
+	if math.Cos(t13-t12) < 0 {
+		dat = -dat
+	}
+	return distance.Distance(dat) * distance.EarthRadius
+}
+
+// the position on the great circle path from start to end at the
+// distance d from start, start (a copy) if d is not positive
+func Interpolate(start, end *Point, d distance.Distance) *Point {
+	if d <= 0 {
+		return New(start.Lat, start.Lon)
+	}
+	return start.Destination(start.Bearing(end), d)
+}
+
+// Returns the closest position to p on the great circle segment from start
+// to end and its distance from start. Used for snapping to ways and for
+// linear referencing.
+func (p *Point) ProjectOnSegment(start, end *Point) (*Point, distance.Distance) {
+	sl := start.DistanceOf(end)
+	var at distance.Distance
+	if sl > 0 {
+		at = p.AlongTrackDistance(start, end)
+	}
+	switch {
+	case at <= 0:
+		return New(start.Lat, start.Lon), 0
+	case at >= sl:
+		return New(end.Lat, end.Lon), sl
+	}
+	return Interpolate(start, end, at), at
+}
+
+// The distance on the WGS84 ellipsoid and the initial and final bearings
+// using Vincenty's inverse formula (accurate to about 0.5mm). For nearly
+// antipodal points the iteration may not converge, an error is returned
+// then.
+//
+// Reference (Vincenty 1975 / Geoscience Australia): from Flinders Peak
+// (-37°57'03.72030", 144°25'29.52440") to Buninyong (-37°39'10.15610",
+// 143°55'35.38390") the distance is 54972.271m, the initial bearing
+// 306°52'05.37" and the final bearing 307°10'25.07".
+func (p *Point) Vincenty(q *Point) (d distance.Distance, initial, final float64, err error) {
+	a := float64(WGS84SemiMajorAxis)
+	b := float64(WGS84SemiMinorAxis)
+	f := WGS84Flattening
+
+	L := deg2rad(q.Lon - p.Lon)
+	u1 := math.Atan((1 - f) * math.Tan(deg2rad(p.Lat)))
+	u2 := math.Atan((1 - f) * math.Tan(deg2rad(q.Lat)))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := L
+	var sinLambda, cosLambda, sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < 200; i++ {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSigma = math.Sqrt((cosU2*sinLambda)*(cosU2*sinLambda) +
+			(cosU1*sinU2-sinU1*cosU2*cosLambda)*(cosU1*sinU2-sinU1*cosU2*cosLambda))
+		if sinSigma == 0 {
+			// coincident points
+			return 0, 0, 0, nil
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		if cosSqAlpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		} else {
+			// equatorial line
+			cos2SigmaM = 0
+		}
+		C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+		prev := lambda
+		lambda = L + (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		err = errors.New("Vincenty formula failed to converge")
+		return
+	}
+
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+
+	d = distance.Distance(b * A * (sigma - deltaSigma))
+	initial = normBearing(rad2deg(math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)))
+	final = normBearing(rad2deg(math.Atan2(cosU1*sinLambda, -sinU1*cosU2+cosU1*sinU2*cosLambda)))
+	return
+}
+
+// the distance on the WGS84 ellipsoid, see Vincenty()
+func (p *Point) VincentyDistance(q *Point) (distance.Distance, error) {
+	d, _, _, err := p.Vincenty(q)
+	return d, err
+}
+
+// The point reached when travelling the distance d from p on the WGS84
+// ellipsoid with the given initial bearing (Vincenty's direct formula),
+// the final bearing is returned too.
+func (p *Point) VincentyDestination(bearing float64, d distance.Distance) (*Point, float64) {
+	a := float64(WGS84SemiMajorAxis)
+	b := float64(WGS84SemiMinorAxis)
+	f := WGS84Flattening
+	s := float64(d)
+
+	sinAlpha1, cosAlpha1 := math.Sincos(deg2rad(bearing))
+	tanU1 := (1 - f) * math.Tan(deg2rad(p.Lat))
+	cosU1 := 1 / math.Sqrt(1+tanU1*tanU1)
+	sinU1 := tanU1 * cosU1
+	sigma1 := math.Atan2(tanU1, cosAlpha1)
+	sinAlpha := cosU1 * sinAlpha1
+	cosSqAlpha := 1 - sinAlpha*sinAlpha
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+
+	sigma := s / (b * A)
+	var sinSigma, cosSigma, cos2SigmaM float64
+	for i := 0; i < 200; i++ {
+		cos2SigmaM = math.Cos(2*sigma1 + sigma)
+		sinSigma, cosSigma = math.Sincos(sigma)
+		deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+			B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+		prev := sigma
+		sigma = s/(b*A) + deltaSigma
+		if math.Abs(sigma-prev) < 1e-12 {
+			break
+		}
+	}
+	sinSigma, cosSigma = math.Sincos(sigma)
+	cos2SigmaM = math.Cos(2*sigma1 + sigma)
+
+	x := sinU1*sinSigma - cosU1*cosSigma*cosAlpha1
+	phi2 := math.Atan2(sinU1*cosSigma+cosU1*sinSigma*cosAlpha1, (1-f)*math.Sqrt(sinAlpha*sinAlpha+x*x))
+	lambda := math.Atan2(sinSigma*sinAlpha1, cosU1*cosSigma-sinU1*sinSigma*cosAlpha1)
+	C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+	L := lambda - (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+	final := normBearing(rad2deg(math.Atan2(sinAlpha, -x)))
+	return New(rad2deg(phi2), normLon(p.Lon+rad2deg(L))), final
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
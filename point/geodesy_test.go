@@ -0,0 +1,131 @@
+package point
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"math"
+	"testing"
+)
+
+// degrees from degrees, minutes and seconds
+func dms(d, m, s float64) float64 {
+	if d < 0 {
+		return d - m/60 - s/3600
+	}
+	return d + m/60 + s/3600
+}
+
+// the difference of two bearings in arc seconds
+func bearingDiff(a, b float64) float64 {
+	return math.Abs(math.Mod(a-b+540, 360)-180) * 3600
+}
+
+// Flinders Peak and Buninyong, the reference case of Vincenty 1975 as
+// published by Geoscience Australia
+var (
+	flindersPeak   = New(dms(-37, 57, 3.72030), dms(144, 25, 29.52440))
+	buninyong      = New(dms(-37, 39, 10.15610), dms(143, 55, 35.38390))
+	referenceDist  = 54972.271 * distance.Meter
+	referenceInit  = dms(306, 52, 5.37)
+	referenceFinal = dms(307, 10, 25.07)
+)
+
+func TestVincentyInverse(t *testing.T) {
+	d, initial, final, err := flindersPeak.Vincenty(buninyong)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(float64(d-referenceDist)) > 0.001 {
+		t.Errorf("distance %.4fm, want %.3fm", float64(d), float64(referenceDist))
+	}
+	if diff := bearingDiff(initial, referenceInit); diff > 0.01 {
+		t.Errorf("initial bearing %f, want %f (%.3f\" off)", initial, referenceInit, diff)
+	}
+	if diff := bearingDiff(final, referenceFinal); diff > 0.01 {
+		t.Errorf("final bearing %f, want %f (%.3f\" off)", final, referenceFinal, diff)
+	}
+	if d, err := flindersPeak.VincentyDistance(flindersPeak); err != nil || d != 0 {
+		t.Errorf("distance to itself %v, %v", d, err)
+	}
+}
+
+func TestVincentyDirect(t *testing.T) {
+	q, final := flindersPeak.VincentyDestination(referenceInit, referenceDist)
+	// 1e-7 degrees is about 1cm
+	if math.Abs(q.Lat-buninyong.Lat) > 1e-7 || math.Abs(q.Lon-buninyong.Lon) > 1e-7 {
+		t.Errorf("destination %v, want %v", q, buninyong)
+	}
+	if diff := bearingDiff(final, referenceFinal); diff > 0.01 {
+		t.Errorf("final bearing %f, want %f (%.3f\" off)", final, referenceFinal, diff)
+	}
+}
+
+// the spherical examples of http://www.movable-type.co.uk/scripts/latlong.html
+// (R = 6371km)
+func TestBearing(t *testing.T) {
+	p := New(dms(50, 3, 59), -dms(5, 42, 53))
+	q := New(dms(58, 38, 38), -dms(3, 4, 12))
+	if diff := bearingDiff(p.Bearing(q), dms(9, 7, 11)); diff > 1 {
+		t.Errorf("initial bearing %f (%.1f\" off)", p.Bearing(q), diff)
+	}
+	if diff := bearingDiff(p.FinalBearing(q), dms(11, 16, 31)); diff > 1 {
+		t.Errorf("final bearing %f (%.1f\" off)", p.FinalBearing(q), diff)
+	}
+	for _, c := range []struct {
+		q       *Point
+		bearing float64
+	}{
+		{New(1, 0), 0},
+		{New(0, 1), 90},
+		{New(-1, 0), 180},
+		{New(0, -1), 270},
+	} {
+		if b := New(0, 0).Bearing(c.q); bearingDiff(b, c.bearing) > 1e-6 {
+			t.Errorf("bearing to %v is %f, want %f", c.q, b, c.bearing)
+		}
+	}
+}
+
+func TestDestination(t *testing.T) {
+	p := New(dms(53, 19, 14), -dms(1, 43, 47))
+	q := p.Destination(dms(96, 1, 18), 124.8*distance.Kilometer)
+	want := New(dms(53, 11, 18), dms(0, 8, 0))
+	// the reference is rounded to arc seconds
+	if math.Abs(q.Lat-want.Lat) > 1.0/3600 || math.Abs(q.Lon-want.Lon) > 1.0/3600 {
+		t.Errorf("destination %v, want %v", q, want)
+	}
+	if diff := bearingDiff(p.FinalBearing(q), dms(97, 30, 52)); diff > 2 {
+		t.Errorf("final bearing %f (%.1f\" off)", p.FinalBearing(q), diff)
+	}
+	// going back gives the start
+	r := q.Destination(normBearing(p.FinalBearing(q)+180), 124.8*distance.Kilometer)
+	if math.Abs(r.Lat-p.Lat) > 1e-9 || math.Abs(r.Lon-p.Lon) > 1e-9 {
+		t.Errorf("return to %v, want %v", r, p)
+	}
+}
+
+func TestTrackDistances(t *testing.T) {
+	// a path along the equator, the meridian through p crosses it at a
+	// right angle
+	start, end := New(0, 0), New(0, 10)
+	arc := func(deg float64) distance.Distance {
+		return distance.Distance(deg2rad(deg)) * distance.EarthRadius
+	}
+	for _, c := range []struct {
+		p            *Point
+		cross, along distance.Distance
+	}{
+		{New(1, 5), -arc(1), arc(5)},
+		{New(-2, 3), arc(2), arc(3)},
+		{New(1, -4), -arc(1), -arc(4)},
+		{New(0, 12), 0, arc(12)},
+	} {
+		if d := c.p.CrossTrackDistance(start, end); math.Abs(float64(d-c.cross)) > 0.001 {
+			t.Errorf("cross track distance of %v is %v, want %v", c.p, d, c.cross)
+		}
+		if d := c.p.AlongTrackDistance(start, end); math.Abs(float64(d-c.along)) > 0.001 {
+			t.Errorf("along track distance of %v is %v, want %v", c.p, d, c.along)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,135 @@
+package point
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// the maximum geohash precision, about 3.7cm × 1.9cm
+const MaxGeohashPrecision = 12
+
+// returns the geohash of p with the given number of characters (1 to
+// MaxGeohashPrecision)
+func (p *Point) Geohash(precision int) string {
+	if precision < 1 {
+		precision = 1
+	} else if precision > MaxGeohashPrecision {
+		precision = MaxGeohashPrecision
+	}
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+	var sb strings.Builder
+	bits, ch, even := 0, 0, true
+	for sb.Len() < precision {
+		if even {
+			mid := (minLon + maxLon) / 2
+			if p.Lon >= mid {
+				ch = ch<<1 | 1
+				minLon = mid
+			} else {
+				ch <<= 1
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if p.Lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		even = !even
+		if bits++; bits == 5 {
+			sb.WriteByte(geohashAlphabet[ch])
+			bits, ch = 0, 0
+		}
+	}
+	return sb.String()
+}
+
+// returns the cell of a geohash
+func GeohashBounds(hash string) (minLat, minLon, maxLat, maxLon float64, err error) {
+	if hash == "" {
+		err = errors.New("Empty geohash")
+		return
+	}
+	minLat, maxLat = -90.0, 90.0
+	minLon, maxLon = -180.0, 180.0
+	even := true
+	for _, c := range strings.ToLower(hash) {
+		v := strings.IndexRune(geohashAlphabet, c)
+		if v < 0 {
+			err = errors.New(fmt.Sprintf("Invalid character %q in geohash %q", c, hash))
+			return
+		}
+		for b := 4; b >= 0; b-- {
+			bit := v>>uint(b)&1 == 1
+			if even {
+				mid := (minLon + maxLon) / 2
+				if bit {
+					minLon = mid
+				} else {
+					maxLon = mid
+				}
+			} else {
+				mid := (minLat + maxLat) / 2
+				if bit {
+					minLat = mid
+				} else {
+					maxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return
+}
+
+// returns the center of the geohash cell
+func DecodeGeohash(hash string) (*Point, error) {
+	minLat, minLon, maxLat, maxLon, err := GeohashBounds(hash)
+	if err != nil {
+		return nil, err
+	}
+	return New((minLat+maxLat)/2, (minLon+maxLon)/2), nil
+}
+
+// Returns the geohash of the same precision which is the given number of
+// cells north and east (negative for south and west) of hash, wrapping
+// around the antimeridian. An error is returned beyond the poles.
+func GeohashNeighbour(hash string, north, east int) (string, error) {
+	minLat, minLon, maxLat, maxLon, err := GeohashBounds(hash)
+	if err != nil {
+		return "", err
+	}
+	h, w := maxLat-minLat, maxLon-minLon
+	lat := (minLat+maxLat)/2 + float64(north)*h
+	if lat < -90 || lat > 90 {
+		return "", errors.New(fmt.Sprintf("No neighbour of geohash %q beyond the pole", hash))
+	}
+	lon := (minLon+maxLon)/2 + float64(east)*w
+	lon = math.Mod(lon+540, 360) - 180
+	return New(lat, lon).Geohash(len(hash)), nil
+}
+
+// Returns the 8 neighbours of the geohash in the order N, NE, E, SE, S,
+// SW, W, NW. Neighbours beyond the poles are empty strings.
+func GeohashNeighbours(hash string) ([]string, error) {
+	if _, _, _, _, err := GeohashBounds(hash); err != nil {
+		return nil, err
+	}
+	dirs := [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	nb := make([]string, len(dirs))
+	for i, d := range dirs {
+		nb[i], _ = GeohashNeighbour(hash, d[0], d[1])
+	}
+	return nb, nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
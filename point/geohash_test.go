@@ -0,0 +1,91 @@
+package point
+
+import (
+	"testing"
+)
+
+// the examples of the geohash article on Wikipedia and of geohash.org
+func TestGeohash(t *testing.T) {
+	for _, c := range []struct {
+		p         *Point
+		precision int
+		hash      string
+	}{
+		{New(57.64911, 10.40744), 11, "u4pruydqqvj"},
+		{New(57.64911, 10.40744), 5, "u4pru"},
+		{New(42.605, -5.603), 5, "ezs42"},
+		// the precision is clamped to 1 to MaxGeohashPrecision
+		{New(42.605, -5.603), 0, "e"},
+		{New(57.64911, 10.40744), 20, "u4pruydqqvj8"},
+		// cells include their lower and left edges
+		{New(0, 0), 1, "s"},
+		{New(-90, -180), 2, "00"},
+		{New(90, 180), 2, "zz"},
+	} {
+		if got := c.p.Geohash(c.precision); got != c.hash {
+			t.Errorf("geohash of %v with precision %d is %q, want %q", c.p, c.precision, got, c.hash)
+		}
+	}
+}
+
+func TestGeohashBounds(t *testing.T) {
+	minLat, minLon, maxLat, maxLon, err := GeohashBounds("ezs42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if minLat != 42.5830078125 || minLon != -5.625 || maxLat != 42.626953125 || maxLon != -5.5810546875 {
+		t.Errorf("cell of ezs42 is %v,%v %v,%v, want 42.5830078125,-5.625 42.626953125,-5.5810546875", minLat, minLon, maxLat, maxLon)
+	}
+	p, err := DecodeGeohash("EZS42")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Lat != 42.60498046875 || p.Lon != -5.60302734375 {
+		t.Errorf("center of EZS42 is %v, want 42.60498046875,-5.60302734375", p)
+	}
+	for _, h := range []string{"", "ezs4a", "u4pr i"} {
+		if _, err := DecodeGeohash(h); err == nil {
+			t.Errorf("no error for geohash %q", h)
+		}
+	}
+}
+
+// The cells of precision 1 are 8 columns and 4 rows:
+//
+//	b c f g u v y z
+//	8 9 d e s t w x
+//	2 3 6 7 k m q r
+//	0 1 4 5 h j n p
+func TestGeohashNeighbours(t *testing.T) {
+	for _, c := range []struct {
+		hash string
+		nb   [8]string
+	}{
+		{"s", [8]string{"u", "v", "t", "m", "k", "7", "e", "g"}},
+		// wrapping around the antimeridian
+		{"x", [8]string{"z", "b", "8", "2", "r", "q", "w", "y"}},
+		// nothing beyond the poles
+		{"c", [8]string{"", "", "f", "d", "9", "8", "b", ""}},
+		{"0", [8]string{"2", "3", "1", "", "", "", "p", "r"}},
+		{"ezs42", [8]string{"ezs48", "ezs49", "ezs43", "ezs41", "ezs40", "ezefp", "ezefr", "ezefx"}},
+	} {
+		nb, err := GeohashNeighbours(c.hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range nb {
+			if nb[i] != c.nb[i] {
+				t.Errorf("neighbours of %q are %v, want %v", c.hash, nb, c.nb)
+				break
+			}
+		}
+	}
+	if h, err := GeohashNeighbour("ezs42", 2, -1); err != nil || h != "ezefz" {
+		t.Errorf("2 north and 1 west of ezs42 is %q %v, want ezefz", h, err)
+	}
+	if _, err := GeohashNeighbours("ezs4a"); err == nil {
+		t.Error("no error for an invalid geohash")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,35 @@
+package point
+
+import (
+	"math"
+)
+
+// Returns the x and y of the slippy map tile (web mercator, as used by
+// the OSM tile servers) containing p at the given zoom level. Latitudes
+// beyond ±85.0511° are clipped to the first or last row.
+func (p *Point) TileXY(zoom int) (x, y int) {
+	fx, fy := p.TileCoords(zoom)
+	max := int(math.Exp2(float64(zoom))) - 1
+	clamp := func(v float64) int {
+		i := int(math.Floor(v))
+		if i < 0 {
+			return 0
+		}
+		if i > max {
+			return max
+		}
+		return i
+	}
+	return clamp(fx), clamp(fy)
+}
+
+// Returns the position of p in tile units at the given zoom level, the
+// integer parts are the ones of TileXY(). Latitudes beyond ±85.0511° are
+// clipped.
+func (p *Point) TileCoords(zoom int) (x, y float64) {
+	n := math.Exp2(float64(zoom))
+	lat := deg2rad(math.Max(-85.05112877980659, math.Min(85.05112877980659, p.Lat)))
+	return (p.Lon + 180) / 360 * n, (1 - math.Log(math.Tan(lat)+1/math.Cos(lat))/math.Pi) / 2 * n
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,53 @@
+package point
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTileXY(t *testing.T) {
+	for _, c := range []struct {
+		p    *Point
+		zoom int
+		x, y int
+	}{
+		{New(50.8466, 4.3528), 0, 0, 0},
+		// the equator and the prime meridian are the lower and left edges
+		{New(0, 0), 1, 1, 1},
+		{New(0.001, -0.001), 1, 0, 0},
+		{New(50.8466, 4.3528), 10, 524, 343},
+		{New(50.8466, 4.3528), 16, 33560, 21984},
+		{New(-33.8568, 151.2153), 12, 3768, 2457},
+		// clipped to the first and last row and column
+		{New(89, 0), 2, 2, 0},
+		{New(-89, 180), 2, 3, 3},
+		{New(85.05112877980659, -180), 3, 0, 0},
+	} {
+		if x, y := c.p.TileXY(c.zoom); x != c.x || y != c.y {
+			t.Errorf("%v at zoom %d in tile %d/%d, want %d/%d", c.p, c.zoom, x, y, c.x, c.y)
+		}
+	}
+}
+
+func TestTileCoords(t *testing.T) {
+	for _, c := range []struct {
+		p    *Point
+		zoom int
+		x, y float64
+	}{
+		{New(0, 0), 0, 0.5, 0.5},
+		{New(0, -90), 2, 1, 2},
+		{New(85.05112877980659, 180), 1, 2, 0},
+		{New(-85.05112877980659, 0), 1, 1, 2},
+		// beyond the limit of web mercator
+		{New(90, 0), 1, 1, 0},
+		{New(50.8466, 4.3528), 10, 524.3812978, 343.5043832},
+	} {
+		x, y := c.p.TileCoords(c.zoom)
+		if math.Abs(x-c.x) > 1e-7 || math.Abs(y-c.y) > 1e-7 {
+			t.Errorf("%v at zoom %d at %v,%v, want %v,%v", c.p, c.zoom, x, y, c.x, c.y)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
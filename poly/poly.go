@@ -0,0 +1,192 @@
+// Package poly reads and writes the Osmosis polygon filter file format
+// (http://wiki.openstreetmap.org/wiki/Osmosis/Polygon_Filter_File_Format):
+//
+//	name
+//	1
+//	   4.30  50.80
+//	   4.45  50.80
+//	   4.45  50.90
+//	END
+//	!2
+//	   ...
+//	END
+//	END
+//
+// Every section is a ring, sections with a name starting with "!" are
+// holes of the ring containing them.
+package poly
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+	"github.com/brechtvm/osm/relation"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type Poly struct {
+	Name         string
+	MultiPolygon polygon.MultiPolygon
+}
+
+// reads a .poly file
+func ReadFile(file string) (*Poly, error) {
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	return Read(fh)
+}
+
+// reads the .poly format from r
+func Read(r io.Reader) (*Poly, error) {
+	s := bufio.NewScanner(r)
+	line := 0
+	next := func() (string, bool) {
+		for s.Scan() {
+			line++
+			if l := strings.TrimSpace(s.Text()); l != "" {
+				return l, true
+			}
+		}
+		return "", false
+	}
+
+	name, ok := next()
+	if !ok {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("Empty poly file")
+	}
+	p := &Poly{Name: name}
+	var outers, holes []polygon.Ring
+	for {
+		section, ok := next()
+		if !ok {
+			if err := s.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("Missing END at end of poly file")
+		}
+		if section == "END" {
+			break
+		}
+		var ring polygon.Ring
+		for {
+			l, ok := next()
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Missing END of section %q", section))
+			}
+			if l == "END" {
+				break
+			}
+			f := strings.Fields(l)
+			if len(f) != 2 {
+				return nil, errors.New(fmt.Sprintf("Invalid coordinates in line %d: %q", line, l))
+			}
+			lon, err := strconv.ParseFloat(f[0], 64)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid longitude in line %d: %s", line, err))
+			}
+			lat, err := strconv.ParseFloat(f[1], 64)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Invalid latitude in line %d: %s", line, err))
+			}
+			ring = append(ring, point.New(lat, lon))
+		}
+		if len(ring) > 0 && !ring[0].Equal(ring[len(ring)-1]) {
+			ring = append(ring, point.New(ring[0].Lat, ring[0].Lon))
+		}
+		if !ring.Closed() {
+			return nil, errors.New(fmt.Sprintf("Too few points in section %q", section))
+		}
+		if strings.HasPrefix(section, "!") {
+			holes = append(holes, ring)
+		} else {
+			outers = append(outers, ring)
+		}
+	}
+
+	inners := make([][]polygon.Ring, len(outers))
+	for _, h := range holes {
+		// the smallest outer ring containing the hole
+		best := -1
+		for i, o := range outers {
+			if o.ContainsRing(h) && (best == -1 || o.Area() < outers[best].Area()) {
+				best = i
+			}
+		}
+		if best == -1 {
+			return nil, errors.New("Hole outside of all outer rings")
+		}
+		inners[best] = append(inners[best], h)
+	}
+	for i, o := range outers {
+		p.MultiPolygon = append(p.MultiPolygon, polygon.New(o, inners[i]...))
+	}
+	return p, nil
+}
+
+// writes the polygon to a .poly file
+func (p *Poly) WriteFile(file string) error {
+	fh, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err = p.Write(fh); err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+// writes the polygon in .poly format to w, the sections are numbered
+// starting with 1
+func (p *Poly) Write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	name := p.Name
+	if name == "" {
+		name = "polygon"
+	}
+	fmt.Fprintln(b, name)
+	section := 0
+	ring := func(prefix string, r polygon.Ring) {
+		section++
+		fmt.Fprintf(b, "%s%d\n", prefix, section)
+		for _, q := range r {
+			fmt.Fprintf(b, "   %s   %s\n", strconv.FormatFloat(q.Lon, 'f', -1, 64), strconv.FormatFloat(q.Lat, 'f', -1, 64))
+		}
+		fmt.Fprintln(b, "END")
+	}
+	for _, pg := range p.MultiPolygon {
+		ring("", pg.Outer)
+		for _, in := range pg.Inners {
+			ring("!", in)
+		}
+	}
+	fmt.Fprintln(b, "END")
+	return b.Flush()
+}
+
+// returns the polygon of an area relation (e.g. an administrative
+// boundary) named after its name tag, see relation.MultiPolygon()
+func FromRelation(r *relation.Relation) (*Poly, error) {
+	mp, err := r.MultiPolygon()
+	if err != nil {
+		return nil, err
+	}
+	p := &Poly{MultiPolygon: mp, Name: fmt.Sprintf("relation_%d", r.Id_)}
+	if r.Tags_ != nil && r.Tags_.Get("name") != "" {
+		p.Name = r.Tags_.Get("name")
+	}
+	return p, nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
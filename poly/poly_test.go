@@ -0,0 +1,141 @@
+package poly
+
+import (
+	"bytes"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"strings"
+	"testing"
+)
+
+// a lake with an island with a pond in the first section, written with
+// the hole sections first and in scientific notation, the last ring is
+// not closed
+const lakes = `lakes
+!2
+   2 2
+   8 2
+   8 8
+   2 8
+   2 2
+END
+!4
+   4.5 4.5
+   5.5 4.5
+   5.5 5.5
+   4.5 5.5
+END
+1
+   0 0
+   1E+01 0
+   10 10
+   0 10
+   0 0
+END
+
+3
+   4 4
+   6 4
+   6 6
+   4 6
+END
+END
+`
+
+func sameRing(r polygon.Ring, ll ...float64) bool {
+	if len(r) != len(ll)/2 {
+		return false
+	}
+	for i, p := range r {
+		if p.Lat != ll[2*i] || p.Lon != ll[2*i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRead(t *testing.T) {
+	p, err := Read(strings.NewReader(lakes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "lakes" || len(p.MultiPolygon) != 2 {
+		t.Fatalf("%q with %d polygons, want lakes with 2", p.Name, len(p.MultiPolygon))
+	}
+	lake, island := p.MultiPolygon[0], p.MultiPolygon[1]
+	if !sameRing(lake.Outer, 0, 0, 0, 10, 10, 10, 10, 0, 0, 0) || len(lake.Inners) != 1 || lake.Inners[0].Area() != 36 {
+		t.Errorf("lake %v with %d holes", lake.Outer, len(lake.Inners))
+	}
+	// the pond is in the smallest ring containing it
+	if !sameRing(island.Outer, 4, 4, 4, 6, 6, 6, 6, 4, 4, 4) || len(island.Inners) != 1 || island.Inners[0].Area() != 1 {
+		t.Errorf("island %v with %d holes", island.Outer, len(island.Inners))
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, c := range []struct{ name, data string }{
+		{"empty", "\n\n"},
+		{"missing END of file", "x\n1\n 0 0\n 1 0\n 1 1\nEND\n"},
+		{"missing END of section", "x\n1\n 0 0\n 1 0\n 1 1\n"},
+		{"too few points", "x\n1\n 0 0\n 1 0\nEND\nEND\n"},
+		{"three coordinates", "x\n1\n 0 0 0\n 1 0\n 1 1\nEND\nEND\n"},
+		{"invalid longitude", "x\n1\n a 0\n 1 0\n 1 1\nEND\nEND\n"},
+		{"hole outside", "x\n1\n 0 0\n 1 0\n 1 1\nEND\n!2\n 5 5\n 6 5\n 6 6\nEND\nEND\n"},
+	} {
+		if _, err := Read(strings.NewReader(c.data)); err == nil {
+			t.Errorf("%s: no error", c.name)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	outer := polygon.Ring{point.New(50.8, 4.3), point.New(50.8, 4.45), point.New(50.9, 4.45), point.New(50.8, 4.3)}
+	hole := polygon.Ring{point.New(50.82, 4.4), point.New(50.85, 4.42), point.New(50.83, 4.43), point.New(50.82, 4.4)}
+	p := &Poly{MultiPolygon: polygon.MultiPolygon{polygon.New(outer, hole), polygon.New(outer)}}
+	var b bytes.Buffer
+	if err := p.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "polygon\n1\n   4.3   50.8\n   4.45   50.8\n   4.45   50.9\n   4.3   50.8\nEND\n" +
+		"!2\n   4.4   50.82\n   4.42   50.85\n   4.43   50.83\n   4.4   50.82\nEND\n" +
+		"3\n   4.3   50.8\n   4.45   50.8\n   4.45   50.9\n   4.3   50.8\nEND\nEND\n"
+	if b.String() != want {
+		t.Errorf("written\n%s\nwant\n%s", b.String(), want)
+	}
+
+	// and read back
+	q, err := Read(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.MultiPolygon) != 2 || len(q.MultiPolygon[0].Inners) != 1 || len(q.MultiPolygon[1].Inners) != 0 {
+		t.Fatalf("read back %d polygons", len(q.MultiPolygon))
+	}
+	if !sameRing(q.MultiPolygon[0].Inners[0], 50.82, 4.4, 50.85, 4.42, 50.83, 4.43, 50.82, 4.4) {
+		t.Errorf("hole read back as %v", q.MultiPolygon[0].Inners[0])
+	}
+}
+
+func TestFromRelation(t *testing.T) {
+	var nl []*node.Node
+	for i, ll := range [][2]float64{{0, 0}, {0, 1}, {1, 1}, {1, 0}} {
+		nl = append(nl, &node.Node{Id_: int64(i + 1), Position_: point.New(ll[0], ll[1])})
+	}
+	w := &way.Way{Id_: 10, Nodes_: append(nl, nl[0]), Tags_: tags.New()}
+	r := &relation.Relation{Id_: 7, Tags_: &tags.Tags{"type": "boundary"}, Members_: []*relation.Member{{Type_: item.TypeWay, Id_: 10, Ref: w}}}
+	p, err := FromRelation(r)
+	if err != nil || p.Name != "relation_7" || len(p.MultiPolygon) != 1 {
+		t.Errorf("FromRelation() = %v %v", p, err)
+	}
+	r.Tags_.Add("name", "Square")
+	if p, _ = FromRelation(r); p.Name != "Square" {
+		t.Errorf("named %q, want Square", p.Name)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,86 @@
+package polygon
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"math"
+)
+
+// The geodesic area on a sphere with radius distance.EarthRadius using
+// the spherical excess of the ring (see "Some Algorithms for Polygons on
+// a Sphere", Chamberlain & Duquette, JPL 2007). Compared to the WGS84
+// ellipsoid the error is well below 0.5%.
+//
+// The area is positive for counter clockwise rings. Edges are taken the
+// shorter way round, so rings may cross the antimeridian, but rings
+// around a pole are not supported.
+func (r Ring) SignedGeodesicArea() distance.Area {
+	var a float64
+	for i := 0; i < len(r)-1; i++ {
+		p, q := r[i], r[i+1]
+		dlon := q.Lon - p.Lon
+		if dlon > 180 {
+			dlon -= 360
+		} else if dlon < -180 {
+			dlon += 360
+		}
+		a += deg2rad(dlon) * (2 + math.Sin(deg2rad(p.Lat)) + math.Sin(deg2rad(q.Lat)))
+	}
+	er := float64(distance.EarthRadius)
+	return distance.Area(-a * er * er / 2)
+}
+
+// the geodesic area of the ring in square meters
+func (r Ring) GeodesicArea() distance.Area {
+	return distance.Area(math.Abs(float64(r.SignedGeodesicArea())))
+}
+
+// the length of the ring (great circle distances)
+func (r Ring) Perimeter() distance.Distance {
+	var l distance.Distance
+	for i := 0; i < len(r)-1; i++ {
+		l += r[i].DistanceOf(r[i+1])
+	}
+	return l
+}
+
+// the geodesic area of the outer ring minus the holes in square meters
+func (p *Polygon) GeodesicArea() distance.Area {
+	a := p.Outer.GeodesicArea()
+	for _, in := range p.Inners {
+		a -= in.GeodesicArea()
+	}
+	return a
+}
+
+// the length of all rings (outer and inner)
+func (p *Polygon) Perimeter() distance.Distance {
+	l := p.Outer.Perimeter()
+	for _, in := range p.Inners {
+		l += in.Perimeter()
+	}
+	return l
+}
+
+// the sum of the geodesic areas of all polygons in square meters
+func (m MultiPolygon) GeodesicArea() distance.Area {
+	var a distance.Area
+	for _, p := range m {
+		a += p.GeodesicArea()
+	}
+	return a
+}
+
+// the sum of the perimeters of all polygons
+func (m MultiPolygon) Perimeter() distance.Distance {
+	var l distance.Distance
+	for _, p := range m {
+		l += p.Perimeter()
+	}
+	return l
+}
+
+func deg2rad(v float64) float64 {
+	return v * math.Pi / 180.0
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
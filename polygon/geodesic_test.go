@@ -0,0 +1,75 @@
+package polygon
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/point"
+	"math"
+	"testing"
+)
+
+// the counter clockwise ring around the cell from lon0 east to lon1
+func box(lat0, lon0, lat1, lon1 float64) Ring {
+	return Ring{point.New(lat0, lon0), point.New(lat0, lon1), point.New(lat1, lon1), point.New(lat1, lon0), point.New(lat0, lon0)}
+}
+
+func sameArea(a, b distance.Area, rel float64) bool {
+	return math.Abs(float64(a-b)) <= rel*math.Abs(float64(b))
+}
+
+// The area of a cell between two parallels and two meridians on the
+// sphere is R² Δλ (sin φ1 - sin φ0).
+func TestGeodesicAreaCell(t *testing.T) {
+	for _, c := range []struct {
+		r    Ring
+		want distance.Area
+	}{
+		// 1° x 1° at 51°N 4°E, R² π/180 (sin 52° - sin 51°)
+		{box(51, 4, 52, 5), 7696867319.8},
+		// 2° x 1° on the equator, R² 2π/180 sin 1°
+		{box(0, 0, 1, 2), 24727367980.5},
+		// the same across the antimeridian
+		{box(0, 179, 1, -179), 24727367980.5},
+		// and south of the equator
+		{box(-1, -1, 0, 1), 24727367980.5},
+	} {
+		if got := c.r.SignedGeodesicArea(); !sameArea(got, c.want, 1e-9) {
+			t.Errorf("%v: area %.1f, want %.1f", c.r, float64(got), float64(c.want))
+		}
+		if got := c.r.Reverse().SignedGeodesicArea(); !sameArea(got, -c.want, 1e-9) {
+			t.Errorf("%v reversed: area %.1f, want %.1f", c.r, float64(got), float64(-c.want))
+		}
+	}
+}
+
+// Wyoming, between 41°N and 45°N and 104°03'W and 111°03'W, has an area
+// of 253335km² (US Census Bureau). The sphere is within the 0.5% stated
+// for SignedGeodesicArea().
+func TestGeodesicAreaWyoming(t *testing.T) {
+	w := -(111 + 3.0/60)
+	wy := New(box(41, w, 45, w+7))
+	if got := wy.GeodesicArea(); !sameArea(got, 253335*distance.SquareKilometer, 0.005) {
+		t.Errorf("Wyoming has %s, want 253335km²", got)
+	}
+}
+
+func TestGeodesicAreaHoles(t *testing.T) {
+	p := New(box(0, 0, 2, 2), box(0.5, 0.5, 1.5, 1.5).Reverse())
+	want := box(0, 0, 2, 2).GeodesicArea() - box(0.5, 0.5, 1.5, 1.5).GeodesicArea()
+	if got := p.GeodesicArea(); !sameArea(got, want, 1e-12) {
+		t.Errorf("area with hole %s, want %s", got, want)
+	}
+	mp := MultiPolygon{p, New(box(10, 10, 11, 11))}
+	if got := mp.GeodesicArea(); !sameArea(got, want+box(10, 10, 11, 11).GeodesicArea(), 1e-12) {
+		t.Errorf("multipolygon area %s", got)
+	}
+}
+
+// the perimeter of the 1° cell at 51°N along great circles: two meridian
+// edges of 111.195km and the chords of the parallels at 51° and 52°
+func TestPerimeter(t *testing.T) {
+	if got := box(51, 4, 52, 5).Perimeter(); math.Abs(float64(got)-360824.4) > 0.1 {
+		t.Errorf("perimeter %s, want 360824.4m", got)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,223 @@
+package polygon
+
+import (
+	"container/heap"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/point"
+	"math"
+	"sort"
+)
+
+// rings of the polygon as planar coordinates: x is the longitude scaled
+// by the cosine of the latitude of the outer ring's center, y the latitude
+type plane struct {
+	k     float64
+	rings [][][2]float64
+}
+
+func (p *Polygon) plane() *plane {
+	bb, err := p.Outer.BoundingBox()
+	if err != nil {
+		return nil
+	}
+	pl := &plane{k: math.Cos(deg2rad((bb.LowerLeft.Lat + bb.UpperRight.Lat) / 2))}
+	for _, r := range append([]Ring{p.Outer}, p.Inners...) {
+		pr := make([][2]float64, len(r))
+		for i, q := range r {
+			pr[i] = [2]float64{q.Lon * pl.k, q.Lat}
+		}
+		pl.rings = append(pl.rings, pr)
+	}
+	return pl
+}
+
+// the distance of (x, y) from the nearest edge, negative outside
+func (pl *plane) signedDistance(x, y float64) float64 {
+	inside := false
+	min := math.Inf(1)
+	for _, r := range pl.rings {
+		for i := 0; i < len(r)-1; i++ {
+			a, b := r[i], r[i+1]
+			if (a[1] > y) != (b[1] > y) && x < (b[0]-a[0])*(y-a[1])/(b[1]-a[1])+a[0] {
+				inside = !inside
+			}
+			min = math.Min(min, segDist(x, y, a, b))
+		}
+	}
+	if inside {
+		return min
+	}
+	return -min
+}
+
+func segDist(x, y float64, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	px, py := a[0], a[1]
+	if dx != 0 || dy != 0 {
+		t := ((x-a[0])*dx + (y-a[1])*dy) / (dx*dx + dy*dy)
+		if t > 1 {
+			px, py = b[0], b[1]
+		} else if t > 0 {
+			px, py = a[0]+dx*t, a[1]+dy*t
+		}
+	}
+	return math.Hypot(x-px, y-py)
+}
+
+type cell struct {
+	x, y, h, d, max float64
+}
+
+func newCell(pl *plane, x, y, h float64) *cell {
+	d := pl.signedDistance(x, y)
+	return &cell{x: x, y: y, h: h, d: d, max: d + h*math.Sqrt2}
+}
+
+type cellQueue []*cell
+
+func (q cellQueue) Len() int            { return len(q) }
+func (q cellQueue) Less(i, j int) bool  { return q[i].max > q[j].max }
+func (q cellQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *cellQueue) Push(x interface{}) { *q = append(*q, x.(*cell)) }
+func (q *cellQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// Returns the pole of inaccessibility, the point inside the polygon
+// farthest from its boundary (polylabel algorithm), and its distance from
+// the boundary. The result is within the tolerance of the optimum. This
+// is a good position for a label, unlike the centroid it is always
+// inside.
+func (p *Polygon) PoleOfInaccessibility(tolerance distance.Distance) (*point.Point, distance.Distance) {
+	pl := p.plane()
+	if pl == nil {
+		return nil, 0
+	}
+	degree := float64(distance.EarthRadius) * math.Pi / 180
+	precision := float64(tolerance) / degree
+	if precision <= 0 {
+		precision = 1e-9
+	}
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, q := range pl.rings[0] {
+		minX, maxX = math.Min(minX, q[0]), math.Max(maxX, q[0])
+		minY, maxY = math.Min(minY, q[1]), math.Max(maxY, q[1])
+	}
+	size := math.Min(maxX-minX, maxY-minY)
+	if size == 0 {
+		return point.New(minY, minX/pl.k), 0
+	}
+	h := size / 2
+	q := &cellQueue{}
+	for x := minX; x < maxX; x += size {
+		for y := minY; y < maxY; y += size {
+			heap.Push(q, newCell(pl, x+h, y+h, h))
+		}
+	}
+	best := newCell(pl, (minX+maxX)/2, (minY+maxY)/2, 0)
+	if c := p.Centroid(); c != nil {
+		if cc := newCell(pl, c.Lon*pl.k, c.Lat, 0); cc.d > best.d {
+			best = cc
+		}
+	}
+	for q.Len() > 0 {
+		c := heap.Pop(q).(*cell)
+		if c.d > best.d {
+			best = c
+		}
+		if c.max-best.d <= precision {
+			continue
+		}
+		h := c.h / 2
+		heap.Push(q, newCell(pl, c.x-h, c.y-h, h))
+		heap.Push(q, newCell(pl, c.x+h, c.y-h, h))
+		heap.Push(q, newCell(pl, c.x-h, c.y+h, h))
+		heap.Push(q, newCell(pl, c.x+h, c.y+h, h))
+	}
+	return point.New(best.y, best.x/pl.k), distance.Distance(math.Max(0, best.d) * degree)
+}
+
+// the pole of inaccessibility of the polygon for which it is farthest
+// from the boundary, see Polygon.PoleOfInaccessibility()
+func (m MultiPolygon) PoleOfInaccessibility(tolerance distance.Distance) (*point.Point, distance.Distance) {
+	var best *point.Point
+	var bd distance.Distance = -1
+	for _, p := range m {
+		if q, d := p.PoleOfInaccessibility(tolerance); q != nil && d > bd {
+			best, bd = q, d
+		}
+	}
+	if best == nil {
+		return nil, 0
+	}
+	return best, bd
+}
+
+// Returns a point which is guaranteed to be inside the polygon (unless it
+// has no area): the middle of the widest part of a horizontal line
+// through the polygon. This is much cheaper than the pole of
+// inaccessibility.
+func (p *Polygon) PointOnSurface() *point.Point {
+	bb, err := p.Outer.BoundingBox()
+	if err != nil {
+		return nil
+	}
+	rings := append([]Ring{p.Outer}, p.Inners...)
+	// use a latitude between the vertices closest to the middle, so the
+	// line does not run through a vertex
+	mid := (bb.LowerLeft.Lat + bb.UpperRight.Lat) / 2
+	below, above := bb.LowerLeft.Lat, bb.UpperRight.Lat
+	for _, r := range rings {
+		for _, q := range r {
+			if q.Lat <= mid && q.Lat > below {
+				below = q.Lat
+			}
+			if q.Lat > mid && q.Lat < above {
+				above = q.Lat
+			}
+		}
+	}
+	y := (below + above) / 2
+	if below == mid {
+		// a vertex at the middle, look above it
+		y = (mid + above) / 2
+	}
+	var xs []float64
+	for _, r := range rings {
+		for i := 0; i < len(r)-1; i++ {
+			a, b := r[i], r[i+1]
+			if (a.Lat > y) != (b.Lat > y) {
+				xs = append(xs, a.Lon+(y-a.Lat)*(b.Lon-a.Lon)/(b.Lat-a.Lat))
+			}
+		}
+	}
+	sort.Float64s(xs)
+	var best *point.Point
+	w := -1.0
+	for i := 0; i+1 < len(xs); i += 2 {
+		if xs[i+1]-xs[i] > w {
+			w = xs[i+1] - xs[i]
+			best = point.New(y, (xs[i]+xs[i+1])/2)
+		}
+	}
+	return best
+}
+
+// a point inside the largest polygon, see Polygon.PointOnSurface()
+func (m MultiPolygon) PointOnSurface() *point.Point {
+	var largest *Polygon
+	for _, p := range m {
+		if largest == nil || p.Area() > largest.Area() {
+			largest = p
+		}
+	}
+	if largest == nil {
+		return nil
+	}
+	return largest.PointOnSurface()
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
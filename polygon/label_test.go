@@ -0,0 +1,92 @@
+package polygon
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/point"
+	"math"
+	"testing"
+)
+
+// one degree of a great circle
+const degree = distance.Distance(6371000 * math.Pi / 180)
+
+// a ring over the positions given as lat, lon pairs, closed
+func ring(ll ...float64) Ring {
+	var r Ring
+	for i := 0; i+1 < len(ll); i += 2 {
+		r = append(r, point.New(ll[i], ll[i+1]))
+	}
+	return append(r, r[0])
+}
+
+// The polygons are centered on the equator, so longitudes are not scaled.
+// In both the largest circle touches two straight edges and a corner
+// between them: its center is at t° from both edges and √2(2 - t)° from
+// the corner, t = 4 - 2√2.
+var (
+	poleRadius = 4 - 2*math.Sqrt2
+	// an L of 2° wide bars, the corner inside is at -1, 2
+	lShape = New(ring(-3, 0, -3, 6, -1, 6, -1, 2, 3, 2, 3, 0))
+	// a 6° square with an off-center 3° square hole, the gap is widest
+	// between the lower left corners
+	withHole = New(box(-3, -3, 3, 3), box(-1, -1, 2, 2))
+)
+
+func checkPole(t *testing.T, name string, p *point.Point, d distance.Distance, lat, lon float64, tolerance distance.Distance) {
+	t.Helper()
+	if want := distance.Distance(poleRadius) * degree; d > want || d < want-tolerance {
+		t.Errorf("%s: pole %v from the boundary, want %v", name, d, want)
+	}
+	if p == nil || math.Abs(p.Lat-lat) > 0.01 || math.Abs(p.Lon-lon) > 0.01 {
+		t.Errorf("%s: pole at %v, want %v,%v", name, p, lat, lon)
+	}
+}
+
+func TestPoleOfInaccessibility(t *testing.T) {
+	tolerance := 100 * distance.Meter
+	p, d := lShape.PoleOfInaccessibility(tolerance)
+	checkPole(t, "L", p, d, poleRadius-3, poleRadius, tolerance)
+	// unlike the centroid in the corner outside
+	if c := lShape.Centroid(); lShape.Contains(c) {
+		t.Errorf("centroid %v of the L is inside", c)
+	}
+
+	p, d = withHole.PoleOfInaccessibility(tolerance)
+	checkPole(t, "hole", p, d, poleRadius-3, poleRadius-3, tolerance)
+	if c := withHole.Centroid(); withHole.Contains(c) {
+		t.Errorf("centroid %v of the square with a hole is inside", c)
+	}
+
+	// the pole of the polygon farthest from its boundary
+	m := MultiPolygon{New(box(10, 10, 11, 11)), withHole, New(box(-10, -10, -8, -8))}
+	p, d = m.PoleOfInaccessibility(tolerance)
+	checkPole(t, "multipolygon", p, d, poleRadius-3, poleRadius-3, tolerance)
+
+	if p, _ = New(Ring{}).PoleOfInaccessibility(tolerance); p != nil {
+		t.Errorf("pole %v of an empty polygon", p)
+	}
+}
+
+// a coarser tolerance gives a pole closer to the boundary, but within the
+// tolerance
+func TestPoleOfInaccessibilityTolerance(t *testing.T) {
+	for _, tolerance := range []distance.Distance{10 * distance.Kilometer, distance.Kilometer, distance.Meter} {
+		p, d := lShape.PoleOfInaccessibility(tolerance)
+		if want := distance.Distance(poleRadius) * degree; d > want || d < want-tolerance {
+			t.Errorf("tolerance %v: pole %v from the boundary, want %v", tolerance, d, want)
+		}
+		if !lShape.Contains(p) {
+			t.Errorf("tolerance %v: pole %v outside", tolerance, p)
+		}
+	}
+}
+
+func TestPointOnSurface(t *testing.T) {
+	for _, p := range []*Polygon{lShape, withHole, New(ring(0, 0, 0, 2, 2, 1))} {
+		if q := p.PointOnSurface(); q == nil || !p.Contains(q) {
+			t.Errorf("point on surface %v not inside", q)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,251 @@
+package polygon
+
+import (
+	"errors"
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/point"
+	"math"
+)
+
+// A Ring is a closed line, the first and the last point are equal. All
+// computations are done on lat/lon as planar coordinates (i.e. the area
+// is in square degrees), see the geodesic functions for real units.
+type Ring []*point.Point
+
+// A Polygon is an outer ring with optional inner rings (holes). The outer
+// ring is counter clockwise, the inner rings are clockwise.
+type Polygon struct {
+	Outer  Ring
+	Inners []Ring
+}
+
+// A MultiPolygon is a list of polygons which do not overlap
+type MultiPolygon []*Polygon
+
+// returns true if the first and last point are equal and the ring has at
+// least three different points
+func (r Ring) Closed() bool {
+	return len(r) >= 4 && r[0].Equal(r[len(r)-1])
+}
+
+// the signed area (positive if counter clockwise) in square degrees
+func (r Ring) SignedArea() float64 {
+	var a float64
+	for i := 0; i < len(r)-1; i++ {
+		a += r[i].Lon*r[i+1].Lat - r[i+1].Lon*r[i].Lat
+	}
+	return a / 2
+}
+
+// the area in square degrees
+func (r Ring) Area() float64 {
+	return math.Abs(r.SignedArea())
+}
+
+func (r Ring) IsClockwise() bool {
+	return r.SignedArea() < 0
+}
+
+// returns a reversed copy of the ring
+func (r Ring) Reverse() Ring {
+	n := make(Ring, len(r))
+	for i, p := range r {
+		n[len(r)-1-i] = p
+	}
+	return n
+}
+
+// the centroid of the area enclosed by the ring
+func (r Ring) Centroid() *point.Point {
+	var cx, cy, a float64
+	for i := 0; i < len(r)-1; i++ {
+		ap := r[i].Lon*r[i+1].Lat - r[i+1].Lon*r[i].Lat
+		cx += (r[i].Lon + r[i+1].Lon) * ap
+		cy += (r[i].Lat + r[i+1].Lat) * ap
+		a += ap
+	}
+	if a == 0 {
+		return nil
+	}
+	return point.New(cy/(3*a), cx/(3*a))
+}
+
+// checks if p is inside the ring (even-odd rule), points on the boundary
+// may be inside or outside
+func (r Ring) Contains(p *point.Point) bool {
+	odd := false
+	j := len(r) - 1
+	for i := 0; i < len(r); i++ {
+		if (r[i].Lat > p.Lat) != (r[j].Lat > p.Lat) &&
+			p.Lon < (r[j].Lon-r[i].Lon)*(p.Lat-r[i].Lat)/(r[j].Lat-r[i].Lat)+r[i].Lon {
+			odd = !odd
+		}
+		j = i
+	}
+	return odd
+}
+
+// checks if the ring q is inside r, rings may touch each other, but must
+// not cross
+func (r Ring) ContainsRing(q Ring) bool {
+	for _, p := range q {
+		onRing := false
+		for _, s := range r {
+			if s.Equal(p) {
+				onRing = true
+				break
+			}
+		}
+		if !onRing {
+			return r.Contains(p)
+		}
+	}
+	// all points of q are on r, use a point inside q
+	if c := q.Centroid(); c != nil {
+		return r.Contains(c)
+	}
+	return false
+}
+
+func (r Ring) BoundingBox() (*bbox.BBox, error) {
+	if len(r) == 0 {
+		return nil, errors.New("Empty ring")
+	}
+	llat, llon := r[0].Lat, r[0].Lon
+	ulat, ulon := r[0].Lat, r[0].Lon
+	for _, p := range r[1:] {
+		llat = math.Min(llat, p.Lat)
+		ulat = math.Max(ulat, p.Lat)
+		llon = math.Min(llon, p.Lon)
+		ulon = math.Max(ulon, p.Lon)
+	}
+	return &bbox.BBox{LowerLeft: point.New(llat, llon), UpperRight: point.New(ulat, ulon)}, nil
+}
+
+// returns a polygon with the given rings, the rings are oriented as
+// needed (outer ring counter clockwise, inner rings clockwise)
+func New(outer Ring, inners ...Ring) *Polygon {
+	if outer.IsClockwise() {
+		outer = outer.Reverse()
+	}
+	p := &Polygon{Outer: outer}
+	for _, in := range inners {
+		if !in.IsClockwise() {
+			in = in.Reverse()
+		}
+		p.Inners = append(p.Inners, in)
+	}
+	return p
+}
+
+// the area of the outer ring minus the area of the holes in square degrees
+func (p *Polygon) Area() float64 {
+	a := p.Outer.Area()
+	for _, in := range p.Inners {
+		a -= in.Area()
+	}
+	return a
+}
+
+// the centroid of the polygon, holes are taken into account
+func (p *Polygon) Centroid() *point.Point {
+	c := p.Outer.Centroid()
+	if c == nil {
+		return nil
+	}
+	a := p.Outer.Area()
+	lat, lon, den := c.Lat*a, c.Lon*a, a
+	for _, in := range p.Inners {
+		ic := in.Centroid()
+		if ic == nil {
+			continue
+		}
+		ia := in.Area()
+		lat -= ic.Lat * ia
+		lon -= ic.Lon * ia
+		den -= ia
+	}
+	if den == 0 {
+		return nil
+	}
+	return point.New(lat/den, lon/den)
+}
+
+// checks if q is inside the outer ring and not inside one of the holes
+func (p *Polygon) Contains(q *point.Point) bool {
+	if !p.Outer.Contains(q) {
+		return false
+	}
+	for _, in := range p.Inners {
+		if in.Contains(q) {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Polygon) BoundingBox() (*bbox.BBox, error) {
+	return p.Outer.BoundingBox()
+}
+
+// the sum of the areas of all polygons in square degrees
+func (m MultiPolygon) Area() float64 {
+	var a float64
+	for _, p := range m {
+		a += p.Area()
+	}
+	return a
+}
+
+// the centroid of all polygons, weighted by their geodesic area
+func (m MultiPolygon) Centroid() *point.Point {
+	var lat, lon, den float64
+	for _, p := range m {
+		c := p.Centroid()
+		if c == nil {
+			continue
+		}
+		a := float64(p.GeodesicArea())
+		lat += c.Lat * a
+		lon += c.Lon * a
+		den += a
+	}
+	if den == 0 {
+		return nil
+	}
+	return point.New(lat/den, lon/den)
+}
+
+// checks if q is inside one of the polygons
+func (m MultiPolygon) Contains(q *point.Point) bool {
+	for _, p := range m {
+		if p.Contains(q) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m MultiPolygon) BoundingBox() (*bbox.BBox, error) {
+	var bb *bbox.BBox
+	for _, p := range m {
+		b, err := p.BoundingBox()
+		if err != nil {
+			return nil, err
+		}
+		if bb == nil {
+			bb = b
+			continue
+		}
+		bb.LowerLeft.Lat = math.Min(bb.LowerLeft.Lat, b.LowerLeft.Lat)
+		bb.LowerLeft.Lon = math.Min(bb.LowerLeft.Lon, b.LowerLeft.Lon)
+		bb.UpperRight.Lat = math.Max(bb.UpperRight.Lat, b.UpperRight.Lat)
+		bb.UpperRight.Lon = math.Max(bb.UpperRight.Lon, b.UpperRight.Lon)
+	}
+	if bb == nil {
+		return nil, errors.New("Empty multipolygon")
+	}
+	return bb, nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
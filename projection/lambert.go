@@ -0,0 +1,151 @@
+package projection
+
+import (
+	"github.com/brechtvm/osm/point"
+	"math"
+)
+
+// a Lambert conformal conic projection with two standard parallels
+// (EPSG method 9802), with an optional datum shift from WGS84
+type lambert struct {
+	epsg          int
+	ell           ellipsoid
+	lon0          float64
+	x0, y0        float64
+	n, f, rho0, e float64
+	toWGS84       *helmert
+}
+
+// Belgian Lambert 72 (EPSG:31370) on the Belgian Datum 1972, the WGS84
+// positions are transformed with the EPSG:15929 parameters (accurate to
+// about a metre)
+var Lambert72 Projection = newLambert(31370, international,
+	51.16666723333333, 49.8333339, 90, 4.367486666666666,
+	150000.013, 5400088.438,
+	&helmert{tx: -106.8686, ty: 52.2978, tz: -103.7239, rx: 0.3366, ry: -0.457, rz: 1.8422, s: -1.2747})
+
+// Belgian Lambert 2008 (EPSG:3812) on ETRS89, which is treated as equal
+// to WGS84
+var Lambert2008 Projection = newLambert(3812, grs80,
+	49.83333333333334, 51.16666666666666, 50.797815, 4.359215833333333,
+	649328, 665262, nil)
+
+func newLambert(epsg int, ell ellipsoid, lat1, lat2, lat0, lon0, x0, y0 float64, h *helmert) *lambert {
+	l := &lambert{epsg: epsg, ell: ell, lon0: lon0 * math.Pi / 180, x0: x0, y0: y0, e: ell.e(), toWGS84: h}
+	r := math.Pi / 180
+	m1, m2 := l.m(lat1*r), l.m(lat2*r)
+	t1, t2 := l.t(lat1*r), l.t(lat2*r)
+	l.n = (math.Log(m1) - math.Log(m2)) / (math.Log(t1) - math.Log(t2))
+	l.f = m1 / (l.n * math.Pow(t1, l.n))
+	l.rho0 = l.rho(lat0 * r)
+	return l
+}
+
+func (l *lambert) m(phi float64) float64 {
+	s := l.e * math.Sin(phi)
+	return math.Cos(phi) / math.Sqrt(1-s*s)
+}
+
+func (l *lambert) t(phi float64) float64 {
+	s := l.e * math.Sin(phi)
+	return math.Tan(math.Pi/4-phi/2) / math.Pow((1-s)/(1+s), l.e/2)
+}
+
+func (l *lambert) rho(phi float64) float64 {
+	if phi >= math.Pi/2 {
+		return 0
+	}
+	return l.ell.a * l.f * math.Pow(l.t(phi), l.n)
+}
+
+func (l *lambert) EPSG() int { return l.epsg }
+
+func (l *lambert) Forward(p *point.Point) Coord {
+	if l.toWGS84 != nil {
+		p = l.toWGS84.inverse(wgs84, l.ell, p)
+	}
+	rho := l.rho(p.Lat * math.Pi / 180)
+	theta := l.n * (p.Lon*math.Pi/180 - l.lon0)
+	return Coord{X: l.x0 + rho*math.Sin(theta), Y: l.y0 + l.rho0 - rho*math.Cos(theta)}
+}
+
+func (l *lambert) Inverse(c Coord) *point.Point {
+	dx, dy := c.X-l.x0, l.rho0-(c.Y-l.y0)
+	rho := math.Copysign(math.Hypot(dx, dy), l.n)
+	theta := math.Atan2(dx, dy)
+	t := math.Pow(rho/(l.ell.a*l.f), 1/l.n)
+	phi := math.Pi/2 - 2*math.Atan(t)
+	for i := 0; i < 10; i++ {
+		s := l.e * math.Sin(phi)
+		next := math.Pi/2 - 2*math.Atan(t*math.Pow((1-s)/(1+s), l.e/2))
+		if math.Abs(next-phi) < 1e-12 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	p := point.New(phi*180/math.Pi, (theta/l.n+l.lon0)*180/math.Pi)
+	if l.toWGS84 != nil {
+		p = l.toWGS84.forward(l.ell, wgs84, p)
+	}
+	return p
+}
+
+// a 7 parameter Helmert transformation (position vector convention) from
+// a local datum to WGS84: translations in metres, rotations in arc seconds
+// and the scale in ppm
+type helmert struct {
+	tx, ty, tz, rx, ry, rz, s float64
+}
+
+func (h *helmert) apply(sign float64, x, y, z float64) (float64, float64, float64) {
+	as := math.Pi / 180 / 3600
+	rx, ry, rz := sign*h.rx*as, sign*h.ry*as, sign*h.rz*as
+	s := 1 + sign*h.s*1e-6
+	return sign*h.tx + s*(x-rz*y+ry*z),
+		sign*h.ty + s*(rz*x+y-rx*z),
+		sign*h.tz + s*(-ry*x+rx*y+z)
+}
+
+// transforms p from the local datum (on ellipsoid from) to WGS84 (on
+// ellipsoid to)
+func (h *helmert) forward(from, to ellipsoid, p *point.Point) *point.Point {
+	x, y, z := toECEF(from, p)
+	x, y, z = h.apply(1, x, y, z)
+	return fromECEF(to, x, y, z)
+}
+
+// the reverse of forward(), by applying the negated parameters
+func (h *helmert) inverse(from, to ellipsoid, p *point.Point) *point.Point {
+	x, y, z := toECEF(from, p)
+	x, y, z = h.apply(-1, x, y, z)
+	return fromECEF(to, x, y, z)
+}
+
+// earth centered, earth fixed cartesian coordinates of p (at height 0)
+func toECEF(e ellipsoid, p *point.Point) (float64, float64, float64) {
+	phi, lambda := p.Lat*math.Pi/180, p.Lon*math.Pi/180
+	e2 := e.e2()
+	n := e.a / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+	return n * math.Cos(phi) * math.Cos(lambda), n * math.Cos(phi) * math.Sin(lambda), n * (1 - e2) * math.Sin(phi)
+}
+
+// the position of cartesian coordinates, the height is dropped
+func fromECEF(e ellipsoid, x, y, z float64) *point.Point {
+	e2 := e.e2()
+	pr := math.Hypot(x, y)
+	phi := math.Atan2(z, pr*(1-e2))
+	for i := 0; i < 10; i++ {
+		n := e.a / math.Sqrt(1-e2*math.Sin(phi)*math.Sin(phi))
+		h := pr/math.Cos(phi) - n
+		next := math.Atan2(z, pr*(1-e2*n/(n+h)))
+		if math.Abs(next-phi) < 1e-13 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	return point.New(phi*180/math.Pi, math.Atan2(y, x)*180/math.Pi)
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
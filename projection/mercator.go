@@ -0,0 +1,35 @@
+package projection
+
+import (
+	"github.com/brechtvm/osm/point"
+	"math"
+)
+
+// the latitude limit of Web Mercator, the map is a square
+const MaxMercatorLat = 85.05112877980659
+
+type webMercator struct{}
+
+// Web Mercator (EPSG:3857) as used by most web maps: the spherical
+// Mercator projection of WGS84 positions. Latitudes are clipped to
+// ±MaxMercatorLat.
+var WebMercator Projection = webMercator{}
+
+func (webMercator) EPSG() int { return 3857 }
+
+func (webMercator) Forward(p *point.Point) Coord {
+	lat := math.Max(-MaxMercatorLat, math.Min(MaxMercatorLat, p.Lat))
+	return Coord{
+		X: wgs84.a * p.Lon * math.Pi / 180,
+		Y: wgs84.a * math.Log(math.Tan(math.Pi/4+lat*math.Pi/360)),
+	}
+}
+
+func (webMercator) Inverse(c Coord) *point.Point {
+	return point.New(
+		(2*math.Atan(math.Exp(c.Y/wgs84.a))-math.Pi/2)*180/math.Pi,
+		c.X/wgs84.a*180/math.Pi,
+	)
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,121 @@
+// Package projection converts positions between WGS84 lat/lon and
+// projected coordinate reference systems and computes areas, centroids
+// and containment in them.
+package projection
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+	"math"
+)
+
+// a projected position, in metres for all projections of this package
+type Coord struct {
+	X, Y float64 // easting, northing
+}
+
+func (c Coord) String() string {
+	return fmt.Sprintf("%.3f %.3f", c.X, c.Y)
+}
+
+// a projected coordinate reference system
+type Projection interface {
+	// projects a WGS84 position
+	Forward(p *point.Point) Coord
+	// returns the WGS84 position of a projected coordinate
+	Inverse(c Coord) *point.Point
+	// the EPSG code of the CRS
+	EPSG() int
+}
+
+type ellipsoid struct {
+	a, f float64
+}
+
+func (e ellipsoid) e2() float64 { return e.f * (2 - e.f) }
+func (e ellipsoid) e() float64  { return math.Sqrt(e.e2()) }
+
+var (
+	wgs84         = ellipsoid{a: float64(point.WGS84SemiMajorAxis), f: point.WGS84Flattening}
+	grs80         = ellipsoid{a: 6378137, f: 1 / 298.257222101}
+	international = ellipsoid{a: 6378388, f: 1 / 297.0}
+)
+
+// returns the projection for an EPSG code: 3857 (Web Mercator), 326xx and
+// 327xx (UTM north and south), 31370 (Belgian Lambert 72) and 3812
+// (Belgian Lambert 2008)
+func ByEPSG(code int) (Projection, error) {
+	switch {
+	case code == 3857:
+		return WebMercator, nil
+	case code == 31370:
+		return Lambert72, nil
+	case code == 3812:
+		return Lambert2008, nil
+	case code > 32600 && code <= 32660:
+		return UTM(code-32600, true), nil
+	case code > 32700 && code <= 32760:
+		return UTM(code-32700, false), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unsupported projection EPSG:%d", code))
+}
+
+// returns a copy of the multipolygon with projected coordinates, X as
+// Lon and Y as Lat. The planar functions of the polygon package (Area(),
+// Contains(), ...) return their results in the units of the projection
+// for such a multipolygon.
+func Project(p Projection, mp polygon.MultiPolygon) polygon.MultiPolygon {
+	ring := func(r polygon.Ring) polygon.Ring {
+		pr := make(polygon.Ring, len(r))
+		for i, q := range r {
+			c := p.Forward(q)
+			pr[i] = point.New(c.Y, c.X)
+		}
+		return pr
+	}
+	var pm polygon.MultiPolygon
+	for _, pg := range mp {
+		np := &polygon.Polygon{Outer: ring(pg.Outer)}
+		for _, in := range pg.Inners {
+			np.Inners = append(np.Inners, ring(in))
+		}
+		pm = append(pm, np)
+	}
+	return pm
+}
+
+// the area of the multipolygon in square metres, computed in the
+// projection
+func Area(p Projection, mp polygon.MultiPolygon) float64 {
+	return Project(p, mp).Area()
+}
+
+// the centroid of the multipolygon computed in the projection, nil for an
+// empty multipolygon
+func Centroid(p Projection, mp polygon.MultiPolygon) *point.Point {
+	var x, y, area float64
+	for _, pg := range Project(p, mp) {
+		c := pg.Centroid()
+		if c == nil {
+			continue
+		}
+		a := pg.Area()
+		x += c.Lon * a
+		y += c.Lat * a
+		area += a
+	}
+	if area == 0 {
+		return nil
+	}
+	return p.Inverse(Coord{X: x / area, Y: y / area})
+}
+
+// checks if q is inside the multipolygon, computed in the projection
+func Contains(p Projection, mp polygon.MultiPolygon, q *point.Point) bool {
+	c := p.Forward(q)
+	return Project(p, mp).Contains(point.New(c.Y, c.X))
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
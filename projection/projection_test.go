@@ -0,0 +1,153 @@
+package projection
+
+import (
+	"fmt"
+	"github.com/brechtvm/osm/point"
+	"math"
+	"testing"
+)
+
+// degrees, minutes and seconds
+func dms(d, m, s float64) float64 {
+	return d + m/60 + s/3600
+}
+
+func checkCoord(t *testing.T, name string, got, want Coord, tolerance float64) {
+	t.Helper()
+	if math.Abs(got.X-want.X) > tolerance || math.Abs(got.Y-want.Y) > tolerance {
+		t.Errorf("%s: %v, want %v (±%gm)", name, got, want, tolerance)
+	}
+}
+
+// Projects p and back, the position must not move by more than a
+// millimetre.
+func checkRoundTrip(t *testing.T, name string, pr Projection, p *point.Point) {
+	t.Helper()
+	q := pr.Inverse(pr.Forward(p))
+	if d := p.DistanceOf(q); d > 0.001 {
+		t.Errorf("%s: %v projected and back is %v, %.6fm away", name, p, q, d)
+	}
+}
+
+// The examples of the EPSG Guidance Note 7-2 (Coordinate Conversions and
+// Transformations including Formulas), given to a centimetre.
+func TestEPSGExamples(t *testing.T) {
+	// Lambert Conic Conformal (2SP), NAD27 / Texas South Central, in US
+	// survey feet
+	const usFoot = 1200.0 / 3937
+	clarke1866 := ellipsoid{a: 6378206.400, f: 1 / 294.9786982}
+	texas := newLambert(32040, clarke1866, dms(28, 23, 0), dms(30, 17, 0), dms(27, 50, 0), -99, 2000000*usFoot, 0, nil)
+	c := texas.Forward(point.New(28.5, -96))
+	checkCoord(t, "Texas South Central", Coord{X: c.X / usFoot, Y: c.Y / usFoot}, Coord{X: 2963503.91, Y: 254759.80}, 0.01)
+	checkRoundTrip(t, "Texas South Central", texas, point.New(28.5, -96))
+
+	// Lambert Conic Conformal (2SP Belgium), Belge 1972 / Belge Lambert
+	// 72. The parameters of EPSG:31370 reproduce this method with the
+	// standard 2SP method to about 5cm, the example position is on the
+	// Belge 1972 datum, i.e. without the datum shift.
+	bd72 := *Lambert72.(*lambert)
+	bd72.toWGS84 = nil
+	p := point.New(dms(50, 40, 46.461), dms(5, 48, 26.533))
+	checkCoord(t, "Belge Lambert 72", bd72.Forward(p), Coord{X: 251763.20, Y: 153034.13}, 0.06)
+	checkRoundTrip(t, "Belge Lambert 72", &bd72, p)
+
+	// the position vector transformation from WGS 72 to WGS 84, in
+	// geocentric coordinates
+	wgs72 := helmert{tz: 4.5, rz: 0.554, s: 0.219}
+	x, y, z := wgs72.apply(1, 3657660.66, 255768.55, 5201382.11)
+	if math.Abs(x-3657660.78) > 0.01 || math.Abs(y-255778.43) > 0.01 || math.Abs(z-5201387.75) > 0.01 {
+		t.Errorf("WGS 72 to WGS 84: %.3f %.3f %.3f, want 3657660.78 255778.43 5201387.75", x, y, z)
+	}
+	x, y, z = wgs72.apply(-1, x, y, z)
+	if math.Abs(x-3657660.66) > 0.01 || math.Abs(y-255768.55) > 0.01 || math.Abs(z-5201382.11) > 0.01 {
+		t.Errorf("WGS 84 to WGS 72: %.3f %.3f %.3f, want 3657660.66 255768.55 5201382.11", x, y, z)
+	}
+}
+
+// The natural origin of Lambert 2008 is mapped to the false easting and
+// northing of EPSG:3812.
+func TestLambert2008(t *testing.T) {
+	origin := point.New(dms(50, 47, 52.134), dms(4, 21, 33.177))
+	checkCoord(t, "Lambert 2008 origin", Lambert2008.Forward(origin), Coord{X: 649328, Y: 665262}, 0.001)
+	for _, p := range belgium {
+		checkRoundTrip(t, "Lambert 2008", Lambert2008, p)
+	}
+}
+
+// positions in and around Belgium
+var belgium = []*point.Point{
+	point.New(50.8466, 4.3528),
+	point.New(51.3, 2.6),
+	point.New(49.5, 6.4),
+	point.New(50.6781, 5.8073),
+}
+
+// Lambert 72 on WGS84 positions: the position is shifted to the Belge
+// 1972 datum (EPSG:15929, which moves positions in Belgium by about 100m)
+// and projected.
+func TestLambert72(t *testing.T) {
+	bd72 := *Lambert72.(*lambert)
+	bd72.toWGS84 = nil
+	for _, p := range belgium {
+		checkRoundTrip(t, "Lambert 72", Lambert72, p)
+		q := Lambert72.(*lambert).toWGS84.inverse(wgs84, international, p)
+		if d := p.DistanceOf(q); d < 90 || d > 130 {
+			t.Errorf("%v is shifted by %v to the Belge 1972 datum, want about 100m", p, d)
+		}
+		checkCoord(t, "Lambert 72", Lambert72.Forward(p), bd72.Forward(q), 0.001)
+	}
+
+	// the Belgian example of TestEPSGExamples, 50°40'46.461"N
+	// 5°48'26.533"E on Belge 1972, is at 50.679014286N 5.808673885E on
+	// WGS84 with the +towgs84 parameters published for EPSG:31370
+	// (computed independently of this package). A wrong sign of the
+	// rotations moves it by tens of metres, the inverse shift by about 200m.
+	p := point.New(50.679014286, 5.808673885)
+	want := Coord{X: 251763.20, Y: 153034.13}
+	checkCoord(t, "Lambert 72 of a WGS84 position", Lambert72.Forward(p), want, 0.1)
+	if d := p.DistanceOf(Lambert72.Inverse(want)); d > 0.1 {
+		t.Errorf("Lambert 72 %v is %v from %v on WGS84", want, d, p)
+	}
+}
+
+// Points on the equator and on the central meridian, whose UTM
+// coordinates follow from the WGS84 meridian arc (4984944.378m to 45°)
+// and the equatorial scale of transverse Mercator.
+func TestUTM(t *testing.T) {
+	for _, c := range []struct {
+		p     *point.Point
+		zone  int
+		north bool
+		want  Coord
+	}{
+		{point.New(0, 3), 31, true, Coord{X: 500000, Y: 0}},
+		{point.New(0, 0), 31, true, Coord{X: 166021.443, Y: 0}},
+		{point.New(0, 6), 31, true, Coord{X: 833978.557, Y: 0}},
+		{point.New(45, 9), 32, true, Coord{X: 500000, Y: 4982950.400}},
+		{point.New(-45, 9), 32, false, Coord{X: 500000, Y: 5017049.600}},
+	} {
+		pr := UTM(c.zone, c.north)
+		name := fmt.Sprintf("EPSG:%d %v", pr.EPSG(), c.p)
+		checkCoord(t, name, pr.Forward(c.p), c.want, 0.001)
+		checkRoundTrip(t, name, pr, c.p)
+	}
+	if zone, north := UTMZone(point.New(-45, 9)); zone != 32 || north {
+		t.Errorf("zone of -45,9 is %d %v, want 32 south", zone, north)
+	}
+	// round trips up to the edges of a zone
+	for _, lat := range []float64{-80, -45, -1, 0, 1, 45, 84} {
+		for _, dl := range []float64{-3, -1.5, 0, 1.5, 3} {
+			p := point.New(lat, 51+dl)
+			pr := UTM(39, lat >= 0)
+			checkRoundTrip(t, "UTM 39", pr, p)
+		}
+	}
+}
+
+func TestWebMercator(t *testing.T) {
+	checkCoord(t, "Web Mercator", WebMercator.Forward(point.New(0, 180)), Coord{X: 20037508.343, Y: 0}, 0.001)
+	checkCoord(t, "Web Mercator", WebMercator.Forward(point.New(MaxMercatorLat, 0)), Coord{X: 0, Y: 20037508.343}, 0.001)
+	checkRoundTrip(t, "Web Mercator", WebMercator, point.New(50.8466, 4.3528))
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,111 @@
+package projection
+
+import (
+	"github.com/brechtvm/osm/point"
+	"math"
+)
+
+// transverse Mercator on the WGS84 ellipsoid using the Krüger series (to
+// the third order of n, accurate to well below a millimetre inside a UTM
+// zone)
+type utm struct {
+	zone  int
+	north bool
+}
+
+const (
+	utmScale    = 0.9996
+	utmEasting  = 500000.0
+	utmNorthing = 10000000.0 // false northing of the southern hemisphere
+)
+
+// returns the UTM projection for a zone (1 to 60) and hemisphere
+func UTM(zone int, north bool) Projection {
+	return utm{zone: zone, north: north}
+}
+
+// returns the UTM zone and hemisphere of p, the exceptions for Norway and
+// Svalbard are not applied
+func UTMZone(p *point.Point) (zone int, north bool) {
+	zone = int(math.Floor((p.Lon+180)/6)) + 1
+	if zone > 60 {
+		zone = 60
+	}
+	return zone, p.Lat >= 0
+}
+
+func (u utm) EPSG() int {
+	if u.north {
+		return 32600 + u.zone
+	}
+	return 32700 + u.zone
+}
+
+func (u utm) lon0() float64 {
+	return (float64(u.zone)*6 - 183) * math.Pi / 180
+}
+
+var (
+	utmN = wgs84.f / (2 - wgs84.f)
+	// rectifying radius
+	utmA     = wgs84.a / (1 + utmN) * (1 + utmN*utmN/4 + utmN*utmN*utmN*utmN/64)
+	utmAlpha = [3]float64{
+		utmN/2 - 2*utmN*utmN/3 + 5*utmN*utmN*utmN/16,
+		13*utmN*utmN/48 - 3*utmN*utmN*utmN/5,
+		61 * utmN * utmN * utmN / 240,
+	}
+	utmBeta = [3]float64{
+		utmN/2 - 2*utmN*utmN/3 + 37*utmN*utmN*utmN/96,
+		utmN*utmN/48 + utmN*utmN*utmN/15,
+		17 * utmN * utmN * utmN / 480,
+	}
+	utmDelta = [3]float64{
+		2*utmN - 2*utmN*utmN/3 - 2*utmN*utmN*utmN,
+		7*utmN*utmN/3 - 8*utmN*utmN*utmN/5,
+		56 * utmN * utmN * utmN / 15,
+	}
+)
+
+func (u utm) Forward(p *point.Point) Coord {
+	phi := p.Lat * math.Pi / 180
+	dl := p.Lon*math.Pi/180 - u.lon0()
+	k := 2 * math.Sqrt(utmN) / (1 + utmN)
+	t := math.Sinh(math.Atanh(math.Sin(phi)) - k*math.Atanh(k*math.Sin(phi)))
+	xi := math.Atan2(t, math.Cos(dl))
+	eta := math.Atanh(math.Sin(dl) / math.Sqrt(1+t*t))
+	x, y := eta, xi
+	for j := 1; j <= 3; j++ {
+		jf := float64(2 * j)
+		x += utmAlpha[j-1] * math.Cos(jf*xi) * math.Sinh(jf*eta)
+		y += utmAlpha[j-1] * math.Sin(jf*xi) * math.Cosh(jf*eta)
+	}
+	c := Coord{X: utmEasting + utmScale*utmA*x, Y: utmScale * utmA * y}
+	if !u.north {
+		c.Y += utmNorthing
+	}
+	return c
+}
+
+func (u utm) Inverse(c Coord) *point.Point {
+	y := c.Y
+	if !u.north {
+		y -= utmNorthing
+	}
+	xi := y / (utmScale * utmA)
+	eta := (c.X - utmEasting) / (utmScale * utmA)
+	xi1, eta1 := xi, eta
+	for j := 1; j <= 3; j++ {
+		jf := float64(2 * j)
+		xi1 -= utmBeta[j-1] * math.Sin(jf*xi) * math.Cosh(jf*eta)
+		eta1 -= utmBeta[j-1] * math.Cos(jf*xi) * math.Sinh(jf*eta)
+	}
+	chi := math.Asin(math.Sin(xi1) / math.Cosh(eta1))
+	phi := chi
+	for j := 1; j <= 3; j++ {
+		phi += utmDelta[j-1] * math.Sin(float64(2*j)*chi)
+	}
+	lon := u.lon0() + math.Atan2(math.Sinh(eta1), math.Cos(xi1))
+	return point.New(phi*180/math.Pi, lon*180/math.Pi)
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
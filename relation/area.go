@@ -0,0 +1,26 @@
+package relation
+
+import (
+	"github.com/brechtvm/osm/distance"
+)
+
+// the geodesic area of the area relation in square meters, holes are
+// subtracted. See MultiPolygon() for the ring assembly.
+func (r *Relation) GeodesicArea() (distance.Area, error) {
+	mp, err := r.MultiPolygon()
+	if err != nil {
+		return -1.0, err
+	}
+	return mp.GeodesicArea(), nil
+}
+
+// the length of all outer and inner rings of the area relation
+func (r *Relation) Perimeter() (distance.Distance, error) {
+	mp, err := r.MultiPolygon()
+	if err != nil {
+		return -1.0, err
+	}
+	return mp.Perimeter(), nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
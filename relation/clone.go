@@ -0,0 +1,60 @@
+package relation
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/user"
+	"github.com/brechtvm/osm/way"
+)
+
+// returns a deep copy of the relation with the same id, all members are
+// copied recursively
+func (r *Relation) DeepCopy() *Relation {
+	return r.DeepCopyWith(make(map[int64]*node.Node), make(map[int64]*way.Way), make(map[int64]*Relation))
+}
+
+// like DeepCopy(), but members already in the maps are used instead of
+// being copied, new copies are added to the maps. Members which are
+// missing (i.e. have no Ref) stay missing.
+func (r *Relation) DeepCopyWith(nodes map[int64]*node.Node, ways map[int64]*way.Way, relations map[int64]*Relation) *Relation {
+	if c, ok := relations[r.Id_]; ok {
+		return c
+	}
+	c := *r
+	relations[r.Id_] = &c
+	if r.User_ != nil {
+		c.User_ = user.New(r.User_.Id, r.User_.Name)
+	}
+	c.Tags_ = r.Tags_.Clone()
+	c.Members_ = make([]*Member, len(r.Members_))
+	for i, m := range r.Members_ {
+		mc := *m
+		switch ref := m.Ref.(type) {
+		case *node.Node:
+			if ref != nil {
+				nc, ok := nodes[ref.Id_]
+				if !ok {
+					nc = ref.DeepCopy()
+					nodes[ref.Id_] = nc
+				}
+				mc.Ref = nc
+			}
+		case *way.Way:
+			if ref != nil {
+				wc, ok := ways[ref.Id_]
+				if !ok {
+					wc = ref.DeepCopyWith(nodes)
+					ways[ref.Id_] = wc
+				}
+				mc.Ref = wc
+			}
+		case *Relation:
+			if ref != nil {
+				mc.Ref = ref.DeepCopyWith(nodes, ways, relations)
+			}
+		}
+		c.Members_[i] = &mc
+	}
+	return &c
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,52 @@
+package relation
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/way"
+	"testing"
+)
+
+// relation 1 has a square, node 9 and relation 2 as members, relation 2
+// has the square, a missing way and relation 1 as members
+func TestDeepCopy(t *testing.T) {
+	tn := testNodes{}
+	sq := tn.square(10, 1, 0, 0, 1)
+	tn.at(9, 2, 2)
+	r1 := multipolygon(sq)
+	r2 := &Relation{Id_: 2, Tags_: r1.Tags_.Clone()}
+	r1.Members_ = append(r1.Members_,
+		&Member{Type_: item.TypeNode, Id_: 9, Role: "label", Ref: tn[9]},
+		&Member{Type_: item.TypeRelation, Id_: 2, Ref: r2})
+	r2.Members_ = []*Member{
+		{Type_: item.TypeWay, Id_: 10, Role: "outer", Ref: sq},
+		{Type_: item.TypeWay, Id_: 11},
+		{Type_: item.TypeRelation, Id_: 1, Ref: r1},
+	}
+
+	c := r1.DeepCopy()
+	wc, _ := c.Members_[0].Ref.(*way.Way)
+	c2, _ := c.Members_[2].Ref.(*Relation)
+	if c == r1 || wc == nil || wc == sq || c2 == nil || c2 == r2 {
+		t.Fatalf("members of the copy %v are not copies", c.Members_)
+	}
+	if c2.Members_[0].Ref != wc || c2.Members_[2].Ref != c {
+		t.Error("copies of the members are not shared")
+	}
+	if c2.Members_[1].Ref != nil || c2.Members_[1].Id_ != 11 {
+		t.Errorf("missing member of the copy is %v", c2.Members_[1])
+	}
+	if wc.Nodes_[0] != wc.Nodes_[4] || wc.Nodes_[0] == sq.Nodes_[0] {
+		t.Error("nodes of the copied way are not copies")
+	}
+
+	c.Members_[1].Role = "admin_centre"
+	c.Members_[1].Ref.(*node.Node).Position_.Lat = 0
+	c2.Tags_.Add("type", "boundary")
+	wc.Nodes_[1].Position_.Lon = 5
+	if r1.Members_[1].Role != "label" || tn[9].Position_.Lat != 2 || r2.Tags_.Get("type") != "multipolygon" || tn[2].Position_.Lon != 1 {
+		t.Error("relations changed through the copy")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,43 @@
+package relation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/point"
+)
+
+// returns a point inside the area relation (in its largest part), see
+// polygon.PointOnSurface()
+func (r *Relation) PointOnSurface() (*point.Point, error) {
+	if !r.IsAreaRelation() {
+		return nil, errors.New("Not an area relation")
+	}
+	mp, err := r.MultiPolygon()
+	if err != nil {
+		return nil, err
+	}
+	if p := mp.PointOnSurface(); p != nil {
+		return p, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Relation #%d has no area", r.Id_))
+}
+
+// returns the pole of inaccessibility of the area relation and its
+// distance from the boundary, see polygon.PoleOfInaccessibility()
+func (r *Relation) PoleOfInaccessibility(tolerance distance.Distance) (*point.Point, distance.Distance, error) {
+	if !r.IsAreaRelation() {
+		return nil, 0, errors.New("Not an area relation")
+	}
+	mp, err := r.MultiPolygon()
+	if err != nil {
+		return nil, 0, err
+	}
+	p, d := mp.PoleOfInaccessibility(tolerance)
+	if p == nil {
+		return nil, 0, errors.New(fmt.Sprintf("Relation #%d has no area", r.Id_))
+	}
+	return p, d, nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,40 @@
+package relation
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/tags"
+	"math"
+	"testing"
+)
+
+// A 6° square on the equator with an off-center 3° square hole and a
+// small island. The largest circle touches the outer ring at t° from the
+// lower left corner and the lower left corner of the hole at √2(2 - t)°,
+// t = 4 - 2√2.
+func TestRelationPoleOfInaccessibility(t *testing.T) {
+	tn := testNodes{}
+	r := multipolygon(tn.square(10, 1, -3, -3, 6), tn.square(11, 5, -1, -1, 3), tn.square(12, 9, 10, 10, 1))
+	tolerance := 100 * distance.Meter
+	t0 := 4 - 2*math.Sqrt2
+	p, d, err := r.PoleOfInaccessibility(tolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := distance.Distance(t0 * float64(distance.EarthRadius) * math.Pi / 180); d > want || d < want-tolerance {
+		t.Errorf("pole %v from the boundary, want %v", d, want)
+	}
+	if math.Abs(p.Lat-(t0-3)) > 0.01 || math.Abs(p.Lon-(t0-3)) > 0.01 {
+		t.Errorf("pole at %v, want %v,%v", p, t0-3, t0-3)
+	}
+	mp, _ := r.MultiPolygon()
+	if p, err = r.PointOnSurface(); err != nil || !mp.Contains(p) {
+		t.Errorf("point on surface %v %v not inside", p, err)
+	}
+
+	r.Tags_ = &tags.Tags{"type": "route"}
+	if _, _, err = r.PoleOfInaccessibility(tolerance); err == nil {
+		t.Error("no error for a route")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,185 @@
+package relation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/polygon"
+	"github.com/brechtvm/osm/way"
+	"sort"
+)
+
+// Assembles the way members of an area relation (type=multipolygon or
+// type=boundary) to a polygon.MultiPolygon. The members may be in any
+// order and any direction, rings may consist of any number of ways and
+// may touch each other in single nodes.
+//
+// The roles are not used: rings are classified as outer or inner by
+// containment, i.e. a ring inside an odd number of other rings is an
+// inner ring of the smallest ring containing it, all others are outer
+// rings. So islands in lakes inside an outer ring become outer rings of
+// their own.
+//
+// An error is returned if a way member is missing, has too few nodes or
+// if the ways cannot be joined to closed rings.
+func (r *Relation) MultiPolygon() (polygon.MultiPolygon, error) {
+	if !r.IsAreaRelation() {
+		return nil, errors.New(fmt.Sprintf("Relation #%d is not an area relation", r.Id_))
+	}
+	var open [][]*node.Node
+	var rings []polygon.Ring
+	for _, m := range r.Members_ {
+		if m.Type_ != item.TypeWay {
+			continue
+		}
+		w, ok := m.Ref.(*way.Way)
+		if !ok || w == nil {
+			return nil, errors.New(fmt.Sprintf("Relation #%d is incomplete: way #%d is missing", r.Id_, m.Id_))
+		}
+		if len(w.Nodes_) < 2 {
+			return nil, errors.New(fmt.Sprintf("Relation #%d: way #%d has too few nodes", r.Id_, w.Id_))
+		}
+		if w.Closed() {
+			ring, err := w.Ring()
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("Relation #%d: %s", r.Id_, err))
+			}
+			rings = append(rings, ring)
+			continue
+		}
+		open = append(open, w.Nodes_)
+	}
+
+	joined, err := joinRings(open)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Relation #%d: %s", r.Id_, err))
+	}
+	rings = append(rings, joined...)
+	if len(rings) == 0 {
+		return nil, errors.New(fmt.Sprintf("Relation #%d has no way members", r.Id_))
+	}
+	return classifyRings(rings), nil
+}
+
+// joins the node lists to closed rings using the ids of the end nodes
+func joinRings(parts [][]*node.Node) ([]polygon.Ring, error) {
+	ends := make(map[int64][]int)
+	for i, p := range parts {
+		ends[p[0].Id_] = append(ends[p[0].Id_], i)
+		ends[p[len(p)-1].Id_] = append(ends[p[len(p)-1].Id_], i)
+	}
+	used := make([]bool, len(parts))
+
+	var rings []polygon.Ring
+	for i := range parts {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		cur := append([]*node.Node(nil), parts[i]...)
+		// the positions of the joints in cur, a ring is split off as soon
+		// as the chain returns to one of them, so rings touching in a node
+		// do not become one self-touching ring
+		joints := map[int64]int{cur[0].Id_: 0, cur[len(cur)-1].Id_: len(cur) - 1}
+		for len(cur) > 1 {
+			last := cur[len(cur)-1].Id_
+			next := -1
+			for _, j := range ends[last] {
+				if !used[j] {
+					next = j
+					break
+				}
+			}
+			if next == -1 {
+				return nil, errors.New(fmt.Sprintf("ring is not closed, open end at node #%d", last))
+			}
+			used[next] = true
+			p := parts[next]
+			if p[0].Id_ == last {
+				cur = append(cur, p[1:]...)
+			} else {
+				for k := len(p) - 2; k >= 0; k-- {
+					cur = append(cur, p[k])
+				}
+			}
+			end := cur[len(cur)-1].Id_
+			k, ok := joints[end]
+			if !ok {
+				joints[end] = len(cur) - 1
+				continue
+			}
+			ring, err := nodeRing(cur[k:])
+			if err != nil {
+				return nil, err
+			}
+			rings = append(rings, ring)
+			for _, n := range cur[k+1 : len(cur)-1] {
+				delete(joints, n.Id_)
+			}
+			cur = cur[:k+1]
+		}
+	}
+	return rings, nil
+}
+
+// the ring of a closed node list
+func nodeRing(nl []*node.Node) (polygon.Ring, error) {
+	if len(nl) < 4 {
+		return nil, errors.New(fmt.Sprintf("ring at node #%d has too few nodes", nl[0].Id_))
+	}
+	ring := make(polygon.Ring, len(nl))
+	for k, n := range nl {
+		ring[k] = n.Position_
+	}
+	return ring, nil
+}
+
+// sorts the rings into outer rings with their holes
+func classifyRings(rings []polygon.Ring) polygon.MultiPolygon {
+	area := make([]float64, len(rings))
+	order := make([]int, len(rings))
+	for i, r := range rings {
+		area[i] = r.Area()
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return area[order[a]] > area[order[b]] })
+
+	depth := make([]int, len(rings))
+	parent := make([]int, len(rings))
+	for k, i := range order {
+		parent[i] = -1
+		// the containing ring with the smallest area is the direct parent
+		for l := k - 1; l >= 0; l-- {
+			j := order[l]
+			if rings[j].ContainsRing(rings[i]) {
+				parent[i] = j
+				depth[i] = depth[j] + 1
+				break
+			}
+		}
+	}
+
+	var mp polygon.MultiPolygon
+	outer := make(map[int]*polygon.Polygon)
+	for _, i := range order {
+		if depth[i]%2 == 0 {
+			p := polygon.New(rings[i])
+			outer[i] = p
+			mp = append(mp, p)
+		}
+	}
+	for _, i := range order {
+		if depth[i]%2 == 1 {
+			p := outer[parent[i]]
+			in := rings[i]
+			if !in.IsClockwise() {
+				in = in.Reverse()
+			}
+			p.Inners = append(p.Inners, in)
+		}
+	}
+	return mp
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
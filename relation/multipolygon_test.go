@@ -0,0 +1,138 @@
+package relation
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"math"
+	"testing"
+)
+
+// the nodes of a test relation by id
+type testNodes map[int64]*node.Node
+
+func (tn testNodes) at(id int64, lat, lon float64) {
+	tn[id] = &node.Node{Id_: id, Position_: point.New(lat, lon)}
+}
+
+func (tn testNodes) way(id int64, ids ...int64) *way.Way {
+	w := &way.Way{Id_: id, Tags_: tags.New()}
+	for _, i := range ids {
+		w.Nodes_ = append(w.Nodes_, tn[i])
+	}
+	return w
+}
+
+// a square with the lower left corner lat, lon and the given size, the
+// node ids are first..first+3
+func (tn testNodes) square(id, first int64, lat, lon, size float64) *way.Way {
+	tn.at(first, lat, lon)
+	tn.at(first+1, lat, lon+size)
+	tn.at(first+2, lat+size, lon+size)
+	tn.at(first+3, lat+size, lon)
+	return tn.way(id, first, first+1, first+2, first+3, first)
+}
+
+func multipolygon(ways ...*way.Way) *Relation {
+	r := &Relation{Id_: 1, Tags_: &tags.Tags{"type": "multipolygon"}}
+	for _, w := range ways {
+		r.Members_ = append(r.Members_, &Member{Type_: item.TypeWay, Id_: w.Id_, Ref: w})
+	}
+	return r
+}
+
+// two squares touching in node 5, assembled from four open ways: the
+// chain 1-2-5-6-7-8-5 returns to the joint 5 and the right square is
+// split off before the left one is closed
+func TestJoinRingsAtJoint(t *testing.T) {
+	tn := testNodes{}
+	tn.at(1, 0, 0)
+	tn.at(2, 1, 0)
+	tn.at(5, 1, 1)
+	tn.at(3, 0, 1)
+	tn.at(6, 2, 1)
+	tn.at(7, 2, 2)
+	tn.at(8, 1, 2)
+	mp, err := multipolygon(tn.way(10, 1, 2, 5), tn.way(11, 5, 6, 7), tn.way(12, 7, 8, 5), tn.way(13, 5, 3, 1)).MultiPolygon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mp) != 2 {
+		t.Fatalf("%d polygons, want 2", len(mp))
+	}
+	for _, p := range mp {
+		if len(p.Outer) != 5 || p.Outer.Area() != 1 || len(p.Inners) != 0 {
+			t.Errorf("ring of %d points and %g square degrees, want a unit square", len(p.Outer), p.Outer.Area())
+		}
+	}
+}
+
+func TestJoinRingsOpen(t *testing.T) {
+	tn := testNodes{}
+	tn.at(1, 0, 0)
+	tn.at(2, 1, 0)
+	tn.at(3, 1, 1)
+	if _, err := multipolygon(tn.way(10, 1, 2), tn.way(11, 2, 3)).MultiPolygon(); err == nil {
+		t.Error("no error for an open ring")
+	}
+	r := multipolygon(tn.way(10, 1, 2, 3, 1))
+	r.Members_ = append(r.Members_, &Member{Type_: item.TypeWay, Id_: 11})
+	if _, err := r.MultiPolygon(); err == nil {
+		t.Error("no error for a missing way")
+	}
+}
+
+// an island with a lake in a lake: the rings at the depths 0 and 2 are
+// outer rings, the ones at the depths 1 and 3 their holes
+func TestClassifyRings(t *testing.T) {
+	tn := testNodes{}
+	mp, err := multipolygon(
+		tn.square(13, 50, 4.5, 4.5, 1),
+		tn.square(10, 10, 0, 0, 10),
+		tn.square(12, 40, 4, 4, 2),
+		tn.square(11, 30, 2, 2, 6),
+	).MultiPolygon()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mp) != 2 {
+		t.Fatalf("%d polygons, want 2", len(mp))
+	}
+	for i, c := range []struct{ outer, inner float64 }{{100, 36}, {4, 1}} {
+		p := mp[i]
+		if p.Outer.Area() != c.outer || len(p.Inners) != 1 || p.Inners[0].Area() != c.inner {
+			t.Errorf("polygon %d: outer %g, %d holes, want %g with a hole of %g", i, p.Outer.Area(), len(p.Inners), c.outer, c.inner)
+			continue
+		}
+		if !p.Inners[0].IsClockwise() {
+			t.Errorf("polygon %d: hole is counter clockwise", i)
+		}
+	}
+}
+
+// the centroid of a 4x4 square with a 1x1 hole at 1..2 is
+// (2*16 - 1.5*1) / 15 = 2.0333 in both directions
+func TestCentroid(t *testing.T) {
+	tn := testNodes{}
+	c, err := multipolygon(tn.square(10, 10, 0, 0, 4), tn.square(11, 20, 1, 1, 1)).Centroid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := 30.5 / 15
+	if math.Abs(c.Lat-want) > 1e-9 || math.Abs(c.Lon-want) > 1e-9 {
+		t.Errorf("centroid %v, want %.4f,%.4f", c, want, want)
+	}
+
+	// two squares mirrored at the equator have the same geodesic area
+	c, err = multipolygon(tn.square(10, 10, 1, 10, 1), tn.square(11, 20, -2, 10, 1)).Centroid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(c.Lat) > 1e-9 || math.Abs(c.Lon-10.5) > 1e-9 {
+		t.Errorf("centroid %v, want 0,10.5", c)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -64,6 +64,17 @@ func NewRelation(m *Member) *Relation {
 	}
 }
 
+func (r *Relation) SetTags(t *tags.Tags) {
+	r.modified = true
+	r.Tags_ = t
+}
+
+// marks the relation as deleted
+func (r *Relation) Delete() {
+	r.deleted = true
+	r.modified = true
+}
+
 func (m *Member) Type() item.ItemType {
 	return m.Type_
 }
@@ -150,7 +161,7 @@ func (r *Relation) WayMembers() []*Member {
 }
 
 func (r *Relation) IsMultipolygon() bool {
-	if r.Tags == nil {
+	if r.Tags_ == nil {
 		return false
 	}
 	t := map[string]string(*r.Tags_)
@@ -158,7 +169,7 @@ func (r *Relation) IsMultipolygon() bool {
 }
 
 func (r *Relation) IsAreaRelation() bool {
-	if r.Tags == nil {
+	if r.Tags_ == nil {
 		return false
 	}
 	switch r.Tags_.Get("type") {
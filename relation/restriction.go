@@ -0,0 +1,278 @@
+package relation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/way"
+	"sort"
+	"strings"
+)
+
+// prohibitory (no_*) or mandatory (only_*) turn restriction
+type RestrictionKind int
+
+const (
+	NoTurn RestrictionKind = iota
+	OnlyTurn
+)
+
+func (k RestrictionKind) String() string {
+	if k == OnlyTurn {
+		return "only"
+	}
+	return "no"
+}
+
+// a validated type=restriction relation
+type Restriction struct {
+	Relation *Relation
+	Kind     RestrictionKind
+	// the restriction value, e.g. "no_left_turn"
+	Value string
+	// the vehicle of type=restriction:<vehicle> or else the vehicles of
+	// restriction:<vehicle> tags without a restriction tag, empty if the
+	// restriction applies to all vehicles
+	Vehicles []string
+	// the vehicles of the except tag
+	Except []string
+	// the condition of restriction:conditional (e.g. "Mo-Fr 07:00-09:00"),
+	// Conditional is true if the restriction only applies then, for a
+	// restriction:<vehicle>:conditional Vehicles are set too
+	Condition   string
+	Conditional bool
+	From        *way.Way
+	ViaNode     *node.Node // either ViaNode or ViaWays is set
+	ViaWays     []*way.Way
+	To          *way.Way
+}
+
+// checks if the relation is a turn restriction (type=restriction or
+// type=restriction:<vehicle>)
+func (r *Relation) IsRestriction() bool {
+	if r.Tags_ == nil {
+		return false
+	}
+	t := r.Tags_.Get("type")
+	return t == "restriction" || strings.HasPrefix(t, "restriction:")
+}
+
+// Returns the restriction of a type=restriction relation. An error is
+// returned if the tags or the members are invalid: there must be exactly
+// one from and one to way and either one via node or a chain of via ways,
+// the from and to ways must start or end at the via node (or the ends of
+// the via chain). The members must be resolved (Ref set).
+func (r *Relation) Restriction() (*Restriction, error) {
+	if !r.IsRestriction() {
+		return nil, errors.New(fmt.Sprintf("Relation #%d is not a restriction", r.Id_))
+	}
+	rs := &Restriction{Relation: r}
+	keys := make([]string, 0, len(*r.Tags_))
+	for k := range *r.Tags_ {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	// restriction:conditional applies to all vehicles, else the first
+	// restriction:<vehicle>:conditional is used for all vehicles with the
+	// same condition
+	general := r.Tags_.Has("restriction:conditional")
+	condition := r.Tags_.Get("restriction:conditional")
+	var condVehicles []string
+	// with a restriction tag, restriction:<vehicle> tags do not narrow it
+	plain := r.Tags_.Has("restriction")
+	for _, k := range keys {
+		v := r.Tags_.Get(k)
+		switch {
+		case k == "restriction":
+			rs.Value = v
+		case k == "restriction:conditional":
+		case strings.HasPrefix(k, "restriction:") && strings.HasSuffix(k, ":conditional"):
+			if general {
+				continue
+			}
+			if condition == "" {
+				condition = v
+			}
+			if v == condition {
+				condVehicles = append(condVehicles, strings.TrimSuffix(strings.TrimPrefix(k, "restriction:"), ":conditional"))
+			}
+		case strings.HasPrefix(k, "restriction:"):
+			if plain {
+				continue
+			}
+			rs.Vehicles = append(rs.Vehicles, strings.TrimPrefix(k, "restriction:"))
+			if rs.Value == "" {
+				rs.Value = v
+			}
+		case k == "except":
+			for _, e := range strings.Split(v, ";") {
+				rs.Except = append(rs.Except, strings.TrimSpace(e))
+			}
+		}
+	}
+	if condition != "" {
+		// "no_left_turn @ (Mo-Fr 07:00-09:00)"
+		parts := strings.SplitN(condition, "@", 2)
+		if len(parts) != 2 {
+			return nil, errors.New(fmt.Sprintf("Relation #%d: invalid conditional restriction %q", r.Id_, condition))
+		}
+		switch {
+		case rs.Value == "":
+			rs.Value = strings.TrimSpace(parts[0])
+			rs.Condition = strings.Trim(strings.TrimSpace(parts[1]), "()")
+			rs.Conditional = true
+			rs.Vehicles = condVehicles
+		case general:
+			rs.Condition = strings.Trim(strings.TrimSpace(parts[1]), "()")
+		}
+	}
+	if t := r.Tags_.Get("type"); t != "restriction" {
+		rs.Vehicles = []string{strings.TrimPrefix(t, "restriction:")}
+	}
+	switch {
+	case strings.HasPrefix(rs.Value, "no_"):
+		rs.Kind = NoTurn
+	case strings.HasPrefix(rs.Value, "only_"):
+		rs.Kind = OnlyTurn
+	default:
+		return nil, errors.New(fmt.Sprintf("Relation #%d: unknown restriction %q", r.Id_, rs.Value))
+	}
+
+	for _, m := range r.Members_ {
+		if missing(m.Ref) {
+			return nil, errors.New(fmt.Sprintf("Relation #%d is incomplete: member #%d is missing", r.Id_, m.Id_))
+		}
+		switch m.Role {
+		case "from", "to":
+			w, ok := m.Ref.(*way.Way)
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Relation #%d: %s member #%d is not a way", r.Id_, m.Role, m.Id_))
+			}
+			if m.Role == "from" {
+				if rs.From != nil {
+					return nil, errors.New(fmt.Sprintf("Relation #%d has more than one from way", r.Id_))
+				}
+				rs.From = w
+			} else {
+				if rs.To != nil {
+					return nil, errors.New(fmt.Sprintf("Relation #%d has more than one to way", r.Id_))
+				}
+				rs.To = w
+			}
+		case "via":
+			switch v := m.Ref.(type) {
+			case *node.Node:
+				if rs.ViaNode != nil {
+					return nil, errors.New(fmt.Sprintf("Relation #%d has more than one via node", r.Id_))
+				}
+				rs.ViaNode = v
+			case *way.Way:
+				rs.ViaWays = append(rs.ViaWays, v)
+			default:
+				return nil, errors.New(fmt.Sprintf("Relation #%d: via member #%d is a relation", r.Id_, m.Id_))
+			}
+		}
+	}
+	if rs.From == nil || rs.To == nil {
+		return nil, errors.New(fmt.Sprintf("Relation #%d needs a from and a to way", r.Id_))
+	}
+	if (rs.ViaNode == nil) == (len(rs.ViaWays) == 0) {
+		return nil, errors.New(fmt.Sprintf("Relation #%d needs either one via node or via ways", r.Id_))
+	}
+	for _, w := range append([]*way.Way{rs.From, rs.To}, rs.ViaWays...) {
+		if len(w.Nodes_) < 2 {
+			return nil, errors.New(fmt.Sprintf("Relation #%d: way #%d has too few nodes", r.Id_, w.Id_))
+		}
+	}
+
+	if rs.ViaNode != nil {
+		if !endsAt(rs.From, rs.ViaNode.Id_) || !endsAt(rs.To, rs.ViaNode.Id_) {
+			return nil, errors.New(fmt.Sprintf("Relation #%d: from and to ways must end at the via node", r.Id_))
+		}
+		return rs, nil
+	}
+	if _, err := rs.ViaChain(); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// checks if a member is not resolved, the parsers may set a nil pointer
+func missing(i item.Item) bool {
+	switch v := i.(type) {
+	case nil:
+		return true
+	case *node.Node:
+		return v == nil
+	case *way.Way:
+		return v == nil
+	case *Relation:
+		return v == nil
+	}
+	return false
+}
+
+// checks if the first or the last node of the way has the given id
+func endsAt(w *way.Way, id int64) bool {
+	return w.FirstNode().Id_ == id || w.LastNode().Id_ == id
+}
+
+// the other end of the way
+func otherEnd(w *way.Way, id int64) int64 {
+	if w.FirstNode().Id_ == id {
+		return w.LastNode().Id_
+	}
+	return w.FirstNode().Id_
+}
+
+// Returns the ids of the nodes where the restriction passes from one way
+// to the next: from the end of the from way through the ends of the via
+// ways to the start of the to way. For a via node this is just its id.
+func (rs *Restriction) ViaChain() ([]int64, error) {
+	if rs.ViaNode != nil {
+		return []int64{rs.ViaNode.Id_}, nil
+	}
+	for _, start := range []int64{rs.From.FirstNode().Id_, rs.From.LastNode().Id_} {
+		chain := []int64{start}
+		ok := true
+		for _, v := range rs.ViaWays {
+			cur := chain[len(chain)-1]
+			if !endsAt(v, cur) {
+				ok = false
+				break
+			}
+			chain = append(chain, otherEnd(v, cur))
+		}
+		if ok && endsAt(rs.To, chain[len(chain)-1]) {
+			return chain, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("Relation #%d: the from, via and to ways are not connected", rs.Relation.Id_))
+}
+
+// Checks if the restriction applies to a vehicle known by the given
+// names (e.g. "motorcar", "motor_vehicle", "vehicle"). It does not apply
+// if one of the names is excepted or if it is limited to other vehicles.
+func (rs *Restriction) AppliesTo(vehicles ...string) bool {
+	for _, e := range rs.Except {
+		for _, v := range vehicles {
+			if e == v {
+				return false
+			}
+		}
+	}
+	if len(rs.Vehicles) == 0 {
+		return true
+	}
+	for _, rv := range rs.Vehicles {
+		for _, v := range vehicles {
+			if rv == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,168 @@
+package relation
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// The ways of the restrictions: 10 (1-2), 11 (2-3), 12 (4-3), 13 (4-5)
+// and 14 (2-6) form the chain 1-2-3-4-5 with a branch to 6 at node 2.
+// Way 15 has a single node.
+func restrictionWays() (testNodes, map[int64]*way.Way) {
+	tn := testNodes{}
+	for id := int64(1); id <= 6; id++ {
+		tn.at(id, 0, float64(id)/1000)
+	}
+	ways := map[int64]*way.Way{
+		10: tn.way(10, 1, 2),
+		11: tn.way(11, 2, 3),
+		12: tn.way(12, 4, 3),
+		13: tn.way(13, 4, 5),
+		14: tn.way(14, 2, 6),
+		15: tn.way(15, 6),
+	}
+	return tn, ways
+}
+
+func wayMember(role string, w *way.Way) *Member {
+	return &Member{Type_: item.TypeWay, Id_: w.Id_, Role: role, Ref: w}
+}
+
+func restriction(t tags.Tags, ml ...*Member) *Relation {
+	return &Relation{Id_: 1, Tags_: &t, Members_: ml}
+}
+
+func TestRestrictionTags(t *testing.T) {
+	tn, ways := restrictionWays()
+	for _, c := range []struct {
+		tags        tags.Tags
+		kind        RestrictionKind
+		value       string
+		vehicles    []string
+		except      []string
+		condition   string
+		conditional bool
+	}{
+		{tags.Tags{"restriction": "no_left_turn"}, NoTurn, "no_left_turn", nil, nil, "", false},
+		{tags.Tags{"restriction": "only_straight_on", "except": "bicycle; psv"}, OnlyTurn, "only_straight_on", nil, []string{"bicycle", "psv"}, "", false},
+		{tags.Tags{"type": "restriction:hgv", "restriction": "no_u_turn"}, NoTurn, "no_u_turn", []string{"hgv"}, nil, "", false},
+		// restriction:<vehicle> without a restriction tag
+		{tags.Tags{"restriction:hgv": "no_right_turn", "restriction:bus": "no_right_turn"}, NoTurn, "no_right_turn", []string{"bus", "hgv"}, nil, "", false},
+		// with a restriction tag, restriction:<vehicle> does not narrow it
+		{tags.Tags{"restriction": "no_left_turn", "restriction:hgv": "only_straight_on"}, NoTurn, "no_left_turn", nil, nil, "", false},
+		{tags.Tags{"restriction:conditional": "no_left_turn @ (Mo-Fr 07:00-09:00)"}, NoTurn, "no_left_turn", nil, nil, "Mo-Fr 07:00-09:00", true},
+		// the restriction tag applies always
+		{tags.Tags{"restriction": "no_left_turn", "restriction:conditional": "no_left_turn @ (Sa)"}, NoTurn, "no_left_turn", nil, nil, "Sa", false},
+		{tags.Tags{"restriction:hgv:conditional": "only_straight_on @ 22:00-06:00", "restriction:bus:conditional": "only_straight_on @ 22:00-06:00"}, OnlyTurn, "only_straight_on", []string{"bus", "hgv"}, nil, "22:00-06:00", true},
+		// only the vehicles with the condition of the first key
+		{tags.Tags{"restriction:hgv:conditional": "no_left_turn @ (Su)", "restriction:bus:conditional": "no_left_turn @ (Sa)"}, NoTurn, "no_left_turn", []string{"bus"}, nil, "Sa", true},
+		{tags.Tags{"restriction:conditional": "no_left_turn @ (Sa)", "restriction:hgv:conditional": "no_u_turn @ (Su)"}, NoTurn, "no_left_turn", nil, nil, "Sa", true},
+	} {
+		name := c.tags.String()
+		if _, ok := c.tags["type"]; !ok {
+			c.tags["type"] = "restriction"
+		}
+		rs, err := restriction(c.tags, wayMember("from", ways[10]), &Member{Type_: item.TypeNode, Id_: 2, Role: "via", Ref: tn[2]}, wayMember("to", ways[14])).Restriction()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if rs.Kind != c.kind || rs.Value != c.value || !slices.Equal(rs.Vehicles, c.vehicles) || !slices.Equal(rs.Except, c.except) {
+			t.Errorf("%s: %s %q for %v except %v, want %s %q for %v except %v", name, rs.Kind, rs.Value, rs.Vehicles, rs.Except, c.kind, c.value, c.vehicles, c.except)
+		}
+		if rs.Condition != c.condition || rs.Conditional != c.conditional {
+			t.Errorf("%s: condition %q %v, want %q %v", name, rs.Condition, rs.Conditional, c.condition, c.conditional)
+		}
+		if rs.From != ways[10] || rs.To != ways[14] || rs.ViaNode != tn[2] || rs.ViaWays != nil {
+			t.Errorf("%s: members %v %v %v %v", name, rs.From, rs.ViaNode, rs.ViaWays, rs.To)
+		}
+	}
+}
+
+func TestRestrictionErrors(t *testing.T) {
+	tn, ways := restrictionWays()
+	from, to := wayMember("from", ways[10]), wayMember("to", ways[14])
+	via := &Member{Type_: item.TypeNode, Id_: 2, Role: "via", Ref: tn[2]}
+	valid := tags.Tags{"type": "restriction", "restriction": "no_left_turn"}
+	for _, c := range []struct {
+		tags    tags.Tags
+		members []*Member
+		err     string
+	}{
+		{tags.Tags{"type": "multipolygon"}, nil, "not a restriction"},
+		{tags.Tags{"type": "restriction"}, nil, `unknown restriction ""`},
+		{tags.Tags{"type": "restriction", "restriction": "give_way"}, nil, `unknown restriction "give_way"`},
+		{tags.Tags{"type": "restriction", "restriction:conditional": "no_left_turn"}, nil, "invalid conditional"},
+		{valid, []*Member{from, via, {Type_: item.TypeWay, Id_: 16, Role: "to"}}, "member #16 is missing"},
+		{valid, []*Member{from, via, {Type_: item.TypeWay, Id_: 16, Role: "to", Ref: (*way.Way)(nil)}}, "member #16 is missing"},
+		{valid, []*Member{from, wayMember("from", ways[11]), via, to}, "more than one from way"},
+		{valid, []*Member{from, via, to, wayMember("to", ways[11])}, "more than one to way"},
+		{valid, []*Member{{Type_: item.TypeNode, Id_: 1, Role: "from", Ref: tn[1]}, via, to}, "from member #1 is not a way"},
+		{valid, []*Member{from, via, via, to}, "more than one via node"},
+		{valid, []*Member{from, {Type_: item.TypeRelation, Id_: 2, Role: "via", Ref: &Relation{Id_: 2}}, to}, "via member #2 is a relation"},
+		{valid, []*Member{from, to}, "either one via node or via ways"},
+		{valid, []*Member{from, via, wayMember("via", ways[11]), to}, "either one via node or via ways"},
+		{valid, []*Member{via, to}, "needs a from and a to way"},
+		{valid, []*Member{from, via}, "needs a from and a to way"},
+		{valid, []*Member{from, via, wayMember("to", ways[15])}, "way #15 has too few nodes"},
+		{valid, []*Member{from, via, wayMember("to", ways[13])}, "must end at the via node"},
+		// the via ways 11 and 12 are needed to get from 10 to 13
+		{valid, []*Member{from, wayMember("via", ways[12]), wayMember("to", ways[13])}, "not connected"},
+		{valid, []*Member{from, wayMember("via", ways[11]), wayMember("to", ways[13])}, "not connected"},
+		{valid, []*Member{from, wayMember("via", ways[12]), wayMember("via", ways[11]), wayMember("to", ways[13])}, "not connected"},
+	} {
+		rs, err := restriction(c.tags, c.members...).Restriction()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%v %v: %v %v, want an error %q", c.tags, c.members, rs, err, c.err)
+		}
+	}
+}
+
+func TestViaChain(t *testing.T) {
+	tn, ways := restrictionWays()
+	valid := tags.Tags{"type": "restriction", "restriction": "no_u_turn"}
+	for _, c := range []struct {
+		members []*Member
+		want    []int64
+	}{
+		{[]*Member{wayMember("from", ways[10]), {Type_: item.TypeNode, Id_: 2, Role: "via", Ref: tn[2]}, wayMember("to", ways[14])}, []int64{2}},
+		{[]*Member{wayMember("from", ways[10]), wayMember("via", ways[11]), wayMember("via", ways[12]), wayMember("to", ways[13])}, []int64{2, 3, 4}},
+		// the other way round, against the direction of the ways
+		{[]*Member{wayMember("to", ways[10]), wayMember("via", ways[12]), wayMember("via", ways[11]), wayMember("from", ways[13])}, []int64{4, 3, 2}},
+		{[]*Member{wayMember("from", ways[14]), wayMember("via", ways[11]), wayMember("to", ways[12])}, []int64{2, 3}},
+	} {
+		rs, err := restriction(valid, c.members...).Restriction()
+		if err != nil {
+			t.Errorf("%v: %v", c.members, err)
+			continue
+		}
+		if chain, err := rs.ViaChain(); err != nil || !slices.Equal(chain, c.want) {
+			t.Errorf("%v: chain %v %v, want %v", c.members, chain, err, c.want)
+		}
+	}
+}
+
+func TestAppliesTo(t *testing.T) {
+	for _, c := range []struct {
+		rs       Restriction
+		vehicles []string
+		want     bool
+	}{
+		{Restriction{}, []string{"motorcar", "motor_vehicle", "vehicle"}, true},
+		{Restriction{Except: []string{"bicycle"}}, []string{"bicycle", "vehicle"}, false},
+		{Restriction{Except: []string{"bicycle"}}, []string{"motorcar", "motor_vehicle", "vehicle"}, true},
+		{Restriction{Vehicles: []string{"hgv"}}, []string{"motorcar", "motor_vehicle", "vehicle"}, false},
+		{Restriction{Vehicles: []string{"motor_vehicle"}}, []string{"motorcar", "motor_vehicle", "vehicle"}, true},
+		{Restriction{Vehicles: []string{"motor_vehicle"}, Except: []string{"motorcar"}}, []string{"motorcar", "motor_vehicle", "vehicle"}, false},
+	} {
+		if got := c.rs.AppliesTo(c.vehicles...); got != c.want {
+			t.Errorf("%v except %v AppliesTo(%v) = %v, want %v", c.rs.Vehicles, c.rs.Except, c.vehicles, got, c.want)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -1,28 +1,16 @@
 package relation
 
-import ()
+import (
+	"github.com/brechtvm/osm/item"
+)
 
 // sort.Interface:
 
-// sort negative ids on top of all other in ascending math.Abs(Id)
+// sort negative ids on top of all other in ascending math.Abs(Id), see
+// item.IdLess()
 func (rl *RelationList) Less(i, j int) bool {
 	r := []*Relation(*rl)
-	if r[i].Id_ < 0 && r[j].Id_ < 0 {
-		if r[i].Id_ > r[j].Id_ {
-			return true
-		}
-		return false
-	}
-	if r[i].Id_ < 0 && r[j].Id_ > 0 {
-		return false
-	}
-	if r[i].Id_ > 0 && r[j].Id_ < 0 {
-		return true
-	}
-	if r[i].Id_ < r[j].Id_ {
-		return true
-	}
-	return false
+	return item.IdLess(r[i].Id_, r[j].Id_)
 }
 
 func (rl *RelationList) Len() int {
@@ -0,0 +1,151 @@
+package osm
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/way"
+	"sync"
+)
+
+// Shared guards an *OSM for concurrent use by many readers and writers.
+// The *OSM itself (and the items in it) is not safe for concurrent use,
+// batch tools running in a single goroutine should use it directly.
+//
+// Guarantees:
+//
+//   - functions passed to Read() run concurrently with other Read()
+//     functions, but never while an Update() function runs
+//   - functions passed to Update() run exclusively
+//   - an *OSM returned by Snapshot() is a deep copy (but for the Locations
+//     index) which is not changed by later updates, it may be used
+//     without locking by one goroutine (or by many, as long as none of
+//     them changes it)
+//
+// The *OSM and the items must not be used (or kept) outside of the
+// functions passed to Read() and Update(). Before the OSM is shared and
+// after every Update() the NodeStore and the Locations index are sorted
+// and a tag index the OSM has is rebuilt (tags may have been changed in
+// place), so that the following calls do not change the OSM and may be
+// used in Read():
+//
+//   - GetNode(), GetWay(), GetRelation(), NodeCount() and the lists and
+//     iterators of the items (AllNodes(), SortedWays(), ...)
+//   - ResolveWay() and the extracts, as long as all ways are resolved,
+//     see below
+//   - the tag queries (NodesWithTag(), ...), as long as the OSM has a tag
+//     index: these build it on the first query otherwise, so call
+//     BuildTagIndex() before the OSM is shared or in an Update()
+//   - the methods of the items which do not change them (Tags_.Get(),
+//     Length(), MultiPolygon(), ...)
+//
+// Calls which change the OSM or its items (AddNode(), SetTags(),
+// BuildTagIndex(), FlushNodes(), ...) belong in Update(). The parsers
+// resolve the ways of an OSM without a NodeStore, ways added with only
+// NodeIDs must be resolved with ResolveWay() in the Update() which adds
+// them.
+//
+// For an OSM with a NodeStore GetNode() keeps the nodes it materialises in
+// the Nodes map, so Read() functions on such an OSM run one at a time.
+type Shared struct {
+	mu sync.RWMutex
+	// held by the readers of an OSM with a NodeStore
+	nodes sync.Mutex
+	o     *OSM
+}
+
+// returns a new Shared for o, o must not be used directly afterwards
+func NewShared(o *OSM) *Shared {
+	o.prepareRead()
+	return &Shared{o: o}
+}
+
+// finishes all lazy work which would change the OSM on reads
+func (o *OSM) prepareRead() {
+	if o.NodeStore != nil {
+		o.NodeStore.Sort()
+	}
+	if s, ok := o.Locations.(interface{ Sort() }); ok {
+		s.Sort()
+	}
+	// items may have been changed in place
+	if o.TagIndex != nil {
+		o.BuildTagIndex()
+	}
+}
+
+// takes the read lock, and for an OSM with a NodeStore the nodes lock,
+// returns the function which releases them
+func (s *Shared) rlock() func() {
+	s.mu.RLock()
+	if s.o.NodeStore == nil {
+		return s.mu.RUnlock
+	}
+	s.nodes.Lock()
+	return func() {
+		s.nodes.Unlock()
+		s.mu.RUnlock()
+	}
+}
+
+// runs fn with a read lock held
+func (s *Shared) Read(fn func(o *OSM)) {
+	defer s.rlock()()
+	fn(s.o)
+}
+
+// runs fn with the write lock held, the error of fn is returned
+func (s *Shared) Update(fn func(o *OSM) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.o.prepareRead()
+	return fn(s.o)
+}
+
+// Returns a deep copy of the current data, see OSM.Clone(). The Locations
+// index is not copied but shared with the live data, Update() functions
+// must not change it while snapshots are in use.
+func (s *Shared) Snapshot() *OSM {
+	defer s.rlock()()
+	return s.o.Clone()
+}
+
+// replaces the data with o, e.g. with an updated snapshot. The old *OSM
+// is returned.
+func (s *Shared) Replace(o *OSM) *OSM {
+	o.prepareRead()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.o
+	s.o = o
+	return old
+}
+
+// returns a deep copy of the node with the given id, nil if there is none
+func (s *Shared) GetNode(id int64) *node.Node {
+	defer s.rlock()()
+	if n := s.o.GetNode(id); n != nil {
+		return n.DeepCopy()
+	}
+	return nil
+}
+
+// returns a deep copy of the way with the given id, nil if there is none
+func (s *Shared) GetWay(id int64) *way.Way {
+	defer s.rlock()()
+	if w := s.o.GetWay(id); w != nil {
+		return w.DeepCopy()
+	}
+	return nil
+}
+
+// returns a deep copy of the relation with the given id, nil if there is
+// none
+func (s *Shared) GetRelation(id int64) *relation.Relation {
+	defer s.rlock()()
+	if r := s.o.GetRelation(id); r != nil {
+		return r.DeepCopy()
+	}
+	return nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
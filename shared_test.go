@@ -0,0 +1,75 @@
+package osm
+
+import (
+	"sync"
+	"testing"
+)
+
+// the ways are not resolved by NewShared() or Update(), and no tag index
+// is built for an OSM without one
+func TestSharedPrepare(t *testing.T) {
+	o := NewOSM(nil)
+	o.AddNode(testNode(1, 50, 4))
+	o.AddNode(testNode(2, 50.1, 4.1, "amenity", "bench"))
+	o.AddWay(testWay(10, []int64{1, 2}))
+	s := NewShared(o)
+	s.Update(func(o *OSM) error {
+		o.AddWay(testWay(11, []int64{2, 1}))
+		return o.ResolveWay(o.Ways[11])
+	})
+	s.Read(func(o *OSM) {
+		if o.TagIndex != nil {
+			t.Error("tag index built for an OSM without one")
+		}
+		if len(o.Ways[10].Nodes_) != 0 {
+			t.Error("way 10 resolved by NewShared() or Update()")
+		}
+		if w := o.Ways[11]; len(w.Nodes_) != 2 || w.Nodes_[0] != o.GetNode(2) {
+			t.Error("way 11 not resolved in Update()")
+		}
+	})
+}
+
+// the tag index is rebuilt after an Update() which changed tags in place
+func TestSharedTagIndex(t *testing.T) {
+	o := compactOSM()
+	o.BuildTagIndex()
+	s := NewShared(o)
+	s.Update(func(o *OSM) error {
+		o.GetNode(3).Tags_.Add("amenity", "bench")
+		return nil
+	})
+	s.Read(func(o *OSM) {
+		if got := o.NodesWithTag("amenity", "bench").Len(); got != 2 {
+			t.Errorf("%d benches after the update, want 2", got)
+		}
+	})
+}
+
+// concurrent reads of an OSM with a NodeStore, see go test -race
+func TestSharedCompactReads(t *testing.T) {
+	s := NewShared(compactOSM())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Read(func(o *OSM) {
+				for _, w := range o.Ways {
+					if err := o.ResolveWay(w); err != nil {
+						t.Error(err)
+					}
+				}
+				if o.GetNode(4) == nil {
+					t.Errorf("node 4 missing")
+				}
+			})
+			if n := s.GetNode(1); n == nil || n.Position_.Lat != 50 {
+				t.Errorf("GetNode(1) = %v", n)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
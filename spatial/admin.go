@@ -0,0 +1,150 @@
+package spatial
+
+import (
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/way"
+	"iter"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// an administrative boundary of an AdminIndex
+type Boundary struct {
+	Relation     *relation.Relation
+	Level        int // admin_level, 0 if missing or invalid
+	Name         string
+	MultiPolygon polygon.MultiPolygon
+	Area         distance.Area
+}
+
+// AdminIndex finds the administrative areas (countries, provinces,
+// municipalities, ...) containing a position. Like Index it is not
+// updated when the OSM changes and it is safe for concurrent lookups.
+type AdminIndex struct {
+	parts *RTree[adminPart]
+	// the boundary relations which could not be assembled
+	Errors []error
+}
+
+// a polygon of a boundary, these are indexed separately as the parts of
+// a boundary (e.g. exclaves) may be far apart
+type adminPart struct {
+	boundary *Boundary
+	polygon  *polygon.Polygon
+}
+
+// Builds the index from all boundary=administrative relations of o. The
+// rings are assembled by relation.MultiPolygon(), relations which cannot
+// be assembled (e.g. as members are missing in an extract) are skipped
+// and their errors are kept in Errors.
+//
+// For an OSM with a NodeStore this changes o: the nodes of the member
+// ways are resolved in place (see OSM.ResolveWay).
+func NewAdminIndex(o *osm.OSM) *AdminIndex {
+	x := &AdminIndex{}
+	var entries []Entry[adminPart]
+relations:
+	for r := range o.AllRelations() {
+		if !r.IsAreaRelation() || r.Tags_.Get("boundary") != "administrative" {
+			continue
+		}
+		for _, m := range r.Members_ {
+			if w, ok := m.Ref.(*way.Way); ok && w != nil {
+				if err := o.ResolveWay(w); err != nil {
+					x.Errors = append(x.Errors, err)
+					continue relations
+				}
+			}
+		}
+		mp, err := r.MultiPolygon()
+		if err != nil {
+			x.Errors = append(x.Errors, err)
+			continue
+		}
+		b := &Boundary{Relation: r, Name: r.Tags_.Get("name"), MultiPolygon: mp, Area: mp.GeodesicArea()}
+		b.Level, _ = strconv.Atoi(r.Tags_.Get("admin_level"))
+		for _, p := range mp {
+			bb, err := p.BoundingBox()
+			if err != nil {
+				continue
+			}
+			// two rectangles for a polygon across the antimeridian
+			for _, rect := range BBoxRects(bb) {
+				entries = append(entries, Entry[adminPart]{Rect: rect, Value: adminPart{boundary: b, polygon: p}})
+			}
+		}
+	}
+	x.parts = NewRTree(entries)
+	return x
+}
+
+// Returns the boundaries containing p ordered by admin_level, i.e. from
+// the country down to the smallest area. Boundaries with the same level
+// are ordered by size, largest first.
+func (x *AdminIndex) Lookup(p *point.Point) []*Boundary {
+	var bl []*Boundary
+	seen := make(map[*Boundary]bool)
+	x.parts.Search(PointRect(p), func(e Entry[adminPart]) bool {
+		b := e.Value.boundary
+		if !seen[b] && e.Value.polygon.Contains(p) {
+			seen[b] = true
+			bl = append(bl, b)
+		}
+		return true
+	})
+	sort.Slice(bl, func(i, j int) bool {
+		if bl[i].Level != bl[j].Level {
+			return bl[i].Level < bl[j].Level
+		}
+		return bl[i].Area > bl[j].Area
+	})
+	return bl
+}
+
+// returns the boundary with the given admin_level containing p, nil if
+// there is none
+func (x *AdminIndex) LookupLevel(p *point.Point, level int) *Boundary {
+	for _, b := range x.Lookup(p) {
+		if b.Level == level {
+			return b
+		}
+	}
+	return nil
+}
+
+// calls fn with the boundaries (see Lookup()) of every node
+func (x *AdminIndex) AnnotateNodes(nodes iter.Seq[*node.Node], fn func(*node.Node, []*Boundary)) {
+	for n := range nodes {
+		if n.Position_ != nil {
+			fn(n, x.Lookup(n.Position_))
+		}
+	}
+}
+
+// Calls fn with the boundaries (see Lookup()) of every way. The position
+// used is the centroid for closed ways, note that it may be outside of a
+// concave area. Open ways and closed ways without an area have no
+// centroid, the middle of the way is used for them. Ways without nodes
+// are skipped.
+func (x *AdminIndex) AnnotateWays(ways iter.Seq[*way.Way], fn func(*way.Way, []*Boundary)) {
+	for w := range ways {
+		var p *point.Point
+		if c := w.Centroid(); c != nil && !math.IsNaN(c.Lat+c.Lon) && !math.IsInf(c.Lat+c.Lon, 0) {
+			p = c
+		}
+		if p == nil && len(w.Nodes_) >= 2 {
+			p, _ = w.PointAt(w.Length() / 2)
+		}
+		if p != nil {
+			fn(w, x.Lookup(p))
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,126 @@
+package spatial
+
+import (
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"strings"
+	"testing"
+)
+
+type adminOSM struct {
+	*osm.OSM
+	id int64
+}
+
+func (a *adminOSM) node(lat, lon float64) *node.Node {
+	a.id++
+	n := &node.Node{Id_: a.id, Position_: point.New(lat, lon), Tags_: tags.New()}
+	a.AddNode(n)
+	return n
+}
+
+// a closed way around the rectangle
+func (a *adminOSM) rect(lat0, lon0, lat1, lon1 float64) *way.Way {
+	a.id++
+	w := &way.Way{Id_: a.id, Tags_: tags.New()}
+	w.Nodes_ = []*node.Node{a.node(lat0, lon0), a.node(lat0, lon1), a.node(lat1, lon1), a.node(lat1, lon0)}
+	w.Nodes_ = append(w.Nodes_, w.Nodes_[0])
+	a.AddWay(w)
+	return w
+}
+
+// a boundary relation, the roles are not used
+func (a *adminOSM) boundary(level, name string, ways ...*way.Way) {
+	a.id++
+	r := &relation.Relation{Id_: a.id, Tags_: &tags.Tags{"type": "boundary", "boundary": "administrative", "admin_level": level, "name": name}}
+	for _, w := range ways {
+		r.Members_ = append(r.Members_, &relation.Member{Type_: item.TypeWay, Id_: w.Id_, Role: "outer", Ref: w})
+	}
+	a.AddRelation(r)
+}
+
+// A country of two provinces. The municipality A in the west has a hole,
+// the municipality B, and an exclave in the eastern province.
+func adminIndex() *AdminIndex {
+	a := &adminOSM{OSM: osm.NewOSM(nil)}
+	a.boundary("2", "C", a.rect(0, 0, 10, 10))
+	a.boundary("4", "W", a.rect(0, 0, 10, 5))
+	a.boundary("4", "E", a.rect(0, 5, 10, 10))
+	hole := a.rect(2, 2, 3, 3)
+	a.boundary("8", "A", a.rect(1, 1, 4, 4), hole, a.rect(1, 6, 2, 7))
+	a.boundary("8", "B", hole)
+	// incomplete, a member way is missing
+	a.id++
+	a.AddRelation(&relation.Relation{Id_: a.id, Tags_: &tags.Tags{"type": "boundary", "boundary": "administrative"}, Members_: []*relation.Member{{Type_: item.TypeWay, Id_: 999}}})
+	return NewAdminIndex(a.OSM)
+}
+
+func names(bl []*Boundary) string {
+	var nl []string
+	for _, b := range bl {
+		nl = append(nl, b.Name)
+	}
+	return strings.Join(nl, " ")
+}
+
+func TestAdminLookup(t *testing.T) {
+	x := adminIndex()
+	if len(x.Errors) != 1 {
+		t.Errorf("errors %v, want one for the incomplete relation", x.Errors)
+	}
+	for _, c := range []struct {
+		p    *point.Point
+		want string
+	}{
+		{point.New(1.5, 1.5), "C W A"},
+		{point.New(2.5, 2.5), "C W B"},
+		{point.New(1.5, 6.5), "C E A"},
+		{point.New(5, 3), "C W"},
+		{point.New(5, 8), "C E"},
+		{point.New(11, 11), ""},
+	} {
+		if got := names(x.Lookup(c.p)); got != c.want {
+			t.Errorf("Lookup(%v) = %q, want %q", c.p, got, c.want)
+		}
+	}
+	if b := x.LookupLevel(point.New(2.5, 2.5), 8); b == nil || b.Name != "B" {
+		t.Errorf("municipality of the hole is %v, want B", b)
+	}
+	if b := x.LookupLevel(point.New(5, 3), 8); b != nil {
+		t.Errorf("municipality %s outside of all municipalities", b.Name)
+	}
+}
+
+func TestAdminAnnotate(t *testing.T) {
+	x := adminIndex()
+	a := &adminOSM{OSM: osm.NewOSM(nil), id: 1000}
+	// the centroid of the closed way is in the exclave of A, the middle of
+	// the open way in W
+	closed := a.rect(1.2, 6.2, 1.8, 6.8)
+	open := &way.Way{Id_: 2000, Nodes_: []*node.Node{a.node(5, 1), a.node(5, 3)}, Tags_: tags.New()}
+	a.AddWay(open)
+	got := make(map[int64]string)
+	x.AnnotateWays(a.AllWays(), func(w *way.Way, bl []*Boundary) {
+		got[w.Id_] = names(bl)
+	})
+	if got[closed.Id_] != "C E A" || got[open.Id_] != "C W" {
+		t.Errorf("closed way in %q, open way in %q, want \"C E A\" and \"C W\"", got[closed.Id_], got[open.Id_])
+	}
+	n := 0
+	x.AnnotateNodes(a.AllNodes(), func(nd *node.Node, bl []*Boundary) {
+		n++
+		if nd.Position_.Lat == 5 && names(bl) != "C W" {
+			t.Errorf("node %v in %q, want \"C W\"", nd.Position_, names(bl))
+		}
+	})
+	if n != 6 {
+		t.Errorf("%d nodes annotated, want 6", n)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
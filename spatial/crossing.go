@@ -0,0 +1,84 @@
+package spatial
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"strconv"
+)
+
+// two indexed ways crossing without a shared node
+type Crossing struct {
+	Way, Other            *way.Way
+	Segment, OtherSegment int
+	Point                 *point.Point
+}
+
+// a segment of an indexed way
+type segmentKey struct {
+	w *way.Way
+	i int
+}
+
+// Returns all crossings of indexed ways, each pair of segments is
+// reported once, with the way with the lower id as Way. Ways are only
+// compared if pair returns true for them (or pair is nil), e.g. SameLevel.
+// Self intersections are not reported, see way.SelfIntersections().
+func (x *Index) Crossings(pair func(a, b *way.Way) bool) []Crossing {
+	var cl []Crossing
+	// the pairs of segments of different ways with the same id (e.g. new
+	// ways with id 0) which were compared
+	done := make(map[[2]segmentKey]bool)
+	world := Rect{-90, -180, 90, 180}
+	x.segments.Search(world, func(e Entry[Segment]) bool {
+		s := e.Value
+		x.segments.Search(e.Rect, func(f Entry[Segment]) bool {
+			t := f.Value
+			if t.Way == s.Way || t.Way.Id_ < s.Way.Id_ {
+				return true
+			}
+			if t.Way.Id_ == s.Way.Id_ {
+				sk, tk := segmentKey{s.Way, s.Index}, segmentKey{t.Way, t.Index}
+				if done[[2]segmentKey{tk, sk}] {
+					return true
+				}
+				done[[2]segmentKey{sk, tk}] = true
+			}
+			if pair != nil && !pair(s.Way, t.Way) {
+				return true
+			}
+			a, b := s.Way.Nodes_[s.Index], s.Way.Nodes_[s.Index+1]
+			c, d := t.Way.Nodes_[t.Index], t.Way.Nodes_[t.Index+1]
+			sw := &way.Way{Nodes_: []*node.Node{a, b}}
+			for _, i := range sw.Intersections(&way.Way{Nodes_: []*node.Node{c, d}}) {
+				cl = append(cl, Crossing{Way: s.Way, Other: t.Way, Segment: s.Index, OtherSegment: t.Index, Point: i.Point})
+			}
+			return true
+		})
+		return true
+	})
+	return cl
+}
+
+// the vertical level of a way from its layer, bridge and tunnel tags
+func level(t *tags.Tags) (layer int, bridge, tunnel bool) {
+	if t == nil {
+		return
+	}
+	layer, _ = strconv.Atoi(t.Get("layer"))
+	bridge = t.Get("bridge") != "" && t.Get("bridge") != "no"
+	tunnel = t.Get("tunnel") != "" && t.Get("tunnel") != "no"
+	return
+}
+
+// A pair func for Crossings() which only compares ways on the same
+// level: with equal layer tags (0 if missing) which are both (or both
+// not) bridges and both (or both not) tunnels.
+func SameLevel(a, b *way.Way) bool {
+	la, ba, ta := level(a.Tags_)
+	lb, bb, tb := level(b.Tags_)
+	return la == lb && ba == bb && ta == tb
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,117 @@
+package spatial
+
+import (
+	"fmt"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// the node ids are 10 times the way id plus the index
+func crossingWay(id int64, t *tags.Tags, ll ...float64) *way.Way {
+	w := &way.Way{Id_: id, Tags_: t}
+	for i := 0; i+1 < len(ll); i += 2 {
+		w.Nodes_ = append(w.Nodes_, &node.Node{Id_: 10*id + int64(i/2), Position_: point.New(ll[i], ll[i+1])})
+	}
+	return w
+}
+
+// the crossings as "way/segment other/segment lat,lon", sorted
+func crossingStrings(cl []Crossing) string {
+	var sl []string
+	for _, c := range cl {
+		sl = append(sl, fmt.Sprintf("%d/%d %d/%d %g,%g", c.Way.Id_, c.Segment, c.Other.Id_, c.OtherSegment, c.Point.Lat, c.Point.Lon))
+	}
+	sort.Strings(sl)
+	return strings.Join(sl, "; ")
+}
+
+// A road along the equator, crossed by a road and by a bridge, overlapped
+// by a collinear road which ends on a branch sharing a node of the first
+// road.
+func TestCrossings(t *testing.T) {
+	road := crossingWay(1, tags.New(), 0, 0, 0, 2)
+	crossing := crossingWay(2, tags.New(), -1, 1, 1, 1)
+	branch := crossingWay(3, tags.New(), 0, 2, 1, 2)
+	branch.Nodes_[0] = road.Nodes_[1]
+	bridge := crossingWay(4, &tags.Tags{"bridge": "yes", "layer": "1"}, -1, 0.5, 1, 0.5)
+	overlap := crossingWay(5, tags.New(), 0, 1.5, 0, 2.5)
+	x := NewFromWays(slices.Values([]*way.Way{overlap, bridge, branch, crossing, road}))
+
+	want := "1/0 2/0 0,1; 1/0 4/0 0,0.5; 1/0 5/0 0,1.5; 1/0 5/0 0,2; 3/0 5/0 0,2"
+	if got := crossingStrings(x.Crossings(nil)); got != want {
+		t.Errorf("crossings %s, want %s", got, want)
+	}
+	want = "1/0 2/0 0,1; 1/0 5/0 0,1.5; 1/0 5/0 0,2; 3/0 5/0 0,2"
+	if got := crossingStrings(x.Crossings(SameLevel)); got != want {
+		t.Errorf("crossings on the same level %s, want %s", got, want)
+	}
+}
+
+// New ways which all have the id 0: a road along the equator crossed by
+// a road and by a zigzag, which crosses the second road too. Each
+// crossing is reported once.
+func TestCrossingsSameId(t *testing.T) {
+	var nodeId int64
+	newWay := func(ll ...float64) *way.Way {
+		w := crossingWay(0, tags.New(), ll...)
+		for _, n := range w.Nodes_ {
+			nodeId--
+			n.Id_ = nodeId
+		}
+		return w
+	}
+	road := newWay(0, 0, 0, 2)
+	crossing := newWay(-1, 1, 2, 1)
+	zigzag := newWay(-1, 0.5, 1, 0.5, 1, 1.5, -1, 1.5)
+	x := NewFromWays(slices.Values([]*way.Way{zigzag, crossing, road}))
+
+	// the ways by name, a crossing in either order of the ways
+	names := map[*way.Way]string{road: "road", crossing: "crossing", zigzag: "zigzag"}
+	pair := func(a *way.Way, as int, b *way.Way, bs int, lat, lon float64) string {
+		ab := []string{fmt.Sprintf("%s/%d", names[a], as), fmt.Sprintf("%s/%d", names[b], bs)}
+		sort.Strings(ab)
+		return fmt.Sprintf("%s %s %g,%g", ab[0], ab[1], lat, lon)
+	}
+	var got []string
+	for _, c := range x.Crossings(nil) {
+		got = append(got, pair(c.Way, c.Segment, c.Other, c.OtherSegment, c.Point.Lat, c.Point.Lon))
+	}
+	sort.Strings(got)
+	want := []string{
+		pair(road, 0, crossing, 0, 0, 1),
+		pair(road, 0, zigzag, 0, 0, 0.5),
+		pair(road, 0, zigzag, 2, 0, 1.5),
+		pair(crossing, 0, zigzag, 1, 1, 1),
+	}
+	sort.Strings(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("crossings %v, want %v", got, want)
+	}
+}
+
+func TestSameLevel(t *testing.T) {
+	for _, c := range []struct {
+		a, b *tags.Tags
+		same bool
+	}{
+		{nil, tags.New(), true},
+		{&tags.Tags{"layer": "0"}, tags.New(), true},
+		{&tags.Tags{"bridge": "no"}, &tags.Tags{"tunnel": "no"}, true},
+		{&tags.Tags{"layer": "1"}, &tags.Tags{"layer": "1", "bridge": "viaduct"}, false},
+		{&tags.Tags{"layer": "-1", "tunnel": "yes"}, &tags.Tags{"layer": "-1", "tunnel": "culvert"}, true},
+		{&tags.Tags{"layer": "-1"}, tags.New(), false},
+	} {
+		a, b := &way.Way{Tags_: c.a}, &way.Way{Tags_: c.b}
+		if SameLevel(a, b) != c.same || SameLevel(b, a) != c.same {
+			t.Errorf("SameLevel(%v, %v) != %v", c.a, c.b, c.same)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,165 @@
+package spatial
+
+import (
+	"github.com/brechtvm/osm"
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/way"
+	"iter"
+)
+
+// a segment of a way, from node Index to node Index+1
+type Segment struct {
+	Way   *way.Way
+	Index int
+}
+
+func (s Segment) From() *point.Point { return s.Way.Nodes_[s.Index].Position_ }
+func (s Segment) To() *point.Point   { return s.Way.Nodes_[s.Index+1].Position_ }
+
+// the closest position on the segment to p, its distance from the segment
+// start and from p
+func (s Segment) Project(p *point.Point) (q *point.Point, at, d distance.Distance) {
+	q, at = p.ProjectOnSegment(s.From(), s.To())
+	return q, at, p.DistanceOf(q)
+}
+
+// the result of a snap to the nearest way
+type Snap struct {
+	Segment
+	Point    *point.Point      // the snapped position
+	Offset   distance.Distance // of Point from the first node of the way
+	Distance distance.Distance // between the query position and Point
+}
+
+// Index is a spatial index of the nodes and way segments of an OSM. It is
+// not updated when the OSM changes.
+type Index struct {
+	nodes    *RTree[*node.Node]
+	segments *RTree[Segment]
+}
+
+// Builds the index for o. Only ways for which wayFilter returns true are
+// indexed (all if wayFilter is nil), e.g.
+//
+//	spatial.New(o, item.HasKey[*way.Way]("highway"))
+//
+// Ways without nodes (see osm.ResolveWay()) are skipped.
+func New(o *osm.OSM, wayFilter func(*way.Way) bool) *Index {
+	var ne []Entry[*node.Node]
+	for n := range o.AllNodes() {
+		if n.Position_ != nil {
+			ne = append(ne, Entry[*node.Node]{Rect: PointRect(n.Position_), Value: n})
+		}
+	}
+	var se []Entry[Segment]
+	for w := range o.AllWays() {
+		if wayFilter == nil || wayFilter(w) {
+			se = appendSegments(se, w)
+		}
+	}
+	return &Index{nodes: NewRTree(ne), segments: NewRTree(se)}
+}
+
+func appendSegments(se []Entry[Segment], w *way.Way) []Entry[Segment] {
+	for i := 0; i < len(w.Nodes_)-1; i++ {
+		s := Segment{Way: w, Index: i}
+		se = append(se, Entry[Segment]{Rect: SegmentRect(s.From(), s.To()), Value: s})
+	}
+	return se
+}
+
+// Builds an index of the segments of the ways only, e.g. for snapping to
+// a selection of ways. NodesInBBox() and NearestNodes() find nothing.
+func NewFromWays(ways iter.Seq[*way.Way]) *Index {
+	var se []Entry[Segment]
+	for w := range ways {
+		se = appendSegments(se, w)
+	}
+	return &Index{nodes: NewRTree[*node.Node](nil), segments: NewRTree(se)}
+}
+
+// returns all nodes inside the bounding box (including the edges)
+func (x *Index) NodesInBBox(bb *bbox.BBox) []*node.Node {
+	var nl []*node.Node
+	for _, r := range BBoxRects(bb) {
+		x.nodes.Search(r, func(e Entry[*node.Node]) bool {
+			nl = append(nl, e.Value)
+			return true
+		})
+	}
+	return nl
+}
+
+// returns all ways with a segment whose bounding box intersects bb
+func (x *Index) WaysInBBox(bb *bbox.BBox) []*way.Way {
+	var wl []*way.Way
+	seen := make(map[*way.Way]bool)
+	for _, r := range BBoxRects(bb) {
+		x.segments.Search(r, func(e Entry[Segment]) bool {
+			if !seen[e.Value.Way] {
+				seen[e.Value.Way] = true
+				wl = append(wl, e.Value.Way)
+			}
+			return true
+		})
+	}
+	return wl
+}
+
+// returns all segments whose bounding box intersects r
+func (x *Index) Segments(r Rect) []Segment {
+	var sl []Segment
+	x.segments.Search(r, func(e Entry[Segment]) bool {
+		sl = append(sl, e.Value)
+		return true
+	})
+	return sl
+}
+
+// returns the k nodes closest to p for which pred returns true (all nodes
+// if pred is nil), closest first
+func (x *Index) NearestNodes(p *point.Point, k int, pred func(*node.Node) bool) []*node.Node {
+	var nl []*node.Node
+	if k <= 0 {
+		return nl
+	}
+	x.nodes.Nearest(p, func(e Entry[*node.Node]) distance.Distance {
+		return p.DistanceOf(e.Value.Position_)
+	}, func(e Entry[*node.Node], d distance.Distance) bool {
+		if pred == nil || pred(e.Value) {
+			nl = append(nl, e.Value)
+		}
+		return len(nl) < k
+	})
+	return nl
+}
+
+// Returns the closest position on a way for which pred returns true (all
+// indexed ways if pred is nil) within the given radius of p, false if
+// there is none.
+func (x *Index) NearestSegment(p *point.Point, radius distance.Distance, pred func(*way.Way) bool) (*Snap, bool) {
+	var snap *Snap
+	x.segments.Nearest(p, func(e Entry[Segment]) distance.Distance {
+		_, _, d := e.Value.Project(p)
+		return d
+	}, func(e Entry[Segment], d distance.Distance) bool {
+		if d > radius {
+			return false
+		}
+		if pred != nil && !pred(e.Value.Way) {
+			return true
+		}
+		q, at, d := e.Value.Project(p)
+		snap = &Snap{Segment: e.Value, Point: q, Distance: d, Offset: at}
+		for i := 0; i < e.Value.Index; i++ {
+			snap.Offset += e.Value.Way.Nodes_[i].Position_.DistanceOf(e.Value.Way.Nodes_[i+1].Position_)
+		}
+		return false
+	})
+	return snap, snap != nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
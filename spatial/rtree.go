@@ -0,0 +1,312 @@
+package spatial
+
+import (
+	"container/heap"
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/point"
+	"math"
+	"sort"
+)
+
+// the maximum number of children of a tree node
+const nodeCapacity = 16
+
+// a rectangle in lat/lon
+type Rect struct {
+	MinLat, MinLon, MaxLat, MaxLon float64
+}
+
+// returns the rectangle of a single point
+func PointRect(p *point.Point) Rect {
+	return Rect{p.Lat, p.Lon, p.Lat, p.Lon}
+}
+
+// returns the rectangle of a bounding box, a box crossing the antimeridian
+// is split into two rectangles
+func BBoxRects(b *bbox.BBox) []Rect {
+	if b.CrossesAntimeridian() {
+		return []Rect{
+			{b.LowerLeft.Lat, b.LowerLeft.Lon, b.UpperRight.Lat, 180},
+			{b.LowerLeft.Lat, -180, b.UpperRight.Lat, b.UpperRight.Lon},
+		}
+	}
+	return []Rect{{b.LowerLeft.Lat, b.LowerLeft.Lon, b.UpperRight.Lat, b.UpperRight.Lon}}
+}
+
+// returns the rectangle covering the great circle segment between both
+// points: the segment bulges towards the pole beyond the latitudes of its
+// ends, a segment crossing the antimeridian gets all longitudes
+func SegmentRect(p, q *point.Point) Rect {
+	r := Rect{math.Min(p.Lat, q.Lat), math.Min(p.Lon, q.Lon), math.Max(p.Lat, q.Lat), math.Max(p.Lon, q.Lon)}
+	if r.MaxLon-r.MinLon > 180 {
+		r.MinLon, r.MaxLon = -180, 180
+	}
+	a, b := cartesian(p), cartesian(q)
+	n := cross(a, b)
+	if l := math.Sqrt(dot(n, n)); l > 1e-12 {
+		// the northernmost point of the great circle and its antipode
+		z := [3]float64{0, 0, 1}
+		v := cross(cross(n, z), n)
+		if l = math.Sqrt(dot(v, v)); l > 1e-12 {
+			for _, sign := range []float64{1, -1} {
+				w := [3]float64{sign * v[0] / l, sign * v[1] / l, sign * v[2] / l}
+				if dot(cross(a, w), n) >= 0 && dot(cross(w, b), n) >= 0 {
+					lat := math.Asin(math.Max(-1, math.Min(w[2], 1))) * 180 / math.Pi
+					r.MinLat, r.MaxLat = math.Min(r.MinLat, lat), math.Max(r.MaxLat, lat)
+				}
+			}
+		}
+	}
+	return r
+}
+
+func cartesian(p *point.Point) [3]float64 {
+	lat, lon := p.Lat*math.Pi/180, p.Lon*math.Pi/180
+	return [3]float64{math.Cos(lat) * math.Cos(lon), math.Cos(lat) * math.Sin(lon), math.Sin(lat)}
+}
+
+func cross(a, b [3]float64) [3]float64 {
+	return [3]float64{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+func dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// returns a rectangle around p which contains all points within the
+// distance d of p, all longitudes if that circle contains a pole
+func RadiusRect(p *point.Point, d distance.Distance) Rect {
+	dlat := float64(d/distance.EarthRadius) * 180 / math.Pi
+	if p.Lat+dlat >= 90 || p.Lat-dlat <= -90 {
+		return Rect{math.Max(-90, p.Lat-dlat), -180, math.Min(90, p.Lat+dlat), 180}
+	}
+	// the widest longitude of the circle, at the points where the
+	// meridians touch it
+	dlon := math.Asin(math.Sin(dlat*math.Pi/180)/math.Cos(p.Lat*math.Pi/180)) * 180 / math.Pi
+	return Rect{p.Lat - dlat, p.Lon - dlon, p.Lat + dlat, p.Lon + dlon}
+}
+
+func (r Rect) Intersects(s Rect) bool {
+	return r.MinLat <= s.MaxLat && s.MinLat <= r.MaxLat && r.MinLon <= s.MaxLon && s.MinLon <= r.MaxLon
+}
+
+func (r Rect) extend(s Rect) Rect {
+	return Rect{math.Min(r.MinLat, s.MinLat), math.Min(r.MinLon, s.MinLon), math.Max(r.MaxLat, s.MaxLat), math.Max(r.MaxLon, s.MaxLon)}
+}
+
+func (r Rect) center() (float64, float64) {
+	return (r.MinLat + r.MaxLat) / 2, (r.MinLon + r.MaxLon) / 2
+}
+
+// The great circle distance from p to the closest point of the
+// rectangle (0 if p is inside). Within the longitudes of the rectangle
+// that point has the longitude of p. Otherwise it is on the nearer
+// meridian edge (over the antimeridian if that is shorter), where the
+// great circle through p perpendicular to the meridian meets it: near the
+// poles this is closer to the pole than p, clamping p to the rectangle
+// would overestimate the distance.
+func (r Rect) DistanceOf(p *point.Point) distance.Distance {
+	lat := math.Max(r.MinLat, math.Min(p.Lat, r.MaxLat))
+	if r.MinLon <= p.Lon && p.Lon <= r.MaxLon {
+		return distance.Distance(math.Abs(p.Lat-lat)*math.Pi/180) * distance.EarthRadius
+	}
+	lon := r.MinLon
+	if lonDiff(p.Lon, r.MaxLon) < lonDiff(p.Lon, r.MinLon) {
+		lon = r.MaxLon
+	}
+	// the closest point of the whole great circle of the meridian, beyond
+	// the poles for a difference in longitude of more than 90°
+	phi := p.Lat * math.Pi / 180
+	dl := lonDiff(p.Lon, lon) * math.Pi / 180
+	v := math.Atan2(math.Sin(phi), math.Cos(phi)*math.Cos(dl)) * 180 / math.Pi
+	if r.MinLat <= v && v <= r.MaxLat {
+		return p.DistanceOf(point.New(v, lon))
+	}
+	// the distance grows with the angle from v, either end of the edge
+	// may be closer
+	return min(p.DistanceOf(point.New(r.MinLat, lon)), p.DistanceOf(point.New(r.MaxLat, lon)))
+}
+
+// the difference of two longitudes in degrees, 0 to 180
+func lonDiff(a, b float64) float64 {
+	d := math.Abs(a - b)
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// an entry of the RTree
+type Entry[T any] struct {
+	Rect  Rect
+	Value T
+}
+
+type rnode[T any] struct {
+	rect     Rect
+	children []*rnode[T]
+	entries  []Entry[T]
+}
+
+// RTree is a static R-tree, bulk loaded with the Sort-Tile-Recursive
+// algorithm. It is safe for concurrent reads.
+type RTree[T any] struct {
+	root *rnode[T]
+	size int
+}
+
+// builds a new tree from the entries
+func NewRTree[T any](entries []Entry[T]) *RTree[T] {
+	t := &RTree[T]{size: len(entries)}
+	if len(entries) == 0 {
+		return t
+	}
+	e := append([]Entry[T](nil), entries...)
+	var level []*rnode[T]
+	tile(len(e), func(i, j int) bool {
+		ri, rj := e[i].Rect, e[j].Rect
+		return ri.MinLon+ri.MaxLon < rj.MinLon+rj.MaxLon
+	}, func(i, j int) bool {
+		ri, rj := e[i].Rect, e[j].Rect
+		return ri.MinLat+ri.MaxLat < rj.MinLat+rj.MaxLat
+	}, func(i, j int) { e[i], e[j] = e[j], e[i] }, func(from, to int) {
+		n := &rnode[T]{entries: e[from:to:to], rect: e[from].Rect}
+		for _, x := range n.entries[1:] {
+			n.rect = n.rect.extend(x.Rect)
+		}
+		level = append(level, n)
+	})
+	for len(level) > 1 {
+		l := level
+		var up []*rnode[T]
+		tile(len(l), func(i, j int) bool {
+			_, ci := l[i].rect.center()
+			_, cj := l[j].rect.center()
+			return ci < cj
+		}, func(i, j int) bool {
+			ci, _ := l[i].rect.center()
+			cj, _ := l[j].rect.center()
+			return ci < cj
+		}, func(i, j int) { l[i], l[j] = l[j], l[i] }, func(from, to int) {
+			n := &rnode[T]{children: l[from:to:to], rect: l[from].rect}
+			for _, c := range n.children[1:] {
+				n.rect = n.rect.extend(c.rect)
+			}
+			up = append(up, n)
+		})
+		level = up
+	}
+	t.root = level[0]
+	return t
+}
+
+type sorter struct {
+	n    int
+	less func(i, j int) bool
+	swap func(i, j int)
+	off  int
+}
+
+func (s sorter) Len() int           { return s.n }
+func (s sorter) Less(i, j int) bool { return s.less(s.off+i, s.off+j) }
+func (s sorter) Swap(i, j int)      { s.swap(s.off+i, s.off+j) }
+
+// sorts n items into vertical slices by lon, each slice by lat and calls
+// group for every run of at most nodeCapacity items
+func tile(n int, byLon, byLat func(i, j int) bool, swap func(i, j int), group func(from, to int)) {
+	sort.Sort(sorter{n: n, less: byLon, swap: swap})
+	leaves := (n + nodeCapacity - 1) / nodeCapacity
+	slices := int(math.Ceil(math.Sqrt(float64(leaves))))
+	per := slices * nodeCapacity
+	for s := 0; s < n; s += per {
+		e := s + per
+		if e > n {
+			e = n
+		}
+		sort.Sort(sorter{n: e - s, less: byLat, swap: swap, off: s})
+		for g := s; g < e; g += nodeCapacity {
+			ge := g + nodeCapacity
+			if ge > e {
+				ge = e
+			}
+			group(g, ge)
+		}
+	}
+}
+
+// the number of entries
+func (t *RTree[T]) Len() int {
+	return t.size
+}
+
+// calls fn for all entries intersecting r until fn returns false
+func (t *RTree[T]) Search(r Rect, fn func(Entry[T]) bool) {
+	if t.root != nil {
+		t.root.search(r, fn)
+	}
+}
+
+func (n *rnode[T]) search(r Rect, fn func(Entry[T]) bool) bool {
+	if !n.rect.Intersects(r) {
+		return true
+	}
+	for _, e := range n.entries {
+		if e.Rect.Intersects(r) && !fn(e) {
+			return false
+		}
+	}
+	for _, c := range n.children {
+		if !c.search(r, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+type queued[T any] struct {
+	dist  distance.Distance
+	node  *rnode[T]
+	entry *Entry[T]
+}
+
+type queue[T any] []queued[T]
+
+func (q queue[T]) Len() int            { return len(q) }
+func (q queue[T]) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q queue[T]) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue[T]) Push(x interface{}) { *q = append(*q, x.(queued[T])) }
+func (q *queue[T]) Pop() interface{} {
+	old := *q
+	x := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return x
+}
+
+// Calls fn for the entries ordered by their distance from p until fn
+// returns false. The distance of an entry is computed by dist, which must
+// never be less than the distance of p to the entry's rectangle.
+func (t *RTree[T]) Nearest(p *point.Point, dist func(Entry[T]) distance.Distance, fn func(Entry[T], distance.Distance) bool) {
+	if t.root == nil {
+		return
+	}
+	q := &queue[T]{{dist: t.root.rect.DistanceOf(p), node: t.root}}
+	for q.Len() > 0 {
+		x := heap.Pop(q).(queued[T])
+		if x.entry != nil {
+			if !fn(*x.entry, x.dist) {
+				return
+			}
+			continue
+		}
+		for i := range x.node.entries {
+			e := &x.node.entries[i]
+			heap.Push(q, queued[T]{dist: dist(*e), entry: e})
+		}
+		for _, c := range x.node.children {
+			heap.Push(q, queued[T]{dist: c.rect.DistanceOf(p), node: c})
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
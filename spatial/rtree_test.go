@@ -0,0 +1,143 @@
+package spatial
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"math"
+	"sort"
+	"testing"
+)
+
+func TestRectDistance(t *testing.T) {
+	for _, c := range []struct {
+		r    Rect
+		p    *point.Point
+		want distance.Distance // in m
+	}{
+		{Rect{10, 10, 20, 20}, point.New(15, 15), 0},
+		// 5° south along the meridian
+		{Rect{10, 10, 20, 20}, point.New(5, 15), 5 * math.Pi / 180 * 6371000},
+		// to the meridian 10° east the distance is asin(sin 10° cos 80°),
+		// the closest point is at 80.15°, clamping to 80° gives 192.85km
+		{Rect{70, 10, 89, 20}, point.New(80, 0), 192138.3},
+		// 2° over the antimeridian
+		{Rect{-1, -179, 1, -178}, point.New(0, 179), 2 * math.Pi / 180 * 6371000},
+		// more than 90° from the edge, the closest point is the pole
+		{Rect{60, 100, 90, 110}, point.New(60, -10), 30 * math.Pi / 180 * 6371000},
+	} {
+		if got := c.r.DistanceOf(c.p); math.Abs(float64(got-c.want)) > 1 {
+			t.Errorf("%v DistanceOf(%v) = %.1fm, want %.1fm", c.r, c.p, got, c.want)
+		}
+	}
+}
+
+// the distance to a rectangle is never more than the distance to any
+// point of it
+func TestRectDistanceLowerBound(t *testing.T) {
+	rects := []Rect{{70, 10, 89, 20}, {-85, -170, -60, 170}, {0, 179, 10, 180}, {80, -180, 90, 180}, {-10, -10, 10, 10}}
+	for _, r := range rects {
+		for lat := -88.0; lat <= 88; lat += 8 {
+			for lon := -180.0; lon < 180; lon += 15 {
+				p := point.New(lat, lon)
+				d := r.DistanceOf(p)
+				for i := 0; i <= 20; i++ {
+					for j := 0; j <= 20; j++ {
+						q := point.New(r.MinLat+(r.MaxLat-r.MinLat)*float64(i)/20, r.MinLon+(r.MaxLon-r.MinLon)*float64(j)/20)
+						if dq := p.DistanceOf(q); dq < d-0.001 {
+							t.Fatalf("%v DistanceOf(%v) = %.3fm, but %v is %.3fm away", r, p, d, q, dq)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// the great circle from 80°N 0° to 80°N 90°E reaches 82.9°N at 45°E,
+// atan(tan 80° / cos 45°)
+func TestSegmentRect(t *testing.T) {
+	r := SegmentRect(point.New(80, 0), point.New(80, 90))
+	if want := math.Atan(math.Tan(80*math.Pi/180)/math.Cos(45*math.Pi/180)) * 180 / math.Pi; math.Abs(r.MaxLat-want) > 1e-9 {
+		t.Errorf("segment reaches %.4f°, want %.4f°", r.MaxLat, want)
+	}
+	if r.MinLat != 80 {
+		t.Errorf("segment from %.4f°, want 80°", r.MinLat)
+	}
+	if r = SegmentRect(point.New(0, 179), point.New(1, -179)); r.MinLon != -180 || r.MaxLon != 180 {
+		t.Errorf("segment over the antimeridian in %v", r)
+	}
+}
+
+// a grid of nodes around the north pole and a query position
+func highLatitudeNodes() []*node.Node {
+	var nl []*node.Node
+	id := int64(1)
+	for lat := 70.0; lat < 90; lat += 2.5 {
+		for lon := -180.0; lon < 180; lon += 20 {
+			nl = append(nl, &node.Node{Id_: id, Position_: point.New(lat, lon), Tags_: tags.New()})
+			id++
+		}
+	}
+	return nl
+}
+
+func nodeIndex(nl []*node.Node) *Index {
+	var ne []Entry[*node.Node]
+	for _, n := range nl {
+		ne = append(ne, Entry[*node.Node]{Rect: PointRect(n.Position_), Value: n})
+	}
+	return &Index{nodes: NewRTree(ne), segments: NewRTree[Segment](nil)}
+}
+
+// the nearest nodes at high latitudes are the ones of a brute force search
+func TestNearestHighLatitude(t *testing.T) {
+	nl := highLatitudeNodes()
+	x := nodeIndex(nl)
+	for _, p := range []*point.Point{point.New(88.9, 5), point.New(86, 170), point.New(84, -95), point.New(75, 10)} {
+		got := x.NearestNodes(p, 5, nil)
+		want := append([]*node.Node(nil), nl...)
+		sort.Slice(want, func(i, j int) bool { return p.DistanceOf(want[i].Position_) < p.DistanceOf(want[j].Position_) })
+		for i := range got {
+			// nodes at the same distance may come in any order
+			if math.Abs(float64(p.DistanceOf(got[i].Position_)-p.DistanceOf(want[i].Position_))) > 0.001 {
+				t.Errorf("nearest node %d of %v is #%d at %.0fm, want #%d at %.0fm", i, p, got[i].Id_, p.DistanceOf(got[i].Position_), want[i].Id_, p.DistanceOf(want[i].Position_))
+			}
+		}
+	}
+}
+
+// long segments along the parallels, which bulge towards the pole
+func TestNearestSegmentHighLatitude(t *testing.T) {
+	var ways []*way.Way
+	id := int64(1)
+	for lat := 70.0; lat < 90; lat += 4 {
+		w := &way.Way{Id_: id, Tags_: tags.New()}
+		for lon := -180.0; lon < 180; lon += 90 {
+			w.Nodes_ = append(w.Nodes_, &node.Node{Position_: point.New(lat, lon)})
+		}
+		ways = append(ways, w)
+		id++
+	}
+	var se []Entry[Segment]
+	for _, w := range ways {
+		se = appendSegments(se, w)
+	}
+	x := &Index{nodes: NewRTree[*node.Node](nil), segments: NewRTree(se)}
+	for _, p := range []*point.Point{point.New(89, 45), point.New(87, -135), point.New(84.5, 45), point.New(71, 0)} {
+		var want distance.Distance = -1
+		for _, e := range se {
+			if _, _, d := e.Value.Project(p); want < 0 || d < want {
+				want = d
+			}
+		}
+		s, ok := x.NearestSegment(p, 1000000, nil)
+		if !ok || math.Abs(float64(s.Distance-want)) > 0.001 {
+			t.Errorf("nearest segment of %v at %v, want %.3fm", p, s, want)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
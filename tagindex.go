@@ -0,0 +1,135 @@
+package osm
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tagindex"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+)
+
+// builds the tag index of all nodes, ways and relations and stores it as
+// o.TagIndex. The index is kept up to date by AddNode(), AddWay(),
+// AddRelation() and SetTags(), changes made directly to the maps or to
+// the items are not seen by the index. Tags changed in place are indexed
+// by passing them to SetTags(), e.g.
+//
+//	t := n.Tags()
+//	t.Delete("fixme")
+//	o.SetTags(n, t)
+func (o *OSM) BuildTagIndex() *tagindex.Index {
+	x := tagindex.New()
+	for _, n := range o.Nodes {
+		x.Add(n)
+	}
+	if o.NodeStore != nil {
+		o.NodeStore.Each(func(n *node.Node) bool {
+			if _, ok := o.Nodes[n.Id_]; !ok && n.Tags_.Length() != 0 {
+				x.Add(n)
+			}
+			return true
+		})
+	}
+	for _, w := range o.Ways {
+		x.Add(w)
+	}
+	for _, r := range o.Relations {
+		x.Add(r)
+	}
+	o.TagIndex = x
+	return x
+}
+
+func (o *OSM) tagIndex() *tagindex.Index {
+	if o.TagIndex == nil {
+		return o.BuildTagIndex()
+	}
+	return o.TagIndex
+}
+
+// sets the tags of the item i and updates the tag index
+func (o *OSM) SetTags(i item.Item, t *tags.Tags) {
+	switch i.(type) {
+	case *node.Node:
+		n := i.(*node.Node)
+		n.SetTags(t)
+		if o.NodeStore != nil {
+			if _, ok := o.Nodes[n.Id_]; !ok {
+				o.NodeStore.Put(n)
+			}
+		}
+	case *way.Way:
+		i.(*way.Way).SetTags(t)
+	case *relation.Relation:
+		i.(*relation.Relation).SetTags(t)
+	}
+	if o.TagIndex != nil {
+		o.TagIndex.Update(i)
+	}
+}
+
+func (o *OSM) nodeList(ids []int64) *node.NodeList {
+	var nl []*node.Node
+	for _, id := range ids {
+		if n := o.GetNode(id); n != nil {
+			nl = append(nl, n)
+		}
+	}
+	nlist := node.NodeList(nl)
+	return &nlist
+}
+
+func (o *OSM) wayList(ids []int64) *way.WayList {
+	var wl []*way.Way
+	for _, id := range ids {
+		if w := o.GetWay(id); w != nil {
+			wl = append(wl, w)
+		}
+	}
+	wlist := way.WayList(wl)
+	return &wlist
+}
+
+func (o *OSM) relationList(ids []int64) *relation.RelationList {
+	var rl []*relation.Relation
+	for _, id := range ids {
+		if r := o.GetRelation(id); r != nil {
+			rl = append(rl, r)
+		}
+	}
+	rlist := relation.RelationList(rl)
+	return &rlist
+}
+
+// returns all nodes having the key k, the tag index is built if needed
+func (o *OSM) NodesWithKey(k string) *node.NodeList {
+	return o.nodeList(o.tagIndex().Key(item.TypeNode, k))
+}
+
+// returns all nodes with the tag k=v, the tag index is built if needed
+func (o *OSM) NodesWithTag(k, v string) *node.NodeList {
+	return o.nodeList(o.tagIndex().Tag(item.TypeNode, k, v))
+}
+
+// returns all ways having the key k, the tag index is built if needed
+func (o *OSM) WaysWithKey(k string) *way.WayList {
+	return o.wayList(o.tagIndex().Key(item.TypeWay, k))
+}
+
+// returns all ways with the tag k=v, the tag index is built if needed
+func (o *OSM) WaysWithTag(k, v string) *way.WayList {
+	return o.wayList(o.tagIndex().Tag(item.TypeWay, k, v))
+}
+
+// returns all relations having the key k, the tag index is built if needed
+func (o *OSM) RelationsWithKey(k string) *relation.RelationList {
+	return o.relationList(o.tagIndex().Key(item.TypeRelation, k))
+}
+
+// returns all relations with the tag k=v, the tag index is built if needed
+func (o *OSM) RelationsWithTag(k, v string) *relation.RelationList {
+	return o.relationList(o.tagIndex().Tag(item.TypeRelation, k, v))
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
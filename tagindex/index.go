@@ -0,0 +1,245 @@
+package tagindex
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/tags"
+	"sort"
+)
+
+// the ids of all items with a given key=value pair
+type entry struct {
+	nodes     map[int64]struct{}
+	ways      map[int64]struct{}
+	relations map[int64]struct{}
+}
+
+func newEntry() *entry {
+	return &entry{
+		nodes:     make(map[int64]struct{}),
+		ways:      make(map[int64]struct{}),
+		relations: make(map[int64]struct{}),
+	}
+}
+
+func (e *entry) set(t item.ItemType) map[int64]struct{} {
+	switch t {
+	case item.TypeNode:
+		return e.nodes
+	case item.TypeWay:
+		return e.ways
+	case item.TypeRelation:
+		return e.relations
+	}
+	return nil
+}
+
+func (e *entry) empty() bool {
+	return len(e.nodes) == 0 && len(e.ways) == 0 && len(e.relations) == 0
+}
+
+// Index is an inverted tag index: key -> value -> item ids. It does not
+// hold the items, only their type and id and a copy of the tags they had
+// when they were indexed. The copy is used to remove the item, so the
+// index is right even if the tags of an item were changed in place before
+// Remove() or Update().
+type Index struct {
+	keys    map[string]map[string]*entry
+	indexed map[item.ItemType]map[int64]*tags.Tags
+}
+
+// Statistics for a key (Value is empty then) or a key=value pair
+type Stat struct {
+	Key       string
+	Value     string
+	Nodes     int
+	Ways      int
+	Relations int
+}
+
+func (s Stat) Total() int {
+	return s.Nodes + s.Ways + s.Relations
+}
+
+func New() *Index {
+	return &Index{keys: make(map[string]map[string]*entry), indexed: make(map[item.ItemType]map[int64]*tags.Tags)}
+}
+
+// adds all tags of the item i to the index, an item which is already in
+// the index is updated
+func (x *Index) Add(i item.Item) {
+	x.remove(i.Type(), i.Id())
+	x.add(i.Type(), i.Id(), i.Tags())
+}
+
+// removes the item i from the index
+func (x *Index) Remove(i item.Item) {
+	x.remove(i.Type(), i.Id())
+}
+
+// updates the index after the tags of item i were replaced or changed in
+// place
+func (x *Index) Update(i item.Item) {
+	x.Add(i)
+}
+
+func (x *Index) add(t item.ItemType, id int64, tl *tags.Tags) {
+	if tl == nil || len(*tl) == 0 {
+		return
+	}
+	ids, ok := x.indexed[t]
+	if !ok {
+		ids = make(map[int64]*tags.Tags)
+		x.indexed[t] = ids
+	}
+	ids[id] = tl.Clone()
+	for k, v := range *tl {
+		values, ok := x.keys[k]
+		if !ok {
+			values = make(map[string]*entry)
+			x.keys[k] = values
+		}
+		e, ok := values[v]
+		if !ok {
+			e = newEntry()
+			values[v] = e
+		}
+		e.set(t)[id] = struct{}{}
+	}
+}
+
+func (x *Index) remove(t item.ItemType, id int64) {
+	tl := x.indexed[t][id]
+	if tl == nil {
+		return
+	}
+	delete(x.indexed[t], id)
+	for k, v := range *tl {
+		e := x.keys[k][v]
+		if e == nil {
+			continue
+		}
+		delete(e.set(t), id)
+		if e.empty() {
+			delete(x.keys[k], v)
+			if len(x.keys[k]) == 0 {
+				delete(x.keys, k)
+			}
+		}
+	}
+}
+
+func sortedIds(m map[int64]struct{}) []int64 {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// returns a copy of the tags the item of type t with the given id had
+// when it was indexed, nil if it is not in the index (items without tags
+// are not indexed)
+func (x *Index) Tags(t item.ItemType, id int64) *tags.Tags {
+	tl := x.indexed[t][id]
+	if tl == nil {
+		return nil
+	}
+	return tl.Clone()
+}
+
+// returns the ids of all items of type t with the tag k=v
+func (x *Index) Tag(t item.ItemType, k, v string) []int64 {
+	e := x.keys[k][v]
+	if e == nil {
+		return nil
+	}
+	return sortedIds(e.set(t))
+}
+
+// returns the ids of all items of type t having the key k
+func (x *Index) Key(t item.ItemType, k string) []int64 {
+	all := make(map[int64]struct{})
+	for _, e := range x.keys[k] {
+		for id := range e.set(t) {
+			all[id] = struct{}{}
+		}
+	}
+	return sortedIds(all)
+}
+
+// returns all indexed keys, sorted
+func (x *Index) Keys() []string {
+	keys := make([]string, 0, len(x.keys))
+	for k := range x.keys {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// returns all values of the key k, sorted
+func (x *Index) Values(k string) []string {
+	values := make([]string, 0, len(x.keys[k]))
+	for v := range x.keys[k] {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// returns the statistics for the key k=v
+func (x *Index) TagStat(k, v string) Stat {
+	s := Stat{Key: k, Value: v}
+	if e := x.keys[k][v]; e != nil {
+		s.Nodes, s.Ways, s.Relations = len(e.nodes), len(e.ways), len(e.relations)
+	}
+	return s
+}
+
+// returns the statistics for the key k, an item is counted once even if
+// the key is there with different values (which cannot happen for
+// items with valid tags)
+func (x *Index) KeyStat(k string) Stat {
+	s := Stat{Key: k}
+	for _, e := range x.keys[k] {
+		s.Nodes += len(e.nodes)
+		s.Ways += len(e.ways)
+		s.Relations += len(e.relations)
+	}
+	return s
+}
+
+// returns the statistics of all keys, most used keys first
+func (x *Index) KeyStats() []Stat {
+	stats := make([]Stat, 0, len(x.keys))
+	for k := range x.keys {
+		stats = append(stats, x.KeyStat(k))
+	}
+	sortStats(stats)
+	return stats
+}
+
+// returns the statistics of all values of the key k, most used first
+func (x *Index) ValueStats(k string) []Stat {
+	stats := make([]Stat, 0, len(x.keys[k]))
+	for v := range x.keys[k] {
+		stats = append(stats, x.TagStat(k, v))
+	}
+	sortStats(stats)
+	return stats
+}
+
+func sortStats(stats []Stat) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Total() != stats[j].Total() {
+			return stats[i].Total() > stats[j].Total()
+		}
+		if stats[i].Key != stats[j].Key {
+			return stats[i].Key < stats[j].Key
+		}
+		return stats[i].Value < stats[j].Value
+	})
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,148 @@
+package tagindex
+
+import (
+	"github.com/brechtvm/osm/item"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"github.com/brechtvm/osm/way"
+	"reflect"
+	"testing"
+)
+
+func tagsOf(kv ...string) *tags.Tags {
+	t := tags.New()
+	for i := 0; i+1 < len(kv); i += 2 {
+		t.Add(kv[i], kv[i+1])
+	}
+	return t
+}
+
+// three benches and a waste basket, two roads with names and a route
+func testIndex() (*Index, []*node.Node) {
+	x := New()
+	nodes := []*node.Node{
+		{Id_: 3, Tags_: tagsOf("amenity", "bench")},
+		{Id_: 1, Tags_: tagsOf("amenity", "bench", "backrest", "no")},
+		{Id_: 2, Tags_: tagsOf("amenity", "waste_basket")},
+		{Id_: -1, Tags_: tagsOf("amenity", "bench")},
+		{Id_: 4, Tags_: tags.New()},
+	}
+	for _, n := range nodes {
+		x.Add(n)
+	}
+	x.Add(&way.Way{Id_: 10, Tags_: tagsOf("highway", "residential", "name", "Kerkstraat")})
+	x.Add(&way.Way{Id_: 11, Tags_: tagsOf("highway", "primary", "name", "Kerkstraat")})
+	x.Add(&relation.Relation{Id_: 20, Tags_: tagsOf("type", "route", "name", "Kerkstraat")})
+	return x, nodes
+}
+
+func TestQueries(t *testing.T) {
+	x, _ := testIndex()
+	for _, c := range []struct {
+		got, want []int64
+	}{
+		{x.Tag(item.TypeNode, "amenity", "bench"), []int64{-1, 1, 3}},
+		{x.Tag(item.TypeNode, "amenity", "shelter"), nil},
+		{x.Tag(item.TypeWay, "amenity", "bench"), []int64{}},
+		{x.Key(item.TypeNode, "amenity"), []int64{-1, 1, 2, 3}},
+		{x.Key(item.TypeWay, "name"), []int64{10, 11}},
+		{x.Key(item.TypeRelation, "name"), []int64{20}},
+		{x.Key(item.TypeNode, "name"), []int64{}},
+		{x.Key(item.TypeNode, "fixme"), []int64{}},
+	} {
+		if len(c.got) != len(c.want) || len(c.got) > 0 && !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("ids %v, want %v", c.got, c.want)
+		}
+	}
+	if k := x.Keys(); !reflect.DeepEqual(k, []string{"amenity", "backrest", "highway", "name", "type"}) {
+		t.Errorf("keys %v", k)
+	}
+	if v := x.Values("highway"); !reflect.DeepEqual(v, []string{"primary", "residential"}) {
+		t.Errorf("values of highway %v", v)
+	}
+	if v := x.Values("fixme"); len(v) != 0 {
+		t.Errorf("values of fixme %v", v)
+	}
+}
+
+func TestStats(t *testing.T) {
+	x, _ := testIndex()
+	if s := x.TagStat("amenity", "bench"); s != (Stat{"amenity", "bench", 3, 0, 0}) || s.Total() != 3 {
+		t.Errorf("stat of amenity=bench %+v", s)
+	}
+	if s := x.TagStat("name", "Kerkstraat"); s != (Stat{"name", "Kerkstraat", 0, 2, 1}) || s.Total() != 3 {
+		t.Errorf("stat of name=Kerkstraat %+v", s)
+	}
+	if s := x.TagStat("amenity", "shelter"); s.Total() != 0 {
+		t.Errorf("stat of amenity=shelter %+v", s)
+	}
+	if s := x.KeyStat("highway"); s != (Stat{Key: "highway", Ways: 2}) {
+		t.Errorf("stat of highway %+v", s)
+	}
+	// most used first, then by key
+	want := []Stat{
+		{Key: "amenity", Nodes: 4},
+		{Key: "name", Ways: 2, Relations: 1},
+		{Key: "highway", Ways: 2},
+		{Key: "backrest", Nodes: 1},
+		{Key: "type", Relations: 1},
+	}
+	if s := x.KeyStats(); !reflect.DeepEqual(s, want) {
+		t.Errorf("key stats %+v, want %+v", s, want)
+	}
+	want = []Stat{{"amenity", "bench", 3, 0, 0}, {"amenity", "waste_basket", 1, 0, 0}}
+	if s := x.ValueStats("amenity"); !reflect.DeepEqual(s, want) {
+		t.Errorf("value stats %+v, want %+v", s, want)
+	}
+	want = []Stat{{"highway", "primary", 0, 1, 0}, {"highway", "residential", 0, 1, 0}}
+	if s := x.ValueStats("highway"); !reflect.DeepEqual(s, want) {
+		t.Errorf("value stats %+v, want %+v", s, want)
+	}
+}
+
+// the tags of node 1 and 3 are changed in place before they are updated
+// and removed, node 2 is updated without tags
+func TestUpdateInPlace(t *testing.T) {
+	x, nodes := testIndex()
+	n3, n1, n2 := nodes[0], nodes[1], nodes[2]
+	n1.Tags_.Delete("backrest")
+	n1.Tags_.Add("amenity", "shelter")
+	x.Update(n1)
+	n3.Tags_.Add("amenity", "waste_basket")
+	x.Remove(n3)
+	n2.Tags_ = tags.New()
+	x.Update(n2)
+
+	if ids := x.Tag(item.TypeNode, "amenity", "bench"); !reflect.DeepEqual(ids, []int64{-1}) {
+		t.Errorf("benches %v, want -1", ids)
+	}
+	if ids := x.Tag(item.TypeNode, "amenity", "shelter"); !reflect.DeepEqual(ids, []int64{1}) {
+		t.Errorf("shelters %v, want 1", ids)
+	}
+	if ids := x.Tag(item.TypeNode, "amenity", "waste_basket"); len(ids) != 0 {
+		t.Errorf("waste baskets %v, want none", ids)
+	}
+	if tl := x.Tags(item.TypeNode, 1); tl == nil || len(*tl) != 1 || tl.Get("amenity") != "shelter" {
+		t.Errorf("indexed tags of node 1 %v", tl)
+	}
+	if tl := x.Tags(item.TypeNode, 2); tl != nil {
+		t.Errorf("indexed tags of node 2 without tags %v", tl)
+	}
+	// unused keys and values are dropped
+	if k := x.Keys(); !reflect.DeepEqual(k, []string{"amenity", "highway", "name", "type"}) {
+		t.Errorf("keys %v", k)
+	}
+	if v := x.Values("amenity"); !reflect.DeepEqual(v, []string{"bench", "shelter"}) {
+		t.Errorf("values of amenity %v", v)
+	}
+	// removing twice or removing an item which was never added
+	x.Remove(n3)
+	x.Remove(nodes[4])
+	x.Remove(&node.Node{Id_: 10, Tags_: tagsOf("highway", "residential")})
+	if s := x.KeyStat("highway"); s.Ways != 2 || s.Nodes != 0 {
+		t.Errorf("stat of highway %+v after removing a node 10", s)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
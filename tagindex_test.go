@@ -0,0 +1,96 @@
+package osm
+
+import (
+	"github.com/brechtvm/osm/relation"
+	"github.com/brechtvm/osm/tags"
+	"testing"
+)
+
+// the tags of an item are changed in place and passed back to SetTags()
+func TestSetTagsInPlace(t *testing.T) {
+	for _, o := range []*OSM{NewOSM(nil), NewCompactOSM(nil)} {
+		o.AddNode(testNode(1, 50, 4, "amenity", "bench", "fixme", "check"))
+		o.AddWay(testWay(10, []int64{1}, "highway", "residential"))
+		o.BuildTagIndex()
+
+		n := o.GetNode(1)
+		nt := n.Tags()
+		nt.Delete("fixme")
+		nt.Add("amenity", "waste_basket")
+		o.SetTags(n, nt)
+		if got := o.NodesWithKey("fixme"); got.Len() != 0 {
+			t.Errorf("%d nodes with fixme after deleting it in place, want 0", got.Len())
+		}
+		if got := o.NodesWithTag("amenity", "bench"); got.Len() != 0 {
+			t.Errorf("%d benches after changing the value in place, want 0", got.Len())
+		}
+		if got := o.NodesWithTag("amenity", "waste_basket"); got.Len() != 1 {
+			t.Errorf("%d waste baskets, want 1", got.Len())
+		}
+
+		w := o.Ways[10]
+		w.Tags().Add("highway", "service")
+		o.SetTags(w, w.Tags())
+		if o.WaysWithTag("highway", "residential").Len() != 0 || o.WaysWithTag("highway", "service").Len() != 1 {
+			t.Errorf("way 10 not reindexed after an in place change")
+		}
+
+		o.SetTags(w, tags.New())
+		if o.WaysWithKey("highway").Len() != 0 {
+			t.Errorf("way 10 still indexed after removing its tags")
+		}
+	}
+}
+
+// the queries of all item types, with an index built on the first query
+// and kept up to date when items are replaced
+func TestTagQueries(t *testing.T) {
+	for _, o := range []*OSM{NewOSM(nil), NewCompactOSM(nil)} {
+		o.AddNode(testNode(1, 50, 4, "amenity", "bench"))
+		o.AddNode(testNode(2, 50, 4, "amenity", "waste_basket"))
+		o.AddNode(testNode(3, 50, 4))
+		o.AddWay(testWay(10, []int64{1, 2}, "highway", "residential"))
+		o.AddWay(testWay(11, []int64{2, 3}, "highway", "service", "name", "x"))
+		o.AddRelation(&relation.Relation{Id_: 20, Tags_: &tags.Tags{"type": "route", "name": "x"}})
+		if o.TagIndex != nil {
+			t.Fatal("tag index built before the first query")
+		}
+		if got := o.NodesWithKey("amenity"); got.Len() != 2 {
+			t.Errorf("%d nodes with amenity, want 2", got.Len())
+		}
+		if o.TagIndex == nil {
+			t.Fatal("tag index not kept after the first query")
+		}
+		if got := o.NodesWithTag("amenity", "bench"); got.Len() != 1 || (*got)[0] != o.GetNode(1) {
+			t.Errorf("benches %v, want node 1", got)
+		}
+		if got := o.WaysWithKey("highway"); got.Len() != 2 {
+			t.Errorf("%d ways with highway, want 2", got.Len())
+		}
+		if got := o.WaysWithTag("name", "x"); got.Len() != 1 || (*got)[0] != o.Ways[11] {
+			t.Errorf("ways named x %v, want way 11", got)
+		}
+		if got := o.RelationsWithKey("name"); got.Len() != 1 || (*got)[0] != o.Relations[20] {
+			t.Errorf("relations with name %v, want relation 20", got)
+		}
+		if got := o.RelationsWithTag("type", "multipolygon"); got.Len() != 0 {
+			t.Errorf("%d multipolygons, want 0", got.Len())
+		}
+
+		// replaced items are indexed with their new tags only
+		o.AddNode(testNode(1, 50, 4, "amenity", "shelter"))
+		o.AddWay(testWay(11, []int64{2, 3}, "highway", "service"))
+		o.AddRelation(&relation.Relation{Id_: 20, Tags_: &tags.Tags{"type": "multipolygon"}})
+		if o.NodesWithTag("amenity", "bench").Len() != 0 || o.NodesWithTag("amenity", "shelter").Len() != 1 {
+			t.Errorf("node 1 not reindexed after replacing it")
+		}
+		if o.WaysWithKey("name").Len() != 0 || o.RelationsWithKey("name").Len() != 0 {
+			t.Errorf("replaced way or relation still indexed by name")
+		}
+		if s := o.TagIndex.KeyStat("type"); s.Relations != 1 || o.RelationsWithTag("type", "multipolygon").Len() != 1 {
+			t.Errorf("relation 20 not reindexed after replacing it: %+v", s)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
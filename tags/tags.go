@@ -12,6 +12,18 @@ func New() *Tags {
 	return &tm
 }
 
+// returns a copy of the *Tags
+func (t *Tags) Clone() *Tags {
+	if t == nil {
+		return nil
+	}
+	c := New()
+	for k, v := range *t {
+		(*c)[k] = v
+	}
+	return c
+}
+
 // deletes the named key from the *Tags
 func (t *Tags) Delete(k string) {
 	if t == nil {
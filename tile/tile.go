@@ -0,0 +1,227 @@
+// Package tile implements the slippy map tile scheme of the OSM tile
+// servers (z/x/y in web mercator) and Bing style quadkeys.
+package tile
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/way"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// the highest supported zoom level
+const MaxZoom = 30
+
+type Tile struct {
+	Z, X, Y int
+}
+
+// returns the tile containing p at the given zoom level
+func At(p *point.Point, zoom int) Tile {
+	x, y := p.TileXY(zoom)
+	return Tile{Z: zoom, X: x, Y: y}
+}
+
+// parses "z/x/y"
+func Parse(s string) (Tile, error) {
+	f := strings.Split(s, "/")
+	if len(f) != 3 {
+		return Tile{}, errors.New(fmt.Sprintf("Invalid tile %q", s))
+	}
+	var v [3]int
+	for i := range f {
+		n, err := strconv.Atoi(f[i])
+		if err != nil {
+			return Tile{}, errors.New(fmt.Sprintf("Invalid tile %q", s))
+		}
+		v[i] = n
+	}
+	t := Tile{Z: v[0], X: v[1], Y: v[2]}
+	if !t.Valid() {
+		return Tile{}, errors.New(fmt.Sprintf("Invalid tile %q", s))
+	}
+	return t, nil
+}
+
+// "z/x/y"
+func (t Tile) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// checks if the zoom level and the coordinates are in range
+func (t Tile) Valid() bool {
+	if t.Z < 0 || t.Z > MaxZoom {
+		return false
+	}
+	n := 1 << uint(t.Z)
+	return t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+// returns the latitude of the northern edge of row y
+func rowLat(y, z int) float64 {
+	n := math.Pi - 2*math.Pi*float64(y)/math.Exp2(float64(z))
+	return math.Atan(math.Sinh(n)) * 180 / math.Pi
+}
+
+func colLon(x, z int) float64 {
+	return float64(x)/math.Exp2(float64(z))*360 - 180
+}
+
+// the area covered by the tile
+func (t Tile) BBox() *bbox.BBox {
+	return &bbox.BBox{
+		LowerLeft:  point.New(rowLat(t.Y+1, t.Z), colLon(t.X, t.Z)),
+		UpperRight: point.New(rowLat(t.Y, t.Z), colLon(t.X+1, t.Z)),
+	}
+}
+
+// the tile one zoom level up containing t, t itself at zoom level 0
+func (t Tile) Parent() Tile {
+	if t.Z == 0 {
+		return t
+	}
+	return Tile{Z: t.Z - 1, X: t.X / 2, Y: t.Y / 2}
+}
+
+// the 4 tiles at the next zoom level covering t
+func (t Tile) Children() []Tile {
+	x, y, z := t.X*2, t.Y*2, t.Z+1
+	return []Tile{{z, x, y}, {z, x + 1, y}, {z, x, y + 1}, {z, x + 1, y + 1}}
+}
+
+// the quadkey of the tile, empty at zoom level 0
+func (t Tile) Quadkey() string {
+	var sb strings.Builder
+	for i := t.Z; i > 0; i-- {
+		d := byte('0')
+		mask := 1 << uint(i-1)
+		if t.X&mask != 0 {
+			d++
+		}
+		if t.Y&mask != 0 {
+			d += 2
+		}
+		sb.WriteByte(d)
+	}
+	return sb.String()
+}
+
+// returns the tile of a quadkey
+func FromQuadkey(q string) (Tile, error) {
+	if len(q) > MaxZoom {
+		return Tile{}, errors.New(fmt.Sprintf("Quadkey %q too long", q))
+	}
+	t := Tile{Z: len(q)}
+	for i, c := range q {
+		mask := 1 << uint(len(q)-i-1)
+		switch c {
+		case '0':
+		case '1':
+			t.X |= mask
+		case '2':
+			t.Y |= mask
+		case '3':
+			t.X |= mask
+			t.Y |= mask
+		default:
+			return Tile{}, errors.New(fmt.Sprintf("Invalid character %q in quadkey %q", c, q))
+		}
+	}
+	return t, nil
+}
+
+// returns all tiles at the zoom level covering the bounding box, row by
+// row from north-west to south-east (wrapping around for a box crossing
+// the antimeridian)
+func Covering(b *bbox.BBox, zoom int) []Tile {
+	x0, y0 := point.New(b.UpperRight.Lat, b.LowerLeft.Lon).TileXY(zoom)
+	x1, y1 := point.New(b.LowerLeft.Lat, b.UpperRight.Lon).TileXY(zoom)
+	n := 1 << uint(zoom)
+	if x1 < x0 {
+		x1 += n
+	}
+	var tl []Tile
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			tl = append(tl, Tile{Z: zoom, X: x % n, Y: y})
+		}
+	}
+	return tl
+}
+
+// Returns the tiles at the zoom level touched by the way, in the order
+// of the way. The segments are straight lines in web mercator, as drawn
+// on the map.
+func CoveringWay(w *way.Way, zoom int) []Tile {
+	var tl []Tile
+	seen := make(map[Tile]bool)
+	add := func(x, y int) {
+		max := 1<<uint(zoom) - 1
+		x = int(math.Max(0, math.Min(float64(max), float64(x))))
+		y = int(math.Max(0, math.Min(float64(max), float64(y))))
+		t := Tile{Z: zoom, X: x, Y: y}
+		if !seen[t] {
+			seen[t] = true
+			tl = append(tl, t)
+		}
+	}
+	for i, n := range w.Nodes_ {
+		ax, ay := n.Position_.TileCoords(zoom)
+		if i == len(w.Nodes_)-1 || len(w.Nodes_) == 1 {
+			add(int(math.Floor(ax)), int(math.Floor(ay)))
+			continue
+		}
+		bx, by := w.Nodes_[i+1].Position_.TileCoords(zoom)
+		// grid traversal (Amanatides & Woo)
+		x, y := int(math.Floor(ax)), int(math.Floor(ay))
+		ex, ey := int(math.Floor(bx)), int(math.Floor(by))
+		dx, dy := bx-ax, by-ay
+		sx, sy := 1, 1
+		if dx < 0 {
+			sx = -1
+		}
+		if dy < 0 {
+			sy = -1
+		}
+		tMaxX, tMaxY := math.Inf(1), math.Inf(1)
+		tDeltaX, tDeltaY := math.Inf(1), math.Inf(1)
+		if dx != 0 {
+			next := float64(x)
+			if sx > 0 {
+				next++
+			}
+			tMaxX = (next - ax) / dx
+			tDeltaX = float64(sx) / dx
+		}
+		if dy != 0 {
+			next := float64(y)
+			if sy > 0 {
+				next++
+			}
+			tMaxY = (next - ay) / dy
+			tDeltaY = float64(sy) / dy
+		}
+		add(x, y)
+		for x != ex || y != ey {
+			if tMaxX < tMaxY {
+				x += sx
+				tMaxX += tDeltaX
+			} else {
+				y += sy
+				tMaxY += tDeltaY
+			}
+			if tMaxX > 1 && tMaxY > 1 && (x != ex || y != ey) {
+				// rounding, jump to the end tile
+				x, y = ex, ey
+			}
+			add(x, y)
+		}
+	}
+	return tl
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
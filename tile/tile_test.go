@@ -0,0 +1,156 @@
+package tile
+
+import (
+	"github.com/brechtvm/osm/bbox"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/way"
+	"math"
+	"testing"
+)
+
+func sameTiles(a, b []Tile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestParse(t *testing.T) {
+	tl, err := Parse("10/524/343")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tl != At(point.New(50.8466, 4.3528), 10) || tl.String() != "10/524/343" {
+		t.Errorf("parsed %v, want the tile of Brussels at zoom 10", tl)
+	}
+	for _, s := range []string{"", "1/0", "1/0/0/0", "a/0/0", "1/2/0", "1/0/-1", "-1/0/0", "31/0/0"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("no error for tile %q", s)
+		}
+	}
+}
+
+func TestBBox(t *testing.T) {
+	const maxLat = 85.05112877980659
+	for _, c := range []struct {
+		t                              Tile
+		minLat, minLon, maxLat, maxLon float64
+	}{
+		{Tile{0, 0, 0}, -maxLat, -180, maxLat, 180},
+		{Tile{1, 0, 0}, 0, -180, maxLat, 0},
+		{Tile{1, 1, 1}, -maxLat, 0, 0, 180},
+		// row 1 at zoom 2 reaches from the equator to atan(sinh(π/2))
+		{Tile{2, 2, 1}, 0, 0, 66.51326044311186, 90},
+	} {
+		b := c.t.BBox()
+		if math.Abs(b.LowerLeft.Lat-c.minLat) > 1e-9 || b.LowerLeft.Lon != c.minLon ||
+			math.Abs(b.UpperRight.Lat-c.maxLat) > 1e-9 || b.UpperRight.Lon != c.maxLon {
+			t.Errorf("box of %v is %s, want %v,%v %v,%v", c.t, b.Format(), c.minLat, c.minLon, c.maxLat, c.maxLon)
+		}
+		// the center is in the tile
+		if got := At(b.Center(), c.t.Z); got != c.t {
+			t.Errorf("center of %v in %v", c.t, got)
+		}
+	}
+}
+
+func TestParentChildren(t *testing.T) {
+	tl := Tile{10, 524, 343}
+	if p := tl.Parent(); p != (Tile{9, 262, 171}) {
+		t.Errorf("parent of %v is %v, want 9/262/171", tl, p)
+	}
+	if p := (Tile{}).Parent(); p != (Tile{}) {
+		t.Errorf("parent of 0/0/0 is %v", p)
+	}
+	want := []Tile{{11, 1048, 686}, {11, 1049, 686}, {11, 1048, 687}, {11, 1049, 687}}
+	if c := tl.Children(); !sameTiles(c, want) {
+		t.Errorf("children of %v are %v, want %v", tl, c, want)
+	}
+	for _, c := range tl.Children() {
+		if c.Parent() != tl {
+			t.Errorf("parent of child %v is %v", c, c.Parent())
+		}
+	}
+}
+
+// the example of the Bing Maps tile system: tile 3,5 at level 3 is 213
+func TestQuadkey(t *testing.T) {
+	for _, c := range []struct {
+		t Tile
+		q string
+	}{
+		{Tile{3, 3, 5}, "213"},
+		{Tile{0, 0, 0}, ""},
+		{Tile{1, 1, 0}, "1"},
+		{Tile{2, 3, 3}, "33"},
+		{Tile{10, 524, 343}, "1202021322"},
+	} {
+		if q := c.t.Quadkey(); q != c.q {
+			t.Errorf("quadkey of %v is %q, want %q", c.t, q, c.q)
+		}
+		if tl, err := FromQuadkey(c.q); err != nil || tl != c.t {
+			t.Errorf("tile of quadkey %q is %v %v, want %v", c.q, tl, err, c.t)
+		}
+	}
+	for _, q := range []string{"214", "0123012301230123012301230123012"} {
+		if _, err := FromQuadkey(q); err == nil {
+			t.Errorf("no error for quadkey %q", q)
+		}
+	}
+}
+
+func TestCovering(t *testing.T) {
+	for _, c := range []struct {
+		b    *bbox.BBox
+		zoom int
+		want []Tile
+	}{
+		{bbox.New(50.8, 4.3, 50.9, 4.4), 10, []Tile{{10, 524, 343}}},
+		{bbox.New(-10, -10, 10, 10), 1, []Tile{{1, 0, 0}, {1, 1, 0}, {1, 0, 1}, {1, 1, 1}}},
+		// over the antimeridian, from west to east
+		{bbox.New(-10, 170, 10, -170), 2, []Tile{{2, 3, 1}, {2, 0, 1}, {2, 3, 2}, {2, 0, 2}}},
+	} {
+		if got := Covering(c.b, c.zoom); !sameTiles(got, c.want) {
+			t.Errorf("tiles covering %s at zoom %d are %v, want %v", c.b.Format(), c.zoom, got, c.want)
+		}
+	}
+}
+
+func testWay(ll ...float64) *way.Way {
+	w := &way.Way{}
+	for i := 0; i+1 < len(ll); i += 2 {
+		w.Nodes_ = append(w.Nodes_, &node.Node{Id_: int64(i / 2), Position_: point.New(ll[i], ll[i+1])})
+	}
+	return w
+}
+
+func TestCoveringWay(t *testing.T) {
+	for _, c := range []struct {
+		w    *way.Way
+		zoom int
+		want []Tile
+	}{
+		// the diagonals pass the center of the map above and below it
+		// in web mercator, at y 0.878 and 1.122
+		{testWay(60, -90, -30, 90), 1, []Tile{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}}},
+		{testWay(30, -90, -60, 90), 1, []Tile{{1, 0, 0}, {1, 0, 1}, {1, 1, 1}}},
+		// back and forth, every tile once in the order of the way
+		{testWay(10, 10, 10, -10, 10, 10), 1, []Tile{{1, 1, 0}, {1, 0, 0}}},
+		{testWay(10, 10), 3, []Tile{{3, 4, 3}}},
+		// clipped at the poles
+		{testWay(89, 10, 80, 10), 2, []Tile{{2, 2, 0}}},
+		{testWay(50.85, 4.35, 50.85, 4.75), 10, []Tile{{10, 524, 343}, {10, 525, 343}}},
+	} {
+		if got := CoveringWay(c.w, c.zoom); !sameTiles(got, c.want) {
+			t.Errorf("tiles of way %v at zoom %d are %v, want %v", c.w.Nodes_, c.zoom, got, c.want)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
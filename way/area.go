@@ -1,12 +1,15 @@
 package way
 
 import (
+	"github.com/brechtvm/osm/distance"
 	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/polygon"
 	"math"
 )
 
+// the signed shoelace area over all edges, including the closing one
 func (w *Way) area() float64 {
-	i := len(w.Nodes_) - 2
+	i := len(w.Nodes_) - 1
 	var a float64
 	for n := 0; n < i; n++ {
 		// a += (deg2rad(w.Nodes[n].Position_.Lon)*deg2rad(w.Nodes[n+1].Position_.Lat) -
@@ -26,6 +29,23 @@ func (w *Way) Area() float64 {
 	return math.Abs(w.area())
 }
 
+// Returns the geodesic area of the (closed) way in square meters, see
+// polygon.Ring.GeodesicArea()
+func (w *Way) GeodesicArea() distance.Area {
+	if !w.Closed() {
+		return -1.0
+	}
+	return polygon.Ring(w.Points()).GeodesicArea()
+}
+
+// Returns the perimeter of the (closed) way
+func (w *Way) Perimeter() distance.Distance {
+	if !w.Closed() {
+		return -1.0
+	}
+	return w.Length()
+}
+
 // http://alienryderflex.com/polygon/
 func (w *Way) Contains(n *node.Node) bool {
 	odd := false
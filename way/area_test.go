@@ -0,0 +1,42 @@
+package way
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/tags"
+	"math"
+	"testing"
+)
+
+// a way over the positions given as lat, lon pairs, the node ids are the
+// indexes of the positions, a closed way repeats the first node
+func testWay(closed bool, ll ...float64) *Way {
+	w := &Way{Id_: 1, Tags_: tags.New()}
+	for i := 0; i+1 < len(ll); i += 2 {
+		w.Nodes_ = append(w.Nodes_, &node.Node{Id_: int64(i / 2), Position_: point.New(ll[i], ll[i+1])})
+	}
+	if closed {
+		w.Nodes_ = append(w.Nodes_, w.Nodes_[0])
+	}
+	return w
+}
+
+// the 1° cell at 51°N 4°E, see polygon.TestGeodesicAreaCell
+func TestWayArea(t *testing.T) {
+	w := testWay(true, 51, 4, 51, 5, 52, 5, 52, 4)
+	if a := w.Area(); a != 1 {
+		t.Errorf("area %g square degrees, want 1", a)
+	}
+	if a := w.GeodesicArea(); math.Abs(float64(a)-7696867319.8) > 1 {
+		t.Errorf("geodesic area %s, want 7696867319.8m²", a)
+	}
+	if p := w.Perimeter(); math.Abs(float64(p)-360824.4) > 0.1 {
+		t.Errorf("perimeter %s, want 360824.4m", p)
+	}
+	open := testWay(false, 51, 4, 51, 5, 52, 5)
+	if open.Area() != -1 || open.GeodesicArea() != -1 || open.Perimeter() != -1 {
+		t.Errorf("open way has an area or a perimeter")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
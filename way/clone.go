@@ -0,0 +1,39 @@
+package way
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/user"
+)
+
+// returns a deep copy of the way with the same id, all nodes are copied
+// too. A node which is used more than once in the way (e.g. the first
+// and last node of a closed way) is copied only once.
+func (w *Way) DeepCopy() *Way {
+	return w.DeepCopyWith(make(map[int64]*node.Node))
+}
+
+// like DeepCopy(), but nodes already in the map are used instead of being
+// copied, new copies are added to the map. This keeps nodes shared between
+// ways shared in the copies.
+func (w *Way) DeepCopyWith(nodes map[int64]*node.Node) *Way {
+	c := *w
+	c.NodeIDs = append([]int64(nil), w.NodeIDs...)
+	if w.Nodes_ != nil {
+		c.Nodes_ = make([]*node.Node, len(w.Nodes_))
+		for i, n := range w.Nodes_ {
+			nc, ok := nodes[n.Id_]
+			if !ok {
+				nc = n.DeepCopy()
+				nodes[n.Id_] = nc
+			}
+			c.Nodes_[i] = nc
+		}
+	}
+	if w.User_ != nil {
+		c.User_ = user.New(w.User_.Id, w.User_.Name)
+	}
+	c.Tags_ = w.Tags_.Clone()
+	return &c
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
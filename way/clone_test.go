@@ -0,0 +1,65 @@
+package way
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/user"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	w := testWay(false, 0, 0, 0, 1, 1, 1)
+	w.Tags_.Add("highway", "residential")
+	c, err := w.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Id_ == w.Id_ || c.Tags_.Get("highway") != "residential" {
+		t.Errorf("clone has id %d and tags %v", c.Id_, c.Tags_)
+	}
+	// the nodes are shared, the list of nodes and the tags are not
+	if len(c.Nodes_) != 3 || c.Nodes_[1] != w.Nodes_[1] {
+		t.Error("clone does not share the nodes of the way")
+	}
+	c.Nodes_[0] = c.Nodes_[2]
+	c.Tags_.Add("highway", "service")
+	if w.Nodes_[0].Id_ != 0 || w.Tags_.Get("highway") != "residential" {
+		t.Error("way changed through the clone")
+	}
+
+	if c, err := testWay(false, 0, 0).Clone(); err == nil || c != nil {
+		t.Errorf("clone of a way with one node is %v, want an error", c)
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	w := testWay(true, 0, 0, 0, 1, 1, 1)
+	w.NodeIDs = []int64{0, 1, 2, 0}
+	w.Tags_.Add("area", "yes")
+	w.User_ = user.New(7, "alice")
+	c := w.DeepCopy()
+	if c.Id_ != w.Id_ || !c.Closed() || c.Nodes_[0] != c.Nodes_[3] {
+		t.Fatalf("copy of a closed way with id %d and nodes %v", c.Id_, c.Nodes_)
+	}
+	for i, n := range c.Nodes_ {
+		if n == w.Nodes_[i] || n.Id_ != w.Nodes_[i].Id_ || !n.Position_.Equal(w.Nodes_[i].Position_) {
+			t.Errorf("node %d of the copy is %v, want a copy of %v", i, n, w.Nodes_[i])
+		}
+	}
+	c.Nodes_[1].Position_.Lat = 5
+	c.NodeIDs[1] = 5
+	c.Tags_.Delete("area")
+	c.User_.Name = "bob"
+	if w.Nodes_[1].Position_.Lat != 0 || w.NodeIDs[1] != 1 || w.Tags_.Get("area") != "yes" || w.User_.Name != "alice" {
+		t.Error("way changed through the copy")
+	}
+
+	// nodes in the map are used instead of copies
+	nodes := map[int64]*node.Node{}
+	nodes[1] = w.Nodes_[1]
+	c = w.DeepCopyWith(nodes)
+	if c.Nodes_[1] != w.Nodes_[1] || c.Nodes_[0] == w.Nodes_[0] || nodes[0] != c.Nodes_[0] || len(nodes) != 3 {
+		t.Errorf("copy with a node map has the nodes %v, map %v", c.Nodes_, nodes)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,175 @@
+package way
+
+import (
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"math"
+)
+
+// Intersections are computed on lat/lon as planar coordinates, which is
+// exact enough for the short segments of OSM ways.
+
+// a crossing or touching of two segments, a segment is given by the index
+// of its first node
+type Intersection struct {
+	Point        *point.Point
+	Segment      int // of the way the method was called on
+	OtherSegment int // of the other way (or the same way for SelfIntersections())
+}
+
+// the orientation of c relative to a-b: positive if left, negative if
+// right, 0 if collinear
+func orient(a, b, c *point.Point) float64 {
+	v := (b.Lon-a.Lon)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lon-a.Lon)
+	// treat values in the range of the rounding error as collinear
+	eps := 1e-12 * (math.Abs(b.Lon-a.Lon) + math.Abs(b.Lat-a.Lat)) * (math.Abs(c.Lon-a.Lon) + math.Abs(c.Lat-a.Lat))
+	if math.Abs(v) <= eps {
+		return 0
+	}
+	return v
+}
+
+// checks if c (collinear with a-b) is on the segment a-b
+func onSegment(a, b, c *point.Point) bool {
+	return math.Min(a.Lon, b.Lon) <= c.Lon && c.Lon <= math.Max(a.Lon, b.Lon) &&
+		math.Min(a.Lat, b.Lat) <= c.Lat && c.Lat <= math.Max(a.Lat, b.Lat)
+}
+
+// Returns the points where the segments a-b and c-d meet: none, one or
+// (for collinear overlapping segments) the two ends of the overlap.
+func SegmentIntersection(a, b, c, d *point.Point) []*point.Point {
+	o1, o2 := orient(a, b, c), orient(a, b, d)
+	o3, o4 := orient(c, d, a), orient(c, d, b)
+	if o1 == 0 && o2 == 0 && o3 == 0 && o4 == 0 {
+		// collinear
+		var pl []*point.Point
+		add := func(p *point.Point) {
+			for _, q := range pl {
+				if q.Equal(p) {
+					return
+				}
+			}
+			pl = append(pl, point.New(p.Lat, p.Lon))
+		}
+		for _, p := range []*point.Point{c, d} {
+			if onSegment(a, b, p) {
+				add(p)
+			}
+		}
+		for _, p := range []*point.Point{a, b} {
+			if onSegment(c, d, p) {
+				add(p)
+			}
+		}
+		if len(pl) > 2 {
+			pl = pl[:2]
+		}
+		return pl
+	}
+	if (o1 > 0 && o2 > 0) || (o1 < 0 && o2 < 0) || (o3 > 0 && o4 > 0) || (o3 < 0 && o4 < 0) {
+		return nil
+	}
+	// touching in an end point
+	switch {
+	case o1 == 0 && onSegment(a, b, c):
+		return []*point.Point{point.New(c.Lat, c.Lon)}
+	case o2 == 0 && onSegment(a, b, d):
+		return []*point.Point{point.New(d.Lat, d.Lon)}
+	case o3 == 0 && onSegment(c, d, a):
+		return []*point.Point{point.New(a.Lat, a.Lon)}
+	case o4 == 0 && onSegment(c, d, b):
+		return []*point.Point{point.New(b.Lat, b.Lon)}
+	case o1 == 0 || o2 == 0 || o3 == 0 || o4 == 0:
+		return nil
+	}
+	t := o3 / (o3 - o4)
+	return []*point.Point{point.New(a.Lat+t*(b.Lat-a.Lat), a.Lon+t*(b.Lon-a.Lon))}
+}
+
+// checks if p is the position of n
+func atNode(n *node.Node, p *point.Point) bool {
+	return n.Position_.Equal(p)
+}
+
+// the intersection points of two segments without the position of a node
+// shared by both
+func segmentCrossings(a, b, c, d *node.Node) []*point.Point {
+	var pl []*point.Point
+	for _, p := range SegmentIntersection(a.Position_, b.Position_, c.Position_, d.Position_) {
+		shared := false
+		for _, n := range []*node.Node{a, b} {
+			if (n.Id_ == c.Id_ && atNode(c, p)) || (n.Id_ == d.Id_ && atNode(d, p)) {
+				shared = true
+			}
+		}
+		if !shared {
+			pl = append(pl, p)
+		}
+	}
+	return pl
+}
+
+// Returns the points where the way crosses or touches the other way,
+// meeting in a node which is part of both ways does not count. Collinear
+// overlapping segments give the two ends of the overlap.
+func (w *Way) Intersections(o *Way) []Intersection {
+	var il []Intersection
+	for i := 0; i < len(w.Nodes_)-1; i++ {
+		a, b := w.Nodes_[i], w.Nodes_[i+1]
+		for j := 0; j < len(o.Nodes_)-1; j++ {
+			c, d := o.Nodes_[j], o.Nodes_[j+1]
+			if !boxesOverlap(a, b, c, d) {
+				continue
+			}
+			for _, p := range segmentCrossings(a, b, c, d) {
+				il = append(il, Intersection{Point: p, Segment: i, OtherSegment: j})
+			}
+		}
+	}
+	return il
+}
+
+// checks if the bounding boxes of the segments a-b and c-d overlap
+func boxesOverlap(a, b, c, d *node.Node) bool {
+	p, q, r, s := a.Position_, b.Position_, c.Position_, d.Position_
+	return math.Max(p.Lon, q.Lon) >= math.Min(r.Lon, s.Lon) && math.Max(r.Lon, s.Lon) >= math.Min(p.Lon, q.Lon) &&
+		math.Max(p.Lat, q.Lat) >= math.Min(r.Lat, s.Lat) && math.Max(r.Lat, s.Lat) >= math.Min(p.Lat, q.Lat)
+}
+
+// Returns the points where the way crosses or touches itself, with
+// Segment < OtherSegment. Consecutive segments (and for a closed way the
+// last and the first one) meeting in their common node do not count.
+func (w *Way) SelfIntersections() []Intersection {
+	var il []Intersection
+	l := len(w.Nodes_) - 1
+	closed := w.Closed()
+	for i := 0; i < l; i++ {
+		a, b := w.Nodes_[i], w.Nodes_[i+1]
+		for j := i + 1; j < l; j++ {
+			c, d := w.Nodes_[j], w.Nodes_[j+1]
+			if !boxesOverlap(a, b, c, d) {
+				continue
+			}
+			var shared *node.Node
+			if j == i+1 {
+				shared = b
+			} else if closed && i == 0 && j == l-1 {
+				shared = a
+			}
+			for _, p := range SegmentIntersection(a.Position_, b.Position_, c.Position_, d.Position_) {
+				if shared != nil && atNode(shared, p) {
+					continue
+				}
+				il = append(il, Intersection{Point: p, Segment: i, OtherSegment: j})
+			}
+		}
+	}
+	return il
+}
+
+// checks if the way does not cross or touch itself
+func (w *Way) IsSimple() bool {
+	return len(w.SelfIntersections()) == 0
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,129 @@
+package way
+
+import (
+	"github.com/brechtvm/osm/point"
+	"testing"
+)
+
+// checks if the points are at the positions given as lat, lon pairs
+func samePoints(pl []*point.Point, ll ...float64) bool {
+	if len(pl)*2 != len(ll) {
+		return false
+	}
+	for i, p := range pl {
+		if !samePoint(p, ll[2*i], ll[2*i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// like testWay() with the node ids starting at first
+func otherWay(first int64, ll ...float64) *Way {
+	w := testWay(false, ll...)
+	for _, n := range w.Nodes_ {
+		n.Id_ += first
+	}
+	return w
+}
+
+func TestSegmentIntersection(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		abcd [8]float64 // a, b, c and d as lat, lon pairs
+		want []float64
+	}{
+		{"crossing", [8]float64{0, 0, 2, 2, 0, 2, 2, 0}, []float64{1, 1}},
+		{"parallel", [8]float64{0, 0, 0, 2, 1, 0, 1, 2}, nil},
+		{"apart", [8]float64{0, 0, 2, 2, 3, 0, 2, 1}, nil},
+		{"T", [8]float64{0, 0, 0, 2, 0, 1, 1, 1}, []float64{0, 1}},
+		{"T reversed", [8]float64{0, 1, 1, 1, 0, 0, 0, 2}, []float64{0, 1}},
+		{"short of a T", [8]float64{0, 0, 0, 2, 0.1, 1, 1, 1}, nil},
+		{"end to end", [8]float64{0, 0, 1, 1, 1, 1, 2, 0}, []float64{1, 1}},
+		// collinear
+		{"overlapping", [8]float64{0, 0, 0, 3, 0, 1, 0, 5}, []float64{0, 1, 0, 3}},
+		{"containing", [8]float64{0, 0, 0, 4, 0, 2, 0, 1}, []float64{0, 2, 0, 1}},
+		{"identical", [8]float64{0, 0, 1, 1, 1, 1, 0, 0}, []float64{1, 1, 0, 0}},
+		{"continued", [8]float64{0, 0, 0, 1, 0, 1, 0, 2}, []float64{0, 1}},
+		{"in line", [8]float64{0, 0, 0, 1, 0, 2, 0, 3}, nil},
+	} {
+		v := c.abcd
+		pl := SegmentIntersection(point.New(v[0], v[1]), point.New(v[2], v[3]), point.New(v[4], v[5]), point.New(v[6], v[7]))
+		if !samePoints(pl, c.want...) {
+			t.Errorf("%s: intersections %v, want %v", c.name, pl, c.want)
+		}
+	}
+}
+
+func TestIntersections(t *testing.T) {
+	w := testWay(false, 0, 0, 0, 2, 0, 4)
+	for _, c := range []struct {
+		name string
+		o    *Way
+		want []Intersection
+	}{
+		{"crossing", otherWay(10, -1, 3, 1, 3), []Intersection{{point.New(0, 3), 1, 0}}},
+		{"crossing twice", otherWay(10, -1, 1, 1, 1, 1, 3, -1, 3), []Intersection{{point.New(0, 1), 0, 0}, {point.New(0, 3), 1, 2}}},
+		// touched by both segments of o
+		{"touching", otherWay(10, 1, 2.5, 0, 3, 1, 3.5), []Intersection{{point.New(0, 3), 1, 0}, {point.New(0, 3), 1, 1}}},
+		{"overlapping", otherWay(10, 0, 3, 0, 5), []Intersection{{point.New(0, 3), 1, 0}, {point.New(0, 4), 1, 0}}},
+	} {
+		if got := w.Intersections(c.o); !sameIntersections(got, c.want) {
+			t.Errorf("%s: intersections %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	// meeting in a shared node does not count, going on along w does
+	o := otherWay(10, 1, 2, 0, 2, 0, 3)
+	o.Nodes_[1] = w.Nodes_[1]
+	want := []Intersection{{point.New(0, 3), 1, 1}}
+	if got := w.Intersections(o); !sameIntersections(got, want) {
+		t.Errorf("shared node: intersections %v, want %v", got, want)
+	}
+	// a node at the same position which is not shared counts
+	o = otherWay(10, 1, 2, 0, 2, -1, 2)
+	want = []Intersection{{point.New(0, 2), 0, 0}, {point.New(0, 2), 0, 1}, {point.New(0, 2), 1, 0}, {point.New(0, 2), 1, 1}}
+	if got := w.Intersections(o); !sameIntersections(got, want) {
+		t.Errorf("node at the same position: intersections %v, want %v", got, want)
+	}
+}
+
+func sameIntersections(a, b []Intersection) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !samePoint(a[i].Point, b[i].Point.Lat, b[i].Point.Lon) || a[i].Segment != b[i].Segment || a[i].OtherSegment != b[i].OtherSegment {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSelfIntersections(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		w    *Way
+		want []Intersection
+	}{
+		{"square", testWay(true, 0, 0, 0, 1, 1, 1, 1, 0), nil},
+		{"line", testWay(false, 0, 0, 0, 1, 1, 1), nil},
+		{"figure eight", testWay(true, 0, 0, 1, 1, 1, 0, 0, 1), []Intersection{{point.New(0.5, 0.5), 0, 2}}},
+		// the last node on the first segment
+		{"lasso", testWay(false, 0, 0, 0, 2, 1, 1, 0, 1), []Intersection{{point.New(0, 1), 0, 2}}},
+		// going back along itself, collinear with the previous segment
+		{"turning back", testWay(false, 0, 0, 0, 2, 0, 1), []Intersection{{point.New(0, 1), 0, 1}}},
+		// an open way ending at the position of its first node
+		{"ring of an open way", testWay(false, 0, 0, 0, 1, 1, 1, 0, 0), []Intersection{{point.New(0, 0), 0, 2}}},
+	} {
+		got := c.w.SelfIntersections()
+		if !sameIntersections(got, c.want) {
+			t.Errorf("%s: self intersections %v, want %v", c.name, got, c.want)
+		}
+		if c.w.IsSimple() != (len(c.want) == 0) {
+			t.Errorf("%s: IsSimple() = %v", c.name, c.w.IsSimple())
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
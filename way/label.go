@@ -0,0 +1,36 @@
+package way
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/point"
+)
+
+// returns a point inside the closed way, see polygon.PointOnSurface()
+func (w *Way) PointOnSurface() (*point.Point, error) {
+	p, err := w.Polygon()
+	if err != nil {
+		return nil, err
+	}
+	if q := p.PointOnSurface(); q != nil {
+		return q, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Way #%d has no area", w.Id_))
+}
+
+// returns the pole of inaccessibility of the closed way and its distance
+// from the way, see polygon.PoleOfInaccessibility()
+func (w *Way) PoleOfInaccessibility(tolerance distance.Distance) (*point.Point, distance.Distance, error) {
+	p, err := w.Polygon()
+	if err != nil {
+		return nil, 0, err
+	}
+	q, d := p.PoleOfInaccessibility(tolerance)
+	if q == nil {
+		return nil, 0, errors.New(fmt.Sprintf("Way #%d has no area", w.Id_))
+	}
+	return q, d, nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,39 @@
+package way
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"math"
+	"testing"
+)
+
+// an L of 2° wide bars on the equator, the largest circle inside touches
+// the outer edges at t° and the inner corner at √2(2 - t)°, t = 4 - 2√2
+func TestWayPoleOfInaccessibility(t *testing.T) {
+	w := testWay(true, -3, 0, -3, 6, -1, 6, -1, 2, 3, 2, 3, 0)
+	tolerance := 100 * distance.Meter
+	r := 4 - 2*math.Sqrt2
+	p, d, err := w.PoleOfInaccessibility(tolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := distance.Distance(r) * degree; d > want || d < want-tolerance {
+		t.Errorf("pole %v from the way, want %v", d, want)
+	}
+	if math.Abs(p.Lat-(r-3)) > 0.01 || math.Abs(p.Lon-r) > 0.01 {
+		t.Errorf("pole at %v, want %v,%v", p, r-3, r)
+	}
+	pg, _ := w.Polygon()
+	if p, err = w.PointOnSurface(); err != nil || !pg.Contains(p) {
+		t.Errorf("point on surface %v %v not inside", p, err)
+	}
+
+	open := testWay(false, -3, 0, -3, 6, -1, 6, -1, 2, 3, 2, 3, 0)
+	if _, _, err = open.PoleOfInaccessibility(tolerance); err == nil {
+		t.Error("no error for an open way")
+	}
+	if _, err = open.PointOnSurface(); err == nil {
+		t.Error("no error for the point on surface of an open way")
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
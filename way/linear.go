@@ -0,0 +1,117 @@
+package way
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+)
+
+// Linear referencing uses the same distance model as Length(): great
+// circle distances between the nodes, positions between two nodes are on
+// the great circle through them.
+
+// offsets closer than this to a node are snapped to the node
+const linearTolerance = distance.Millimeter
+
+// the result of LocatePoint()
+type Location struct {
+	Offset   distance.Distance // distance from the first node along the way
+	Distance distance.Distance // distance of the point from the way
+	Segment  int               // index of the first node of the segment
+	Point    *point.Point      // the closest position on the way
+}
+
+// returns the position at the distance d (along the way) from the first
+// node
+func (w *Way) PointAt(d distance.Distance) (*point.Point, error) {
+	if len(w.Nodes_) < 2 {
+		return nil, errors.New(fmt.Sprintf("Way #%d has too few nodes", w.Id_))
+	}
+	if d < 0 {
+		return nil, errors.New(fmt.Sprintf("Negative offset %s", d))
+	}
+	var l distance.Distance
+	for i := 0; i < len(w.Nodes_)-1; i++ {
+		a, b := w.Nodes_[i].Position_, w.Nodes_[i+1].Position_
+		sl := a.DistanceOf(b)
+		if d <= l+sl {
+			return point.Interpolate(a, b, d-l), nil
+		}
+		l += sl
+	}
+	if d-l <= linearTolerance {
+		p := w.LastNode().Position_
+		return point.New(p.Lat, p.Lon), nil
+	}
+	return nil, errors.New(fmt.Sprintf("Offset %s beyond end of way #%d (%s)", d, w.Id_, l))
+}
+
+// projects p onto the way: returns the closest position on the way, its
+// offset from the first node and the distance of p from the way
+func (w *Way) LocatePoint(p *point.Point) (*Location, error) {
+	if len(w.Nodes_) < 2 {
+		return nil, errors.New(fmt.Sprintf("Way #%d has too few nodes", w.Id_))
+	}
+	var best *Location
+	var l distance.Distance
+	for i := 0; i < len(w.Nodes_)-1; i++ {
+		a, b := w.Nodes_[i].Position_, w.Nodes_[i+1].Position_
+		q, at := p.ProjectOnSegment(a, b)
+		d := p.DistanceOf(q)
+		if best == nil || d < best.Distance {
+			best = &Location{Offset: l + at, Distance: d, Segment: i, Point: q}
+		}
+		l += a.DistanceOf(b)
+	}
+	return best, nil
+}
+
+// Returns a new way from the offset from to the offset to (both measured
+// from the first node). Nodes of w between the offsets are shared with w,
+// new nodes are created at the offsets unless they are at a node of w.
+// The tags are copied.
+func (w *Way) SubWay(from, to distance.Distance) (*Way, error) {
+	if len(w.Nodes_) < 2 {
+		return nil, errors.New(fmt.Sprintf("Way #%d has too few nodes", w.Id_))
+	}
+	if from < 0 || to <= from {
+		return nil, errors.New(fmt.Sprintf("Invalid offsets %s - %s", from, to))
+	}
+	if total := w.Length(); to > total+linearTolerance {
+		return nil, errors.New(fmt.Sprintf("Offset %s beyond end of way #%d (%s)", to, w.Id_, total))
+	}
+
+	var nl []*node.Node
+	var l distance.Distance
+	for i := 0; i < len(w.Nodes_)-1; i++ {
+		a, b := w.Nodes_[i], w.Nodes_[i+1]
+		sl := a.Position_.DistanceOf(b.Position_)
+		switch {
+		case nl == nil && from-l <= linearTolerance:
+			nl = append(nl, a)
+		case nl == nil && from < l+sl-linearTolerance:
+			nl = append(nl, node.New(point.Interpolate(a.Position_, b.Position_, from-l)))
+		}
+		if nl != nil {
+			if to <= l+linearTolerance {
+				break
+			}
+			if to < l+sl-linearTolerance {
+				nl = append(nl, node.New(point.Interpolate(a.Position_, b.Position_, to-l)))
+				break
+			}
+			nl = append(nl, b)
+		}
+		l += sl
+	}
+	nw, err := New(nl)
+	if err != nil {
+		return nil, err
+	}
+	nw.Tags_ = w.Tags_.Clone()
+	return nw, nil
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -0,0 +1,108 @@
+package way
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/point"
+	"math"
+	"testing"
+)
+
+// one degree of a great circle
+const degree = distance.Distance(6371000 * math.Pi / 180)
+
+func samePoint(p *point.Point, lat, lon float64) bool {
+	return p != nil && math.Abs(p.Lat-lat) < 1e-9 && math.Abs(p.Lon-lon) < 1e-9
+}
+
+func near(a, b distance.Distance) bool {
+	return math.Abs(float64(a-b)) < 0.001
+}
+
+// along the equator from 0° over 1°E to 3°E
+func equatorWay() *Way {
+	return testWay(false, 0, 0, 0, 1, 0, 3)
+}
+
+func TestPointAt(t *testing.T) {
+	w := equatorWay()
+	for _, c := range []struct {
+		d   distance.Distance
+		lon float64
+	}{
+		{0, 0},
+		{degree / 2, 0.5},
+		{degree, 1},
+		{1.5 * degree, 1.5},
+		{3 * degree, 3},
+		// within the tolerance past the end
+		{3*degree + distance.Millimeter/2, 3},
+	} {
+		if p, err := w.PointAt(c.d); err != nil || !samePoint(p, 0, c.lon) {
+			t.Errorf("PointAt(%s) = %v %v, want 0,%g", c.d, p, err, c.lon)
+		}
+	}
+	for _, d := range []distance.Distance{-1, 3*degree + 1} {
+		if p, err := w.PointAt(d); err == nil {
+			t.Errorf("PointAt(%s) = %v, want an error", d, p)
+		}
+	}
+	// along a meridian
+	if p, err := testWay(false, 50, 4, 51, 4).PointAt(degree / 4); err != nil || !samePoint(p, 50.25, 4) {
+		t.Errorf("PointAt() on the meridian = %v %v, want 50.25,4", p, err)
+	}
+	if _, err := testWay(false, 0, 0).PointAt(0); err == nil {
+		t.Error("no error for a way with one node")
+	}
+}
+
+func TestLocatePoint(t *testing.T) {
+	w := equatorWay()
+	for _, c := range []struct {
+		p        *point.Point
+		offset   distance.Distance
+		segment  int
+		lon      float64
+		distance distance.Distance
+	}{
+		// the meridian through p meets the equator at right angles
+		{point.New(0.1, 2), 2 * degree, 1, 2, degree / 10},
+		{point.New(-0.2, 0.5), degree / 2, 0, 0.5, degree / 5},
+		// before the start and past the end the ends are the closest
+		{point.New(0, -0.5), 0, 0, 0, degree / 2},
+		{point.New(0, 4), 3 * degree, 1, 3, degree},
+	} {
+		l, err := w.LocatePoint(c.p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !near(l.Offset, c.offset) || l.Segment != c.segment || !samePoint(l.Point, 0, c.lon) || !near(l.Distance, c.distance) {
+			t.Errorf("LocatePoint(%v) = %s at segment %d, %v, %s away, want %s at %d, 0,%g, %s away",
+				c.p, l.Offset, l.Segment, l.Point, l.Distance, c.offset, c.segment, c.lon, c.distance)
+		}
+	}
+}
+
+func TestSubWay(t *testing.T) {
+	w := equatorWay()
+	s, err := w.SubWay(degree/2, 2*degree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Nodes_) != 3 || !samePoint(s.Nodes_[0].Position_, 0, 0.5) || s.Nodes_[1] != w.Nodes_[1] || !samePoint(s.Nodes_[2].Position_, 0, 2) {
+		t.Errorf("SubWay(0.5°, 2°) has the nodes %v", s.Points())
+	}
+	if !near(s.Length(), 1.5*degree) {
+		t.Errorf("SubWay(0.5°, 2°) is %s long, want %s", s.Length(), 1.5*degree)
+	}
+	// starting at a node
+	if s, err = w.SubWay(degree, 3*degree); err != nil || len(s.Nodes_) != 2 || s.Nodes_[0] != w.Nodes_[1] || s.Nodes_[1] != w.Nodes_[2] {
+		t.Errorf("SubWay(1°, 3°) = %v %v, want the last two nodes", s, err)
+	}
+	for _, c := range [][2]distance.Distance{{-1, degree}, {degree, degree}, {0, 3*degree + 1}} {
+		if _, err := w.SubWay(c[0], c[1]); err == nil {
+			t.Errorf("SubWay(%s, %s) without error", c[0], c[1])
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
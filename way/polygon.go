@@ -0,0 +1,41 @@
+package way
+
+import (
+	"errors"
+	"fmt"
+	"github.com/brechtvm/osm/point"
+	"github.com/brechtvm/osm/polygon"
+)
+
+// returns the positions of the nodes as polygon.Ring, the way must be
+// closed
+func (w *Way) Ring() (polygon.Ring, error) {
+	if !w.Closed() {
+		return nil, errors.New(fmt.Sprintf("Way #%d is not closed", w.Id_))
+	}
+	r := make(polygon.Ring, len(w.Nodes_))
+	for i, n := range w.Nodes_ {
+		r[i] = n.Position_
+	}
+	return r, nil
+}
+
+// returns the closed way as polygon without holes
+func (w *Way) Polygon() (*polygon.Polygon, error) {
+	r, err := w.Ring()
+	if err != nil {
+		return nil, err
+	}
+	return polygon.New(r), nil
+}
+
+// returns the positions of the nodes
+func (w *Way) Points() []*point.Point {
+	p := make([]*point.Point, len(w.Nodes_))
+	for i, n := range w.Nodes_ {
+		p[i] = n.Position_
+	}
+	return p
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
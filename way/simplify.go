@@ -0,0 +1,256 @@
+package way
+
+import (
+	"container/heap"
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/point"
+	"math"
+)
+
+// the algorithms of Simplify()
+type SimplifyAlgorithm int
+
+const (
+	// Douglas-Peucker: removes nodes closer than the tolerance to the
+	// simplified line
+	DouglasPeucker SimplifyAlgorithm = iota
+	// Visvalingam-Whyatt: removes nodes whose triangle with their
+	// neighbours has an area of less than tolerance²
+	Visvalingam
+)
+
+func (a SimplifyAlgorithm) String() string {
+	switch a {
+	case DouglasPeucker:
+		return "Douglas-Peucker"
+	case Visvalingam:
+		return "Visvalingam"
+	}
+	return "unknown"
+}
+
+// Returns a simplified version of the way and the removed nodes. The
+// simplified way has the id, metadata and (a copy of) the tags of w, the
+// nodes are shared with w, w itself is not changed.
+//
+// The first and the last node are always kept, as are nodes for which one
+// of the keep funcs returns true, e.g.
+//
+//	sw, removed := w.Simplify(5*distance.Meter, way.DouglasPeucker,
+//		way.KeepTagged, way.KeepShared(*o.GetWayList()...))
+//
+// keeps the topology of a road network. Closed ways keep at least three
+// different nodes, so they stay closed and never collapse to a line.
+func (w *Way) Simplify(tolerance distance.Distance, algorithm SimplifyAlgorithm, keep ...func(*node.Node) bool) (*Way, []*node.Node) {
+	l := len(w.Nodes_)
+	rank := make([]float64, l)
+	if l > 0 {
+		rank[0] = math.Inf(1)
+		rank[l-1] = math.Inf(1)
+	}
+	for i, n := range w.Nodes_ {
+		for _, k := range keep {
+			if k(n) {
+				rank[i] = math.Inf(1)
+				break
+			}
+		}
+	}
+
+	var limit float64
+	switch algorithm {
+	case Visvalingam:
+		visvalingam(w.Nodes_, rank)
+		limit = float64(tolerance * tolerance)
+	default:
+		douglasPeucker(w.Nodes_, rank)
+		limit = float64(tolerance)
+	}
+
+	kept := make([]bool, l)
+	count := 0
+	for i, r := range rank {
+		if r >= limit {
+			kept[i] = true
+			count++
+		}
+	}
+	min := 2
+	if w.Closed() {
+		min = 4
+	}
+	// add the most important of the removed nodes until there are enough
+	for count < min && count < l {
+		best := -1
+		for i, r := range rank {
+			if !kept[i] && (best == -1 || r > rank[best]) {
+				best = i
+			}
+		}
+		kept[best] = true
+		count++
+	}
+
+	sw := &Way{
+		Id_:        w.Id_,
+		User_:      w.User_,
+		Tags_:      w.Tags_.Clone(),
+		Timestamp_: w.Timestamp_,
+		Version_:   w.Version_,
+		Changeset_: w.Changeset_,
+		Visible_:   w.Visible_,
+		modified:   count < l,
+	}
+	var removed []*node.Node
+	for i, n := range w.Nodes_ {
+		if kept[i] {
+			sw.Nodes_ = append(sw.Nodes_, n)
+		} else {
+			removed = append(removed, n)
+		}
+	}
+	if len(w.NodeIDs) == l {
+		for _, n := range sw.Nodes_ {
+			sw.NodeIDs = append(sw.NodeIDs, n.Id_)
+		}
+	}
+	return sw, removed
+}
+
+// a keep func for Simplify() which keeps tagged nodes
+func KeepTagged(n *node.Node) bool {
+	return n.Tags_ != nil && len(*n.Tags_) > 0
+}
+
+// returns a keep func for Simplify() which keeps nodes that are part of
+// more than one of the ways
+func KeepShared(ways ...*Way) func(*node.Node) bool {
+	count := make(map[int64]int)
+	for _, w := range ways {
+		seen := make(map[int64]bool, len(w.Nodes_))
+		for _, n := range w.Nodes_ {
+			if !seen[n.Id_] {
+				seen[n.Id_] = true
+				count[n.Id_]++
+			}
+		}
+	}
+	return func(n *node.Node) bool {
+		return count[n.Id_] > 1
+	}
+}
+
+// the distance of p from the segment from a to b
+func segmentDistance(p, a, b *point.Point) distance.Distance {
+	sl := a.DistanceOf(b)
+	if sl == 0 {
+		return p.DistanceOf(a)
+	}
+	at := p.AlongTrackDistance(a, b)
+	if at <= 0 {
+		return p.DistanceOf(a)
+	}
+	if at >= sl {
+		return p.DistanceOf(b)
+	}
+	return distance.Distance(math.Abs(float64(p.CrossTrackDistance(a, b))))
+}
+
+// Sets the rank of every node which is not kept anyway (rank +Inf) to the
+// largest tolerance in metres for which Douglas-Peucker keeps it. The kept
+// nodes split the way into parts which are simplified independently.
+func douglasPeucker(nl []*node.Node, rank []float64) {
+	var dp func(from, to int, parent float64)
+	dp = func(from, to int, parent float64) {
+		if to-from < 2 {
+			return
+		}
+		best, max := -1, -1.0
+		a, b := nl[from].Position_, nl[to].Position_
+		for i := from + 1; i < to; i++ {
+			if d := float64(segmentDistance(nl[i].Position_, a, b)); d > max {
+				best, max = i, d
+			}
+		}
+		// a node is never more important than the one which split its part
+		rank[best] = math.Min(max, parent)
+		dp(from, best, rank[best])
+		dp(best, to, rank[best])
+	}
+	from := 0
+	for i := 1; i < len(nl); i++ {
+		if math.IsInf(rank[i], 1) {
+			dp(from, i, math.Inf(1))
+			from = i
+		}
+	}
+}
+
+// the area of the triangle in square metres, on a local plane
+func triangleArea(a, b, c *point.Point) float64 {
+	k := math.Cos(b.Lat*math.Pi/180) * math.Pi / 180 * float64(distance.EarthRadius)
+	m := math.Pi / 180 * float64(distance.EarthRadius)
+	ax, ay := (a.Lon-b.Lon)*k, (a.Lat-b.Lat)*m
+	cx, cy := (c.Lon-b.Lon)*k, (c.Lat-b.Lat)*m
+	return math.Abs(ax*cy-ay*cx) / 2
+}
+
+type vwEntry struct {
+	i    int
+	area float64
+	seq  int
+}
+
+type vwHeap []vwEntry
+
+func (h vwHeap) Len() int            { return len(h) }
+func (h vwHeap) Less(i, j int) bool  { return h[i].area < h[j].area }
+func (h vwHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *vwHeap) Push(x interface{}) { *h = append(*h, x.(vwEntry)) }
+func (h *vwHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// Sets the rank of every node which is not kept anyway (rank +Inf) to the
+// effective area in square metres at which Visvalingam-Whyatt removes it.
+func visvalingam(nl []*node.Node, rank []float64) {
+	l := len(nl)
+	prev := make([]int, l)
+	next := make([]int, l)
+	seq := make([]int, l)
+	h := &vwHeap{}
+	for i := range nl {
+		prev[i], next[i] = i-1, i+1
+		if i > 0 && i < l-1 && !math.IsInf(rank[i], 1) {
+			heap.Push(h, vwEntry{i: i, area: triangleArea(nl[i-1].Position_, nl[i].Position_, nl[i+1].Position_)})
+		}
+	}
+	update := func(i int) {
+		if i <= 0 || i >= l-1 || math.IsInf(rank[i], 1) {
+			return
+		}
+		seq[i]++
+		heap.Push(h, vwEntry{i: i, seq: seq[i], area: triangleArea(nl[prev[i]].Position_, nl[i].Position_, nl[next[i]].Position_)})
+	}
+	last := 0.0
+	for h.Len() > 0 {
+		e := heap.Pop(h).(vwEntry)
+		if e.seq != seq[e.i] {
+			continue
+		}
+		// the effective area never decreases, so a node removed later is
+		// at least as important as the ones removed before
+		last = math.Max(last, e.area)
+		rank[e.i] = last
+		p, n := prev[e.i], next[e.i]
+		next[p], prev[n] = n, p
+		update(p)
+		update(n)
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
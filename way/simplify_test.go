@@ -0,0 +1,100 @@
+package way
+
+import (
+	"github.com/brechtvm/osm/distance"
+	"github.com/brechtvm/osm/node"
+	"github.com/brechtvm/osm/tags"
+	"testing"
+)
+
+// Near the equator, where 0.001° are 111.19m in both directions: a
+// zigzag of the nodes 0 to 4. Douglas-Peucker keeps node 3 up to 333.6m
+// (its distance from the line 0-4), node 2 up to 157.3m (from 0-3) and
+// node 1 up to 11.1m (from 0-2). Visvalingam removes node 1 with its
+// triangle of 1236m² (35.2m²), then node 2 with the triangle 0-2-3 of
+// 37093m² (192.6m²) and node 3 with 0-3-4 of 111276m² (333.6m²).
+func zigzag() *Way {
+	return testWay(false, 0, 0, 0.0001, 0.001, 0, 0.002, 0.003, 0.003, 0, 0.006)
+}
+
+func nodeIds(nl []*node.Node) []int64 {
+	ids := []int64{}
+	for _, n := range nl {
+		ids = append(ids, n.Id_)
+	}
+	return ids
+}
+
+func sameIds(a []int64, b ...int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSimplify(t *testing.T) {
+	for _, c := range []struct {
+		a         SimplifyAlgorithm
+		tolerance distance.Distance
+		kept      []int64
+	}{
+		{DouglasPeucker, 5, []int64{0, 1, 2, 3, 4}},
+		{DouglasPeucker, 50, []int64{0, 2, 3, 4}},
+		{DouglasPeucker, 200, []int64{0, 3, 4}},
+		{DouglasPeucker, 400, []int64{0, 4}},
+		{Visvalingam, 20, []int64{0, 1, 2, 3, 4}},
+		{Visvalingam, 100, []int64{0, 2, 3, 4}},
+		{Visvalingam, 250, []int64{0, 3, 4}},
+		{Visvalingam, 400, []int64{0, 4}},
+	} {
+		w := zigzag()
+		sw, removed := w.Simplify(c.tolerance, c.a)
+		if got := nodeIds(sw.Nodes_); !sameIds(got, c.kept...) {
+			t.Errorf("%s with %s kept %v, want %v", c.a, c.tolerance, got, c.kept)
+		}
+		if len(removed)+len(sw.Nodes_) != 5 || len(w.Nodes_) != 5 {
+			t.Errorf("%s with %s: %d removed nodes, %d left in the way", c.a, c.tolerance, len(removed), len(w.Nodes_))
+		}
+	}
+}
+
+func TestSimplifyKeep(t *testing.T) {
+	w := zigzag()
+	w.Nodes_[1].Tags_ = &tags.Tags{"highway": "crossing"}
+	other := testWay(false, 1, 1)
+	other.Nodes_[0] = w.Nodes_[2]
+	for _, a := range []SimplifyAlgorithm{DouglasPeucker, Visvalingam} {
+		sw, _ := w.Simplify(400, a, KeepTagged, KeepShared(w, other))
+		if got := nodeIds(sw.Nodes_); !sameIds(got, 0, 1, 2, 4) {
+			t.Errorf("%s kept %v, want the tagged node 1 and the shared node 2", a, got)
+		}
+	}
+}
+
+// A square of 222m with node 2 1.1m off the edge from node 1 to 3, the
+// triangle 1-2-3 has 123m² (11.1m²). Closed ways keep three different
+// nodes.
+func TestSimplifyClosed(t *testing.T) {
+	for _, a := range []SimplifyAlgorithm{DouglasPeucker, Visvalingam} {
+		w := testWay(true, 0, 0, 0, 0.002, 0.001, 0.00201, 0.002, 0.002, 0.002, 0)
+		w.NodeIDs = []int64{0, 1, 2, 3, 4, 0}
+		sw, removed := w.Simplify(100000, a)
+		if !sw.Closed() || len(sw.Nodes_) != 4 || len(removed) != 2 {
+			t.Errorf("%s: %v closed %v, %d removed", a, nodeIds(sw.Nodes_), sw.Closed(), len(removed))
+		}
+		if !sameIds(sw.NodeIDs, nodeIds(sw.Nodes_)...) {
+			t.Errorf("%s: NodeIDs %v, nodes %v", a, sw.NodeIDs, nodeIds(sw.Nodes_))
+		}
+		sw, _ = w.Simplify(15, a)
+		if got := nodeIds(sw.Nodes_); !sameIds(got, 0, 1, 3, 4, 0) {
+			t.Errorf("%s with 15m kept %v, want [0 1 3 4 0]", a, got)
+		}
+	}
+}
+
+// vim: ts=4 sw=4 noexpandtab nolist syn=go
@@ -1,28 +1,16 @@
 package way
 
-import ()
+import (
+	"github.com/brechtvm/osm/item"
+)
 
 // sort.Interface:
 
-// sort negative ids on top of all other in ascending math.Abs(Id)
+// sort negative ids on top of all other in ascending math.Abs(Id), see
+// item.IdLess()
 func (wl *WayList) Less(i, j int) bool {
 	w := []*Way(*wl)
-	if w[i].Id_ < 0 && w[j].Id_ < 0 {
-		if w[i].Id_ > w[j].Id_ {
-			return true
-		}
-		return false
-	}
-	if w[i].Id_ < 0 && w[j].Id_ > 0 {
-		return false
-	}
-	if w[i].Id_ > 0 && w[j].Id_ < 0 {
-		return true
-	}
-	if w[i].Id_ < w[j].Id_ {
-		return true
-	}
-	return false
+	return item.IdLess(w[i].Id_, w[j].Id_)
 }
 
 func (wl *WayList) Len() int {
@@ -354,18 +354,16 @@ func (w *Way) Closed() bool {
 	return w.Nodes_[0].Id_ == w.Nodes_[num-1].Id_
 }
 
+// returns a new way (with a new id) with the same nodes and a copy of the
+// tags. The nodes are shared with w, use DeepCopy() to copy them too.
 func (w *Way) Clone() (nw *Way, err error) {
 	nd := []*node.Node{}
 	for _, n := range w.Nodes_ {
 		nd = append(nd, n)
 	}
-	t := tags.New()
-	for key := range map[string]string(*w.Tags_) {
-		t.Add(key, w.Tags_.Get(key))
-	}
 	nw, err = New(nd)
-	if err != nil {
-		nw.Tags_ = t
+	if err == nil {
+		nw.Tags_ = w.Tags_.Clone()
 	}
 	return
 }
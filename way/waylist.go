@@ -57,7 +57,7 @@ func (wl WayList) AsWay() (w *Way, err error) {
 			n.Reverse()
 			w.Join(n)
 		case NotConnected:
-			err = errors.New(fmt.Sprintf("Not connected to way #%d\n", cur.Id))
+			err = errors.New(fmt.Sprintf("Not connected to way #%d\n", cur.Id()))
 			return
 		}
 	}